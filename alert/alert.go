@@ -0,0 +1,197 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/mail"
+	"github.com/tdycwym/edgex_admin/notify"
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+// 告警类型, 即用户偏好中可选择的类型
+const (
+	TypeOffline        = "offline"         // 网关离线
+	TypeOnline         = "online"          // 网关恢复在线
+	TypeDeleted        = "deleted"         // 网关被删除
+	TypeAddressChanged = "address_changed" // 网关地址变更
+	TypeFlapping       = "flapping"        // 状态频繁变化, 之后暂停状态告警
+)
+
+// AllTypes ...
+var AllTypes = []string{
+	TypeOffline,
+	TypeOnline,
+	TypeDeleted,
+	TypeAddressChanged,
+	TypeFlapping,
+}
+
+// TypeAll 接收全部告警
+const TypeAll = "*"
+
+// CategoryPrefix 站内通知的category为 alert.<type>
+const CategoryPrefix = "alert."
+
+const processedTTL = 24 * time.Hour
+
+var titles = map[string]string{
+	TypeOffline:        "网关离线",
+	TypeOnline:         "网关恢复在线",
+	TypeDeleted:        "网关已删除",
+	TypeAddressChanged: "网关地址变更",
+	TypeFlapping:       "网关状态频繁变化",
+}
+
+// Init 注册outbox订阅者, 将网关状态变化等事件转为关注者的告警
+func Init() {
+	event.Subscribe("alert", handle)
+}
+
+// Classify 事件对应的告警类型, 不需要告警时返回空
+func Classify(evt *event.Event) string {
+	switch evt.Type {
+	case event.TypeEdgexStatusChanged:
+		status, _ := evt.Data["status"].(float64)
+		if int32(status) == dal.EdgexActive {
+			return TypeOnline
+		}
+		return TypeOffline
+	case event.TypeEdgexDeleted:
+		return TypeDeleted
+	case event.TypeEdgexUpdated:
+		changes, _ := evt.Data["changes"].(map[string]interface{})
+		previous, _ := evt.Data["previous"].(map[string]interface{})
+		if address, ok := changes["address"]; ok && address != previous["address"] {
+			return TypeAddressChanged
+		}
+	}
+	return ""
+}
+
+func handle(evt *event.Event) (err error) {
+	if !config.AlertConf.Enabled {
+		return nil
+	}
+	alertType := Classify(evt)
+	if alertType == "" {
+		return nil
+	}
+
+	// relay超时重新抢占时可能重复分发, 已处理过的事件直接跳过
+	processedKey := fmt.Sprintf("edgex_admin:alert:event:%d", evt.ID)
+	first, redisErr := caller.RedisClient.SetNX(context.Background(), processedKey, time.Now().Unix(), processedTTL).Result()
+	if redisErr == nil && !first {
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			caller.RedisClient.Del(context.Background(), processedKey)
+		}
+	}()
+
+	alertType, rollback := suppress(evt.EdgexID, alertType)
+	if alertType == "" {
+		logs.Info("[alert-handle] alert suppressed: event_id=%d, edgex_id=%d", evt.ID, evt.EdgexID)
+		return nil
+	}
+	// 发送失败时撤销抑制记录, relay重试时才能重新发送
+	if err = notifyFollowers(evt, alertType); err != nil {
+		rollback()
+	}
+	return err
+}
+
+func notifyFollowers(evt *event.Event, alertType string) error {
+	// 操作人不需要收到自己操作的告警
+	audience := &notify.Audience{Followers: evt.EdgexID, Exclude: []int64{evt.UserID}}
+	userList, err := audience.Resolve()
+	if err != nil || len(userList) == 0 {
+		return err
+	}
+	userIDs := make([]int64, 0, len(userList))
+	for _, user := range userList {
+		userIDs = append(userIDs, user.ID)
+	}
+	prefMap, err := dal.GetNotifyPrefMapByUserIDs(userIDs)
+	if err != nil {
+		return err
+	}
+
+	title, content := render(evt, alertType)
+	inboxUserIDs := make([]int64, 0)
+	mailTo := make([]string, 0)
+	for _, user := range userList {
+		pref := GetPref(prefMap, user.ID)
+		if !Match(pref, alertType) {
+			continue
+		}
+		if pref.InboxEnabled {
+			inboxUserIDs = append(inboxUserIDs, user.ID)
+		}
+		if pref.EmailEnabled && user.Email != "" {
+			mailTo = append(mailTo, user.Email)
+		}
+	}
+	err = notify.SendToUsers(caller.EdgexDB, inboxUserIDs, &notify.Message{
+		Category: CategoryPrefix + alertType,
+		Title:    title,
+		Content:  content,
+		EdgexID:  evt.EdgexID,
+		EventID:  evt.ID,
+	})
+	if err != nil {
+		return err
+	}
+	// 邮件异步发送, 不阻塞事件分发
+	if len(mailTo) > 0 {
+		go sendMails(mailTo, title, content)
+	}
+	return nil
+}
+
+func render(evt *event.Event, alertType string) (title string, content string) {
+	name, _ := evt.Data["edgex_name"].(string)
+	prefix, _ := evt.Data["prefix"].(string)
+	address, _ := evt.Data["address"].(string)
+	title = fmt.Sprintf("[%s] %s(%s)", titles[alertType], name, prefix)
+
+	lines := []string{
+		fmt.Sprintf("网关: %s(%s)", name, prefix),
+		fmt.Sprintf("时间: %s", time.Unix(evt.OccurredAt, 0).Format("2006-01-02 15:04:05")),
+	}
+	switch alertType {
+	case TypeOffline:
+		lines = append(lines, fmt.Sprintf("地址: %s", address))
+		if errMsg, _ := evt.Data["error"].(string); errMsg != "" {
+			lines = append(lines, fmt.Sprintf("错误: %s", errMsg))
+		}
+	case TypeAddressChanged:
+		previous, _ := evt.Data["previous"].(map[string]interface{})
+		lines = append(lines, fmt.Sprintf("地址: %v -> %s", previous["address"], address))
+	case TypeFlapping:
+		lines = append(lines, fmt.Sprintf("%d秒内状态变化超过%d次, 窗口结束前不再发送状态告警",
+			config.AlertConf.FlapWindow, config.AlertConf.FlapThreshold))
+	}
+	return title, strings.Join(lines, "\n")
+}
+
+func sendMails(mailTo []string, title string, content string) {
+	defer utils.RecoverPanic()
+
+	body := "<p>" + strings.Join(strings.Split(content, "\n"), "</p><p>") + "</p>"
+	// 逐个发送, 避免暴露其他关注者的邮箱
+	for _, addr := range mailTo {
+		msg := &mail.Message{To: []string{addr}, Subject: title, HTML: body, Text: content}
+		if err := mail.SendAsync(msg); err != nil {
+			logs.Error("[alert-sendMails] enqueue mail failed: mailTo=%v, err=%v", addr, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package alert
+
+import (
+	"strings"
+
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+// DefaultPref 未设置偏好的用户接收全部告警的邮件和站内通知
+func DefaultPref(userID int64) *dal.EdgexNotifyPref {
+	return &dal.EdgexNotifyPref{
+		UserID:       userID,
+		EmailEnabled: true,
+		InboxEnabled: true,
+		Alerts:       TypeAll,
+	}
+}
+
+// GetPref ...
+func GetPref(prefMap map[int64]*dal.EdgexNotifyPref, userID int64) *dal.EdgexNotifyPref {
+	if pref, ok := prefMap[userID]; ok {
+		return pref
+	}
+	return DefaultPref(userID)
+}
+
+// ParseAlerts 校验并规范化告警类型, 空表示不接收任何告警
+func ParseAlerts(raw string) ([]string, bool) {
+	alertList := make([]string, 0)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if item == TypeAll {
+			return []string{TypeAll}, true
+		}
+		if !utils.InStringSlice(item, AllTypes) {
+			return nil, false
+		}
+		if !utils.InStringSlice(item, alertList) {
+			alertList = append(alertList, item)
+		}
+	}
+	return alertList, true
+}
+
+// Match 偏好中是否包含alertType
+func Match(pref *dal.EdgexNotifyPref, alertType string) bool {
+	for _, item := range strings.Split(pref.Alerts, ",") {
+		if item == TypeAll || item == alertType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+)
+
+// statusScript 状态与上次发送的相同时返回nil, 否则记录本次状态并返回上次的状态(没有时为空串)
+var statusScript = redis.NewScript(`
+local prev = redis.call('GET', KEYS[1])
+if prev == ARGV[1] then
+	return nil
+end
+redis.call('SET', KEYS[1], ARGV[1], 'EX', ARGV[2])
+return prev or ''
+`)
+
+// suppress 返回实际要发送的告警类型, 为空表示不发送; redis不可用时不做抑制
+//   - 状态告警在FlapWindow内超过FlapThreshold次时视为抖动, 只发送一次flapping, 之后到窗口结束都不再发送
+//   - 状态告警Debounce秒内不重复发送与上次相同的状态, 状态变化(如离线->在线->离线)时照常发送
+//   - 其他告警同一网关同类Debounce秒内只发送一次
+//
+// 发送失败时需调用rollback撤销本次记录, 以免relay重试时被抑制
+func suppress(edgexID int64, alertType string) (string, func()) {
+	ctx := context.Background()
+	rollbacks := make([]func(), 0, 2)
+	rollback := func() {
+		for _, f := range rollbacks {
+			f()
+		}
+	}
+
+	isStatus := alertType == TypeOffline || alertType == TypeOnline
+	if isStatus {
+		flapping, first, undo := checkFlap(ctx, edgexID)
+		if undo != nil {
+			rollbacks = append(rollbacks, undo)
+		}
+		if flapping && !first {
+			return "", rollback
+		}
+		if flapping {
+			alertType = TypeFlapping
+			isStatus = false
+		}
+	}
+
+	if config.AlertConf.Debounce <= 0 {
+		return alertType, rollback
+	}
+	ttl := time.Duration(config.AlertConf.Debounce) * time.Second
+	if isStatus {
+		key := fmt.Sprintf("edgex_admin:alert:debounce:%d:status", edgexID)
+		prev, err := statusScript.Run(ctx, caller.RedisClient, []string{key}, alertType, int64(ttl.Seconds())).Text()
+		if err == redis.Nil {
+			return "", rollback
+		}
+		if err == nil {
+			rollbacks = append(rollbacks, func() {
+				if prev == "" {
+					caller.RedisClient.Del(ctx, key)
+					return
+				}
+				caller.RedisClient.Set(ctx, key, prev, ttl)
+			})
+		}
+		return alertType, rollback
+	}
+
+	key := fmt.Sprintf("edgex_admin:alert:debounce:%d:%s", edgexID, alertType)
+	ok, err := caller.RedisClient.SetNX(ctx, key, time.Now().Unix(), ttl).Result()
+	if err == nil && !ok {
+		return "", rollback
+	}
+	if err == nil {
+		rollbacks = append(rollbacks, func() {
+			caller.RedisClient.Del(ctx, key)
+		})
+	}
+	return alertType, rollback
+}
+
+// checkFlap 统计窗口内的状态变化次数, first表示本次刚进入抖动; undo撤销本次计数
+func checkFlap(ctx context.Context, edgexID int64) (flapping bool, first bool, undo func()) {
+	if config.AlertConf.FlapWindow <= 0 || config.AlertConf.FlapThreshold <= 0 {
+		return false, false, nil
+	}
+	key := fmt.Sprintf("edgex_admin:alert:flap:%d", edgexID)
+	count, err := caller.RedisClient.Incr(ctx, key).Result()
+	if err != nil {
+		return false, false, nil
+	}
+	if count == 1 {
+		caller.RedisClient.Expire(ctx, key, time.Duration(config.AlertConf.FlapWindow)*time.Second)
+	}
+	undo = func() {
+		caller.RedisClient.Decr(ctx, key)
+	}
+	threshold := int64(config.AlertConf.FlapThreshold)
+	return count > threshold, count == threshold+1, undo
+}
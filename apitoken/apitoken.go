@@ -0,0 +1,209 @@
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+// token权限
+const (
+	ScopeRead  = "read"  // ScopeRead GET请求, 如搜索和查看
+	ScopeWrite = "write" // ScopeWrite 其余请求, 包含read
+	ScopeAdmin = "admin" // ScopeAdmin 管理员接口, 包含write, 还要求所属用户为管理员
+)
+
+const (
+	tokenPrefix = "eat_"
+	tokenBytes  = 32
+	prefixLen   = len(tokenPrefix) + 8 // 保存的明文前缀长度, 用于识别token
+
+	defaultMaxPerUser     = 20
+	defaultDefaultTTLDays = 90
+	defaultMaxTTLDays     = 365
+	touchInterval         = time.Minute // 最近使用时间的更新间隔, 避免每个请求都写库
+)
+
+var (
+	// ErrInvalidToken token不存在、已过期或所属用户已删除
+	ErrInvalidToken = errors.New("api token is invalid")
+	// ErrInvalidScope 未知的权限
+	ErrInvalidScope = errors.New("api token scope is invalid")
+)
+
+var validScopes = map[string]bool{
+	ScopeRead:  true,
+	ScopeWrite: true,
+	ScopeAdmin: true,
+}
+
+// Generate 返回token明文、用于识别的前缀和保存的哈希
+func Generate() (token string, prefix string, hash string, err error) {
+	buf := make([]byte, tokenBytes)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	token = tokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, token[:prefixLen], Hash(token), nil
+}
+
+// Hash token为高熵随机串, 使用sha256即可, 便于按哈希直接查询
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseScopes 逗号分隔, 去重排序后返回; 为空时默认只读
+func ParseScopes(raw string) ([]string, error) {
+	set := make(map[string]bool)
+	for _, scope := range strings.Split(raw, ",") {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" {
+			continue
+		}
+		if !validScopes[scope] {
+			return nil, ErrInvalidScope
+		}
+		set[scope] = true
+	}
+	if len(set) == 0 {
+		set[ScopeRead] = true
+	}
+	scopes := make([]string, 0, len(set))
+	for scope := range set {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+// impliedScopes 权限包含的其他权限: admin包含write和read, write包含read;
+// 只有admin的token也能通过按请求方法的检查, 访问管理员的GET接口
+var impliedScopes = map[string][]string{
+	ScopeAdmin: {ScopeWrite, ScopeRead},
+	ScopeWrite: {ScopeRead},
+}
+
+// HasScope scopes为逗号分隔的权限
+func HasScope(scopes string, want string) bool {
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope == want {
+			return true
+		}
+		for _, implied := range impliedScopes[scope] {
+			if implied == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MethodScope 请求方法所需的权限
+func MethodScope(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ScopeRead
+	}
+	return ScopeWrite
+}
+
+// Authenticate 校验token并返回所属用户, 同时记录最近使用时间和ip
+func Authenticate(token string, ip string) (*dal.EdgexAPIToken, *dal.EdgexUser, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, nil, ErrInvalidToken
+	}
+	item, err := dal.GetAPITokenByHash(Hash(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if item == nil || item.ExpireTime.Before(now) {
+		return nil, nil, ErrInvalidToken
+	}
+	user, err := dal.GetEdgexUserByID(item.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || user.Deleted != 0 {
+		return nil, nil, ErrInvalidToken
+	}
+	if item.LastUsedTime == nil || now.Sub(*item.LastUsedTime) >= touchInterval || item.LastUsedIP != ip {
+		if err = dal.TouchAPIToken(item.ID, ip, now); err != nil {
+			// 不影响本次请求
+			logs.Warn("[Authenticate] touch api token failed: id=%v, err=%v", item.ID, err)
+		}
+	}
+	return item, user, nil
+}
+
+// Create 超过个数上限时返回ok为false; ttlDays为0时使用默认有效期
+func Create(userID int64, name string, scopes []string, ttlDays int) (item *dal.EdgexAPIToken, token string, ok bool, err error) {
+	count, err := dal.CountAPITokens(userID)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if count >= int64(MaxPerUser()) {
+		return nil, "", false, nil
+	}
+	token, prefix, hash, err := Generate()
+	if err != nil {
+		return nil, "", false, err
+	}
+	if ttlDays <= 0 {
+		ttlDays = DefaultTTLDays()
+	}
+	now := time.Now()
+	item = &dal.EdgexAPIToken{
+		UserID:       userID,
+		Name:         name,
+		TokenPrefix:  prefix,
+		TokenHash:    hash,
+		Scopes:       strings.Join(scopes, ","),
+		ExpireTime:   now.AddDate(0, 0, ttlDays),
+		CreatedTime:  now,
+		ModifiedTime: now,
+	}
+	if err = dal.AddAPIToken(caller.EdgexDB, item); err != nil {
+		return nil, "", false, err
+	}
+	return item, token, true, nil
+}
+
+// MaxPerUser ...
+func MaxPerUser() int {
+	if config.APITokenConf == nil || config.APITokenConf.MaxPerUser <= 0 {
+		return defaultMaxPerUser
+	}
+	return config.APITokenConf.MaxPerUser
+}
+
+// DefaultTTLDays ...
+func DefaultTTLDays() int {
+	if config.APITokenConf == nil || config.APITokenConf.DefaultTTLDays <= 0 {
+		return defaultDefaultTTLDays
+	}
+	if days := config.APITokenConf.DefaultTTLDays; days < MaxTTLDays() {
+		return days
+	}
+	return MaxTTLDays()
+}
+
+// MaxTTLDays ...
+func MaxTTLDays() int {
+	if config.APITokenConf == nil || config.APITokenConf.MaxTTLDays <= 0 {
+		return defaultMaxTTLDays
+	}
+	return config.APITokenConf.MaxTTLDays
+}
@@ -0,0 +1,110 @@
+package attribute
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 支持的比较操作符
+const (
+	OpEq  = "="
+	OpNe  = "!="
+	OpGt  = ">"
+	OpGte = ">="
+	OpLt  = "<"
+	OpLte = "<="
+)
+
+// Filter extra属性过滤条件, e.g. extra.vendor = "Dell"
+type Filter struct {
+	Path  []string
+	Op    string
+	Value interface{} // string, float64 or bool
+}
+
+// JSONPath MySQL JSON_EXTRACT使用的路径, e.g. $."vendor"
+func (f *Filter) JSONPath() string {
+	quoted := make([]string, 0, len(f.Path))
+	for _, key := range f.Path {
+		quoted = append(quoted, strconv.Quote(key))
+	}
+	return "$." + strings.Join(quoted, ".")
+}
+
+var filterClauseRegexp = regexp.MustCompile(`^extra((?:\.[A-Za-z0-9_]+)+)\s*(!=|>=|<=|=|>|<)\s*(.+)$`)
+
+// ParseFilters 解析以AND连接的过滤条件, e.g. extra.vendor = "Dell" AND extra.cores >= 8
+func ParseFilters(raw string) ([]*Filter, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	filterList := make([]*Filter, 0)
+	for _, clause := range splitClauses(raw) {
+		clause = strings.TrimSpace(clause)
+		match := filterClauseRegexp.FindStringSubmatch(clause)
+		if match == nil {
+			return nil, fmt.Errorf("filter is invalid: %s", clause)
+		}
+		value, err := parseFilterValue(strings.TrimSpace(match[3]))
+		if err != nil {
+			return nil, fmt.Errorf("filter is invalid: %s: %v", clause, err)
+		}
+		filter := &Filter{
+			Path:  strings.Split(strings.TrimPrefix(match[1], "."), "."),
+			Op:    match[2],
+			Value: value,
+		}
+		if _, ok := filter.Value.(float64); !ok && filter.Op != OpEq && filter.Op != OpNe {
+			return nil, fmt.Errorf("filter is invalid: %s: operator %s requires a number", clause, filter.Op)
+		}
+		filterList = append(filterList, filter)
+	}
+	return filterList, nil
+}
+
+func parseFilterValue(raw string) (interface{}, error) {
+	if strings.HasPrefix(raw, `"`) || strings.HasPrefix(raw, `'`) {
+		if len(raw) < 2 || raw[len(raw)-1] != raw[0] {
+			return nil, fmt.Errorf("unterminated string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	}
+	switch raw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("value must be a quoted string, number or boolean: %s", raw)
+}
+
+// splitClauses 按AND(不区分大小写)切分, 忽略引号内的AND
+func splitClauses(raw string) []string {
+	clauseList := make([]string, 0)
+	var (
+		quote byte
+		start int
+	)
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ' ' && i+5 <= len(raw) && strings.EqualFold(raw[i:i+5], " and "):
+			clauseList = append(clauseList, raw[start:i])
+			start = i + 5
+			i += 4
+		}
+	}
+	return append(clauseList, raw[start:])
+}
@@ -0,0 +1,260 @@
+package attribute
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 支持的JSON Schema类型
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+)
+
+// Schema JSON Schema的子集: type, enum, required, properties, items及数值/长度范围
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// ValidationError 单个字段的校验错误
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors ...
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgList := make([]string, 0, len(e))
+	for _, item := range e {
+		msgList = append(msgList, fmt.Sprintf("%s: %s", item.Path, item.Message))
+	}
+	return strings.Join(msgList, "; ")
+}
+
+// ParseSchema 解析并检查schema定义本身是否合法
+func ParseSchema(raw string) (*Schema, error) {
+	s := &Schema{}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(s); err != nil {
+		return nil, fmt.Errorf("schema is invalid: %v", err)
+	}
+	if s.Type == "" {
+		s.Type = TypeObject
+	}
+	if s.Type != TypeObject {
+		return nil, fmt.Errorf("schema is invalid: root type must be object, got %s", s.Type)
+	}
+	if err := s.check("$"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) check(path string) error {
+	switch s.Type {
+	case "", TypeObject, TypeArray, TypeString, TypeNumber, TypeInteger, TypeBoolean:
+	default:
+		return fmt.Errorf("schema is invalid: %s: unsupported type %s", path, s.Type)
+	}
+	if s.Minimum != nil && s.Maximum != nil && *s.Minimum > *s.Maximum {
+		return fmt.Errorf("schema is invalid: %s: minimum > maximum", path)
+	}
+	if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+		for _, name := range s.Required {
+			if _, ok := s.Properties[name]; !ok {
+				return fmt.Errorf("schema is invalid: %s: required property %s is not defined", path, name)
+			}
+		}
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return fmt.Errorf("schema is invalid: %s.%s: empty property", path, name)
+		}
+		if err := prop.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+// ValidateExtra 校验EdgexServiceItem.Extra, 空字符串视为空对象
+func (s *Schema) ValidateExtra(extra string) error {
+	if strings.TrimSpace(extra) == "" {
+		extra = "{}"
+	}
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(extra))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return ValidationErrors{{Path: "$", Message: "extra is not valid json"}}
+	}
+	errList := make(ValidationErrors, 0)
+	s.validate("$", value, &errList)
+	if len(errList) > 0 {
+		return errList
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, value interface{}, errList *ValidationErrors) {
+	addErr := func(format string, args ...interface{}) {
+		*errList = append(*errList, &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Type != "" && !matchType(s.Type, value) {
+		addErr("expected %s, got %s", s.Type, typeOf(value))
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(value, s.Enum) {
+		addErr("value must be one of %s", marshal(s.Enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errList = append(*errList, &ValidationError{Path: path + "." + name, Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*errList = append(*errList, &ValidationError{Path: path + "." + name, Message: "is not allowed"})
+				}
+				continue
+			}
+			prop.validate(path+"."+name, v[name], errList)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			addErr("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			addErr("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errList)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			addErr("length must be >= %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			addErr("length must be <= %d", *s.MaxLength)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			addErr("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			addErr("must be <= %v", *s.Maximum)
+		}
+	}
+}
+
+func matchType(typ string, value interface{}) bool {
+	switch typ {
+	case TypeObject:
+		_, ok := value.(map[string]interface{})
+		return ok
+	case TypeArray:
+		_, ok := value.([]interface{})
+		return ok
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case TypeNumber:
+		_, ok := value.(json.Number)
+		return ok
+	case TypeInteger:
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return false
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return TypeObject
+	case []interface{}:
+		return TypeArray
+	case string:
+		return TypeString
+	case bool:
+		return TypeBoolean
+	case json.Number:
+		return TypeNumber
+	case nil:
+		return "null"
+	}
+	return "unknown"
+}
+
+func inEnum(value interface{}, enum []interface{}) bool {
+	raw := marshal(value)
+	for _, item := range enum {
+		if raw == marshal(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// marshal 统一数值表示, 保证json.Number与float64比较一致
+func marshal(value interface{}) string {
+	if n, ok := value.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			value = f
+		}
+	}
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	return strings.TrimSpace(buf.String())
+}
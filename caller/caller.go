@@ -3,6 +3,7 @@ package caller
 import (
 	"fmt"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/tdycwym/edgex_admin/config"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -12,21 +13,23 @@ import (
 var (
 	// EdgexDB ...
 	EdgexDB *gorm.DB
+	// RedisClient ...
+	RedisClient *redis.Client
 )
 
 func InitClient() {
-	// initRedisClient()
+	initRedisClient()
 	initMysqlClient()
 }
 
-// func initRedisClient() {
-// 	redisOpt := &redis.Options{
-// 		Addr:     config.RedisConf.Address,
-// 		Password: config.RedisConf.Password,
-// 		DB:       config.RedisConf.DB,
-// 	}
-// 	RedisClient = redis.NewClient(redisOpt)
-// }
+func initRedisClient() {
+	redisOpt := &redis.Options{
+		Addr:     config.RedisConf.Address,
+		Password: config.RedisConf.Password,
+		DB:       config.RedisConf.DB,
+	}
+	RedisClient = redis.NewClient(redisOpt)
+}
 
 func initMysqlClient() {
 
@@ -0,0 +1,12 @@
+package caller
+
+import (
+	"context"
+	"time"
+)
+
+// TryLock 基于redis SETNX的简单互斥, 用于多实例部署时只由一个实例执行定时任务;
+// 锁到期自动释放, 不支持续期
+func TryLock(key string, ttl time.Duration) (bool, error) {
+	return RedisClient.SetNX(context.Background(), "edgex_admin:lock:"+key, time.Now().Unix(), ttl).Result()
+}
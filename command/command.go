@@ -0,0 +1,43 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Command 命令行子命令, e.g. ./edgex_admin -conf=config/app.ini reconcile-relation
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+var commands = make(map[string]*Command)
+
+func register(cmd *Command) {
+	commands[cmd.Name] = cmd
+}
+
+// Run 执行指定的子命令
+func Run(name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command: %s\n%s", name, Usage())
+	}
+	return cmd.Run(args)
+}
+
+// Usage ...
+func Usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	usage := "commands:\n"
+	for _, name := range names {
+		usage += fmt.Sprintf("  %-24s %s\n", name, commands[name].Usage)
+	}
+	return usage
+}
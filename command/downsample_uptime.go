@@ -0,0 +1,25 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/uptime"
+)
+
+func init() {
+	register(&Command{
+		Name:  "downsample-uptime",
+		Usage: "aggregate expired probe results into hourly rows and purge old uptime data",
+		Run:   downsampleUptime,
+	})
+}
+
+func downsampleUptime(args []string) error {
+	start := time.Now()
+	if err := uptime.Downsample(start); err != nil {
+		return err
+	}
+	fmt.Printf("downsample finished: cost=%v\n", time.Since(start))
+	return nil
+}
@@ -0,0 +1,45 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/tdycwym/edgex_admin/dal"
+)
+
+func init() {
+	register(&Command{
+		Name:  "drop-plaintext-answers",
+		Usage: "clear and drop the legacy plaintext edgex_user.entrypted column [-dry-run]",
+		Run:   dropPlaintextAnswers,
+	})
+}
+
+// dropPlaintextAnswers 旧版密保答案以明文保存在edgex_user.entrypted, 新版不再读取, 升级后清除;
+// 旧答案无法对应到新的问题目录, 用户需重新设置密保
+func dropPlaintextAnswers(args []string) error {
+	fs := flag.NewFlagSet("drop-plaintext-answers", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only count users with plaintext answers, do not update")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	exist, count, err := dal.CountLegacyAnswers()
+	if err != nil {
+		return err
+	}
+	if !exist {
+		fmt.Println("column entrypted does not exist, nothing to do")
+		return nil
+	}
+	if *dryRun {
+		fmt.Printf("plaintext=%d dry_run=true\n", count)
+		return nil
+	}
+	cleared, err := dal.DropLegacyAnswers()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("plaintext=%d cleared=%d dropped=true\n", count, cleared)
+	return nil
+}
@@ -0,0 +1,119 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+const reconcileBatchSize = 200
+
+func init() {
+	register(&Command{
+		Name:  "reconcile-relation",
+		Usage: "repair stale edgex_name/username copies in edgex_related_user [-dry-run]",
+		Run:   reconcileRelation,
+	})
+}
+
+// relationDrift 一条关注记录与源数据不一致的情况
+type relationDrift struct {
+	RelationID  int64
+	EdgexID     int64
+	UserID      int64
+	FieldsMap   map[string]interface{}
+	OldEdgex    string
+	OldUsername string
+}
+
+func reconcileRelation(args []string) error {
+	fs := flag.NewFlagSet("reconcile-relation", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report drift, do not repair")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var (
+		lastID  int64
+		scanned int
+		fixed   int
+		failed  int
+	)
+	for {
+		itemList, err := dal.ScanEdgexRelatedUser(lastID, reconcileBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(itemList) == 0 {
+			break
+		}
+		lastID = itemList[len(itemList)-1].ID
+		scanned += len(itemList)
+
+		driftList, err := findRelationDrift(itemList)
+		if err != nil {
+			return err
+		}
+		for _, drift := range driftList {
+			fmt.Printf("relation_id=%d edgex_id=%d user_id=%d old_edgex_name=%q old_username=%q fix=%v\n",
+				drift.RelationID, drift.EdgexID, drift.UserID, drift.OldEdgex, drift.OldUsername, drift.FieldsMap)
+			if *dryRun {
+				continue
+			}
+			if err := dal.UpdateEdgexRelatedUser(caller.EdgexDB, drift.RelationID, drift.FieldsMap); err != nil {
+				failed++
+				continue
+			}
+			fixed++
+		}
+	}
+
+	logs.Info("[reconcileRelation] done: scanned=%d, fixed=%d, failed=%d, dryRun=%v", scanned, fixed, failed, *dryRun)
+	fmt.Printf("scanned=%d fixed=%d failed=%d dry_run=%v\n", scanned, fixed, failed, *dryRun)
+	if failed > 0 {
+		return fmt.Errorf("%d relations failed to repair", failed)
+	}
+	return nil
+}
+
+func findRelationDrift(itemList []*dal.EdgexRelatedUser) (driftList []*relationDrift, err error) {
+	edgexIDs := make([]int64, 0, len(itemList))
+	userIDs := make([]int64, 0, len(itemList))
+	for _, item := range itemList {
+		edgexIDs = append(edgexIDs, item.EdgexID)
+		userIDs = append(userIDs, item.UserID)
+	}
+	edgexMap, err := dal.GetEdgexMapByIDs(edgexIDs)
+	if err != nil {
+		return
+	}
+	userMap, err := dal.GetEdgexUserMapByIDs(userIDs)
+	if err != nil {
+		return
+	}
+
+	for _, item := range itemList {
+		fieldsMap := make(map[string]interface{})
+		if edgex, ok := edgexMap[item.EdgexID]; ok && edgex.EdgexName != item.EdgexName {
+			fieldsMap["edgex_name"] = edgex.EdgexName
+		}
+		if user, ok := userMap[item.UserID]; ok && user.Username != item.Username {
+			fieldsMap["username"] = user.Username
+		}
+		if len(fieldsMap) == 0 {
+			continue
+		}
+		driftList = append(driftList, &relationDrift{
+			RelationID:  item.ID,
+			EdgexID:     item.EdgexID,
+			UserID:      item.UserID,
+			FieldsMap:   fieldsMap,
+			OldEdgex:    item.EdgexName,
+			OldUsername: item.Username,
+		})
+	}
+	return
+}
@@ -0,0 +1,28 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/tdycwym/edgex_admin/credential"
+	"github.com/tdycwym/edgex_admin/mfa"
+)
+
+func init() {
+	register(&Command{
+		Name:  "rotate-credential-key",
+		Usage: "re-encrypt edgex credentials and 2FA secrets with the current [Credential] MasterKey",
+		Run:   rotateCredentialKey,
+	})
+}
+
+// rotateCredentialKey 轮换步骤: 新密钥写入MasterKey、原密钥移入OldMasterKeys并重启, 执行本命令成功后再移除旧密钥
+func rotateCredentialKey(args []string) error {
+	rotated, err := credential.Rotate()
+	fmt.Printf("rotate credentials finished: rotated=%d\n", rotated)
+	if err != nil {
+		return err
+	}
+	rotated, err = mfa.Rotate()
+	fmt.Printf("rotate 2fa secrets finished: rotated=%d\n", rotated)
+	return err
+}
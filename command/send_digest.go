@@ -0,0 +1,26 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/digest"
+)
+
+func init() {
+	register(&Command{
+		Name:  "send-digest",
+		Usage: "send the weekly digest of the past 7 days to subscribed users now",
+		Run:   sendDigest,
+	})
+}
+
+func sendDigest(args []string) error {
+	start := time.Now()
+	sent, err := digest.SendAll(start)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("send digest finished: sent=%d, cost=%v\n", sent, time.Since(start))
+	return nil
+}
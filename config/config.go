@@ -13,10 +13,28 @@ const (
 )
 
 var (
-	Server    *Service
-	DBConf    *Database
-	RedisConf *RedisConfig
-	LogConf   *LogConfig
+	Server         *Service
+	DBConf         *Database
+	RedisConf      *RedisConfig
+	LogConf        *LogConfig
+	ProbeConf      *ProbeConfig
+	HookConf       *WebhookConfig
+	StreamConf     *StreamConfig
+	UptimeConf     *UptimeConfig
+	SDConf         *DiscoveryConfig
+	MetricsConf    *MetricsConfig
+	ConsulConf     *ConsulConfig
+	CredConf       *CredentialConfig
+	GatewayConf    *GatewayConfig
+	StatsConf      *StatsConfig
+	AlertConf      *AlertConfig
+	DigestConf     *DigestConfig
+	PasswordConf   *PasswordConfig
+	VerifyCodeConf *VerifyCodeConfig
+	MailConf       *MailConfig
+	SMSConf        *SMSConfig
+	MFAConf        *MFAConfig
+	APITokenConf   *APITokenConfig
 )
 
 type LogConfig struct {
@@ -27,6 +45,144 @@ type LogConfig struct {
 	MaxAge     int
 	Compress   bool
 }
+type ProbeConfig struct {
+	Timeout           int  // 单步探测超时 单位：ms
+	RefuseUnreachable bool // 创建/修改地址时拒绝探测失败的地址
+}
+
+type WebhookConfig struct {
+	Timeout     int // 单次投递超时 单位：ms
+	MaxAttempts int // 最大投递次数
+	BaseBackoff int // 首次重试间隔 单位：s, 之后指数增长
+	MaxBackoff  int // 最大重试间隔 单位：s
+}
+
+type StreamConfig struct {
+	Channel           string // 跨实例广播事件的redis频道
+	HeartbeatInterval int    // 心跳间隔 单位：s
+	ReplayLimit       int    // 断线重连时最多补发的事件数
+	BufferSize        int    // 单个连接的发送缓冲, 满时断开慢连接
+}
+
+type UptimeConfig struct {
+	Enabled             bool // 是否定时探测全部edgex
+	Interval            int  // 探测间隔 单位：s
+	Concurrency         int  // 并发探测数
+	RawRetentionDays    int  // 原始探测结果保留天数, 之后按小时聚合
+	HourlyRetentionDays int  // 小时聚合数据与不可达区间保留天数
+}
+
+type DiscoveryConfig struct {
+	Token string // Prometheus http_sd_configs的Bearer token, 为空时关闭服务发现接口
+}
+
+type MetricsConfig struct {
+	Token string // /metrics的Bearer token, 为空时关闭该接口
+}
+
+type ConsulConfig struct {
+	Port          int    // edgex网关上consul的端口
+	Timeout       int    // 请求超时 单位：ms
+	Token         string // ACL token, 可为空
+	KVPrefix      string // edgex服务配置在KV中的根路径
+	PreviewExpire int    // 变更预览的有效期 单位：s
+}
+
+type CredentialConfig struct {
+	MasterKey     string // 加密edgex凭证的AES-256密钥, base64编码的32字节; 为空时不能保存凭证
+	OldMasterKeys string // 轮换前的密钥, 逗号分隔, 仅用于解密, 执行rotate-credential-key后可移除
+}
+
+type GatewayConfig struct {
+	MaxIdleConnsPerHost int // 每个edgex保持的空闲连接数
+	IdleConnTimeout     int // 空闲连接超时 单位：s
+	CertWarnDays        int // 证书在多少天内过期时列入预警
+}
+
+type StatsConfig struct {
+	CacheTTL int // 统计结果在redis中的缓存时间 单位：s, <=0时不缓存
+	Months   int // 按创建月份统计最近几个月
+	TopN     int // 关注最多、最近修改及创建人排行的数量
+}
+
+type AlertConfig struct {
+	Enabled       bool // 是否在关注的网关离线/恢复/删除/地址变更时通知关注者
+	Debounce      int  // 同一网关同类告警的最小间隔 单位：s
+	FlapWindow    int  // 状态抖动检测窗口 单位：s
+	FlapThreshold int  // 窗口内状态变化超过该次数时视为抖动, 窗口结束前不再通知
+}
+
+type DigestConfig struct {
+	Enabled bool   // 是否发送每周摘要邮件
+	Weekday int    // 每周几发送, 0-周日
+	Hour    int    // 几点发送, 0-23
+	BaseURL string // 邮件中退订链接的前缀, e.g. http://127.0.0.1:8080
+	Secret  string // 退订链接的签名密钥, 为空时不发送摘要
+}
+
+type PasswordConfig struct {
+	Algorithm         string // bcrypt/argon2id, 修改后已有密码在下次登录时重新计算
+	BcryptCost        int    // bcrypt的cost, 4-31
+	Argon2Time        int    // argon2id迭代次数
+	Argon2Memory      int    // argon2id内存 单位：KiB
+	Argon2Threads     int    // argon2id并行度
+	ResetTokenTTL     int    // 重置密码链接的有效期 单位：s
+	ResetURL          string // 重置密码页面, 邮件中的链接为 ResetURL?token=xxx
+	QuestionCount     int    // 每个用户需要设置的密保问题个数
+	AnswerMaxAttempts int    // 密保答案最多错误次数, 超过后锁定
+	AnswerLockTime    int    // 密保答案错误的锁定时间 单位：s
+}
+
+type VerifyCodeConfig struct {
+	TTL              int // 验证码有效期 单位：s
+	Length           int // 验证码位数, 4-10
+	MaxAttempts      int // 最多错误次数, 达到后验证码作废
+	SendInterval     int // 同一邮箱/手机号两次发送的最小间隔 单位：s
+	TargetDailyLimit int // 同一邮箱/手机号每天最多发送次数
+	IPHourlyLimit    int // 同一ip每小时最多发送次数
+}
+
+type MailConfig struct {
+	Driver        string // smtp/file/log, file和log不实际发送, 用于开发和测试
+	Host          string // SMTP服务器
+	Port          int    // SMTP端口, 465使用SSL
+	Username      string
+	Password      string // qq、163等邮箱填授权码
+	From          string // 发件人地址, 为空时使用Username
+	FromName      string // 发件人别名
+	Dir           string // file方式下邮件的保存目录
+	Lang          string // 默认语言 zh/en
+	QueueSize     int    // 异步发送队列长度
+	Workers       int    // 异步发送协程数
+	MaxRetries    int    // 发送失败的重试次数, -1表示不重试
+	RetryInterval int    // 首次重试间隔 单位：s, 之后指数增长
+}
+
+type SMSConfig struct {
+	Provider       string // tencent/fake, fake不实际发送, 用于开发和测试
+	SecretID       string // 腾讯云API密钥
+	SecretKey      string
+	Region         string // 地域, 默认ap-guangzhou
+	AppID          string // 短信应用SdkAppId
+	Sign           string // 短信签名
+	CodeTemplateID string // 验证码模板id, 模板变量依次为验证码和有效分钟数
+	Timeout        int    // 请求超时 单位：s
+}
+
+type MFAConfig struct {
+	Issuer        string // 认证应用中显示的发行方
+	PreAuthTTL    int    // 登录第二步的有效期 单位：s
+	MaxAttempts   int    // 验证码最多错误次数, 超过后锁定
+	LockTime      int    // 验证码错误的锁定时间 单位：s
+	RecoveryCodes int    // 恢复码个数
+}
+
+type APITokenConfig struct {
+	MaxPerUser     int // 每个用户最多持有的token个数
+	DefaultTTLDays int // 未指定有效期时的默认值 单位：天
+	MaxTTLDays     int // 有效期上限 单位：天
+}
+
 type RedisConfig struct {
 	Address  string
 	Password string
@@ -64,10 +220,46 @@ func LoadConfig(confFilePath string) {
 	DBConf = new(Database)
 	RedisConf = new(RedisConfig)
 	Server = new(Service)
+	ProbeConf = new(ProbeConfig)
+	HookConf = new(WebhookConfig)
+	StreamConf = new(StreamConfig)
+	UptimeConf = new(UptimeConfig)
+	SDConf = new(DiscoveryConfig)
+	MetricsConf = new(MetricsConfig)
+	ConsulConf = new(ConsulConfig)
+	CredConf = new(CredentialConfig)
+	GatewayConf = new(GatewayConfig)
+	StatsConf = new(StatsConfig)
+	AlertConf = new(AlertConfig)
+	DigestConf = new(DigestConfig)
+	PasswordConf = new(PasswordConfig)
+	VerifyCodeConf = new(VerifyCodeConfig)
+	MailConf = new(MailConfig)
+	SMSConf = new(SMSConfig)
+	MFAConf = new(MFAConfig)
+	APITokenConf = new(APITokenConfig)
 	mapTo("Log", LogConf, cfg)
 	mapTo("Database", DBConf, cfg)
 	mapTo("Redis", RedisConf, cfg)
 	mapTo("Server", Server, cfg)
+	mapTo("Probe", ProbeConf, cfg)
+	mapTo("Webhook", HookConf, cfg)
+	mapTo("Stream", StreamConf, cfg)
+	mapTo("Uptime", UptimeConf, cfg)
+	mapTo("Discovery", SDConf, cfg)
+	mapTo("Metrics", MetricsConf, cfg)
+	mapTo("Consul", ConsulConf, cfg)
+	mapTo("Credential", CredConf, cfg)
+	mapTo("Gateway", GatewayConf, cfg)
+	mapTo("Stats", StatsConf, cfg)
+	mapTo("Alert", AlertConf, cfg)
+	mapTo("Digest", DigestConf, cfg)
+	mapTo("Password", PasswordConf, cfg)
+	mapTo("VerifyCode", VerifyCodeConf, cfg)
+	mapTo("Mail", MailConf, cfg)
+	mapTo("SMS", SMSConf, cfg)
+	mapTo("MFA", MFAConf, cfg)
+	mapTo("APIToken", APITokenConf, cfg)
 
 	if Server.HTTPPort != 0 {
 		Server.Port = fmt.Sprintf(":%d", Server.HTTPPort)
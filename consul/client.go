@@ -0,0 +1,161 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout  = 5 * time.Second
+	maxResponseSize = 16 << 20
+)
+
+// KVPair Consul KV条目, Value已做base64解码
+type KVPair struct {
+	Key         string `json:"Key"`
+	Value       string `json:"-"`
+	RawValue    []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// Client Consul KV HTTP API的最小实现
+type Client struct {
+	BaseURL string // e.g. http://106.15.79.230:8500
+	Token   string // ACL token, 可为空
+	Client  *http.Client
+}
+
+// NewClient timeout<=0时使用默认超时
+func NewClient(baseURL string, token string, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		Client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// List 递归列出prefix下的全部key, prefix不存在时返回空
+func (c *Client) List(ctx context.Context, prefix string) ([]*KVPair, error) {
+	body, status, err := c.do(ctx, http.MethodGet, prefix, url.Values{"recurse": {"true"}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return []*KVPair{}, nil
+	}
+	return decodePairs(body)
+}
+
+// Keys 列出prefix下一级的key与目录(以/结尾), prefix不存在时返回空
+func (c *Client) Keys(ctx context.Context, prefix string) ([]string, error) {
+	body, status, err := c.do(ctx, http.MethodGet, prefix, url.Values{"keys": {""}, "separator": {"/"}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	if status == http.StatusNotFound {
+		return keys, nil
+	}
+	if err = json.Unmarshal(body, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Get key不存在时返回nil
+func (c *Client) Get(ctx context.Context, key string) (*KVPair, error) {
+	body, status, err := c.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	pairs, err := decodePairs(body)
+	if err != nil || len(pairs) == 0 {
+		return nil, err
+	}
+	return pairs[0], nil
+}
+
+// Put cas为key当前的ModifyIndex, 0表示仅当key不存在时写入; 返回false表示key已被其他人修改
+func (c *Client) Put(ctx context.Context, key string, value string, cas uint64) (bool, error) {
+	query := url.Values{"cas": {strconv.FormatUint(cas, 10)}}
+	body, _, err := c.do(ctx, http.MethodPut, key, query, []byte(value))
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(body)) == "true", nil
+}
+
+// Delete cas语义同Put
+func (c *Client) Delete(ctx context.Context, key string, cas uint64) (bool, error) {
+	query := url.Values{"cas": {strconv.FormatUint(cas, 10)}}
+	body, _, err := c.do(ctx, http.MethodDelete, key, query, nil)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(body)) == "true", nil
+}
+
+// do KV接口, 404原样返回状态码, 其他非2xx状态视为错误
+func (c *Client) do(ctx context.Context, method string, key string, query url.Values, payload []byte) ([]byte, int, error) {
+	body, status, err := c.request(ctx, method, "/v1/kv/"+strings.TrimLeft(key, "/"), query, payload)
+	if err != nil {
+		return nil, status, err
+	}
+	if status != http.StatusNotFound && status/100 != 2 {
+		return nil, status, fmt.Errorf("consul %s %s: status=%d, body=%s", method, key, status, strings.TrimSpace(string(body)))
+	}
+	return body, status, nil
+}
+
+func (c *Client) request(ctx context.Context, method string, path string, query url.Values, payload []byte) ([]byte, int, error) {
+	reqURL := c.BaseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, reqURL, reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+
+	rsp, err := c.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(rsp.Body, maxResponseSize))
+	return body, rsp.StatusCode, err
+}
+
+func decodePairs(body []byte) ([]*KVPair, error) {
+	pairs := make([]*KVPair, 0)
+	if err := json.Unmarshal(body, &pairs); err != nil {
+		return nil, err
+	}
+	for _, pair := range pairs {
+		// Consul对JSON中的[]byte做base64编码, json.Unmarshal已自动解码
+		pair.Value = string(pair.RawValue)
+	}
+	return pairs, nil
+}
@@ -0,0 +1,143 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// 变更类型
+const (
+	OpAdd    = "add"
+	OpModify = "modify"
+	OpDelete = "delete"
+)
+
+// Edit 用户提交的一项修改, Delete为true时忽略Value
+type Edit struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Delete bool   `json:"delete"`
+}
+
+// Change 预览得到的一项变更, ModifyIndex为预览时key的版本, 用于应用时CAS
+type Change struct {
+	Key         string `json:"key"`
+	Op          string `json:"op"`
+	OldValue    string `json:"old_value"`
+	NewValue    string `json:"new_value"`
+	ModifyIndex uint64 `json:"modify_index"`
+}
+
+// Diff 对比当前KV与修改, 值未变化的修改被忽略; 结果按key排序
+func Diff(current []*KVPair, edits []*Edit) ([]*Change, error) {
+	currentMap := make(map[string]*KVPair, len(current))
+	for _, pair := range current {
+		currentMap[pair.Key] = pair
+	}
+
+	changeMap := make(map[string]*Change)
+	seen := make(map[string]bool, len(edits))
+	for _, edit := range edits {
+		key := strings.Trim(edit.Key, "/")
+		if key == "" {
+			return nil, fmt.Errorf("key is empty")
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key: %s", key)
+		}
+		seen[key] = true
+
+		pair, exist := currentMap[key]
+		switch {
+		case edit.Delete && !exist:
+			return nil, fmt.Errorf("key not found: %s", key)
+		case edit.Delete:
+			changeMap[key] = &Change{Key: key, Op: OpDelete, OldValue: pair.Value, ModifyIndex: pair.ModifyIndex}
+		case !exist:
+			changeMap[key] = &Change{Key: key, Op: OpAdd, NewValue: edit.Value}
+		case pair.Value != edit.Value:
+			changeMap[key] = &Change{Key: key, Op: OpModify, OldValue: pair.Value, NewValue: edit.Value, ModifyIndex: pair.ModifyIndex}
+		}
+	}
+
+	changes := make([]*Change, 0, len(changeMap))
+	for _, change := range changeMap {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+// MaxTxnOps Consul单个事务最多包含的操作数
+const MaxTxnOps = 64
+
+type txnOp struct {
+	KV *txnKVOp `json:"KV"`
+}
+
+type txnKVOp struct {
+	Verb  string `json:"Verb"`
+	Key   string `json:"Key"`
+	Value []byte `json:"Value,omitempty"`
+	Index uint64 `json:"Index"`
+}
+
+type txnResponse struct {
+	Errors []struct {
+		OpIndex int    `json:"OpIndex"`
+		What    string `json:"What"`
+	} `json:"Errors"`
+}
+
+// Apply 通过/v1/txn原子地应用全部变更, 每项以预览时的ModifyIndex做CAS;
+// 任一key在预览后被修改时整体回滚并返回ConflictError
+func (c *Client) Apply(ctx context.Context, changes []*Change) error {
+	if len(changes) > MaxTxnOps {
+		return fmt.Errorf("too many changes: %d > %d", len(changes), MaxTxnOps)
+	}
+	ops := make([]*txnOp, 0, len(changes))
+	for _, change := range changes {
+		op := &txnKVOp{Verb: "cas", Key: change.Key, Value: []byte(change.NewValue), Index: change.ModifyIndex}
+		if change.Op == OpDelete {
+			op = &txnKVOp{Verb: "delete-cas", Key: change.Key, Index: change.ModifyIndex}
+		}
+		ops = append(ops, &txnOp{KV: op})
+	}
+	payload, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+
+	body, status, err := c.request(ctx, http.MethodPut, "/v1/txn", nil, payload)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusConflict {
+		rsp := &txnResponse{}
+		_ = json.Unmarshal(body, rsp)
+		conflict := &ConflictError{}
+		if len(rsp.Errors) > 0 && rsp.Errors[0].OpIndex < len(changes) {
+			conflict.Key = changes[rsp.Errors[0].OpIndex].Key
+			conflict.What = rsp.Errors[0].What
+		}
+		return conflict
+	}
+	if status/100 != 2 {
+		return fmt.Errorf("consul txn: status=%d, body=%s", status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// ConflictError key在预览之后被其他人修改
+type ConflictError struct {
+	Key  string
+	What string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("consul txn rolled back: key=%s, err=%s", e.Key, e.What)
+}
@@ -0,0 +1,348 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeConsul 内存中的/v1/kv与/v1/txn, 按Consul的CAS语义实现
+type fakeConsul struct {
+	*httptest.Server
+	mu    sync.Mutex
+	index uint64
+	kv    map[string]*KVPair
+	token string
+	txns  int
+}
+
+func newFakeConsul(t *testing.T, token string) *fakeConsul {
+	f := &fakeConsul{kv: make(map[string]*KVPair), token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/kv/", f.handleKV)
+	mux.HandleFunc("/v1/txn", f.handleTxn)
+	f.Server = httptest.NewServer(f.auth(mux))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeConsul) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.token != "" && r.Header.Get("X-Consul-Token") != f.token {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// set 绕过CAS直接写入, 模拟其他人的修改
+func (f *fakeConsul) set(key string, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.index++
+	f.kv[key] = &KVPair{Key: key, Value: value, ModifyIndex: f.index}
+}
+
+func (f *fakeConsul) value(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pair, ok := f.kv[key]
+	if !ok {
+		return "", false
+	}
+	return pair.Value, true
+}
+
+// casOK cas为0时要求key不存在, 否则要求ModifyIndex一致
+func (f *fakeConsul) casOK(key string, cas uint64) bool {
+	pair, ok := f.kv[key]
+	if cas == 0 {
+		return !ok
+	}
+	return ok && pair.ModifyIndex == cas
+}
+
+func (f *fakeConsul) handleKV(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		pairs := make([]*KVPair, 0)
+		for k, pair := range f.kv {
+			if k == key || (query.Get("recurse") != "" && strings.HasPrefix(k, key)) {
+				pairs = append(pairs, &KVPair{Key: k, RawValue: []byte(pair.Value), ModifyIndex: pair.ModifyIndex})
+			}
+		}
+		if len(pairs) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+		json.NewEncoder(w).Encode(pairs)
+	case http.MethodPut, http.MethodDelete:
+		if raw := query.Get("cas"); raw != "" {
+			cas, _ := strconv.ParseUint(raw, 10, 64)
+			if !f.casOK(key, cas) {
+				fmt.Fprint(w, "false")
+				return
+			}
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.kv, key)
+		} else {
+			body, _ := ioutil.ReadAll(r.Body)
+			f.index++
+			f.kv[key] = &KVPair{Key: key, Value: string(body), ModifyIndex: f.index}
+		}
+		fmt.Fprint(w, "true")
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeConsul) handleTxn(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.txns++
+	ops := make([]*txnOp, 0)
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 先检查全部CAS, 任一失败时不做任何修改
+	for i, op := range ops {
+		if (op.KV.Verb != "cas" && op.KV.Verb != "delete-cas") || !f.casOK(op.KV.Key, op.KV.Index) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Errors": []map[string]interface{}{{"OpIndex": i, "What": "failed to set key " + op.KV.Key + ", index is stale"}},
+			})
+			return
+		}
+	}
+	for _, op := range ops {
+		if op.KV.Verb == "delete-cas" {
+			delete(f.kv, op.KV.Key)
+			continue
+		}
+		f.index++
+		f.kv[op.KV.Key] = &KVPair{Key: op.KV.Key, Value: string(op.KV.Value), ModifyIndex: f.index}
+	}
+	fmt.Fprint(w, `{"Results":[],"Errors":null}`)
+}
+
+func TestDiff(t *testing.T) {
+	current := []*KVPair{
+		{Key: "edgex/a", Value: "1", ModifyIndex: 10},
+		{Key: "edgex/b", Value: "2", ModifyIndex: 11},
+		{Key: "edgex/c", Value: "3", ModifyIndex: 12},
+	}
+	edits := []*Edit{
+		{Key: "/edgex/d/", Value: "4"},
+		{Key: "edgex/c", Delete: true},
+		{Key: "edgex/b", Value: "2"},
+		{Key: "edgex/a", Value: "one"},
+	}
+	changes, err := Diff(current, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Key: "edgex/a", Op: OpModify, OldValue: "1", NewValue: "one", ModifyIndex: 10},
+		{Key: "edgex/c", Op: OpDelete, OldValue: "3", ModifyIndex: 12},
+		{Key: "edgex/d", Op: OpAdd, NewValue: "4"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d", len(changes), len(want))
+	}
+	for i := range want {
+		if *changes[i] != want[i] {
+			t.Fatalf("change %d = %+v, want %+v", i, *changes[i], want[i])
+		}
+	}
+}
+
+func TestDiffInvalid(t *testing.T) {
+	current := []*KVPair{{Key: "edgex/a", Value: "1", ModifyIndex: 10}}
+	cases := map[string][]*Edit{
+		"empty key":      {{Key: "/", Value: "1"}},
+		"duplicate key":  {{Key: "edgex/a", Value: "2"}, {Key: "/edgex/a", Value: "3"}},
+		"delete missing": {{Key: "edgex/b", Delete: true}},
+	}
+	for name, edits := range cases {
+		if _, err := Diff(current, edits); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestClientKV(t *testing.T) {
+	srv := newFakeConsul(t, "acl-token")
+	ctx := context.Background()
+	client := NewClient(srv.URL+"/", "acl-token", 0)
+
+	ok, err := client.Put(ctx, "edgex/a", "1", 0)
+	if err != nil || !ok {
+		t.Fatalf("put: ok=%v, err=%v", ok, err)
+	}
+	// cas=0时key已存在
+	if ok, err = client.Put(ctx, "edgex/a", "2", 0); err != nil || ok {
+		t.Fatalf("put existing with cas=0: ok=%v, err=%v", ok, err)
+	}
+	pair, err := client.Get(ctx, "edgex/a")
+	if err != nil || pair == nil || pair.Value != "1" {
+		t.Fatalf("get: pair=%+v, err=%v", pair, err)
+	}
+	if ok, err = client.Put(ctx, "edgex/a", "2", pair.ModifyIndex+1); err != nil || ok {
+		t.Fatalf("put with stale index: ok=%v, err=%v", ok, err)
+	}
+	if ok, err = client.Put(ctx, "edgex/a", "2", pair.ModifyIndex); err != nil || !ok {
+		t.Fatalf("put with index: ok=%v, err=%v", ok, err)
+	}
+	if ok, err = client.Delete(ctx, "edgex/a", pair.ModifyIndex); err != nil || ok {
+		t.Fatalf("delete with stale index: ok=%v, err=%v", ok, err)
+	}
+
+	if pair, err = client.Get(ctx, "edgex/missing"); err != nil || pair != nil {
+		t.Fatalf("get missing: pair=%+v, err=%v", pair, err)
+	}
+	pairs, err := client.List(ctx, "missing/")
+	if err != nil || len(pairs) != 0 {
+		t.Fatalf("list missing: pairs=%v, err=%v", pairs, err)
+	}
+
+	if _, err = NewClient(srv.URL, "wrong", 0).Get(ctx, "edgex/a"); err == nil {
+		t.Fatal("expected error with wrong token")
+	}
+}
+
+func TestApply(t *testing.T) {
+	srv := newFakeConsul(t, "")
+	srv.set("edgex/a", "1")
+	srv.set("edgex/b", "2")
+	ctx := context.Background()
+	client := NewClient(srv.URL, "", 0)
+
+	current, err := client.List(ctx, "edgex/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := Diff(current, []*Edit{
+		{Key: "edgex/a", Value: "one"},
+		{Key: "edgex/b", Delete: true},
+		{Key: "edgex/c", Value: "3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Apply(ctx, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := srv.value("edgex/a"); v != "one" {
+		t.Fatalf("edgex/a = %q", v)
+	}
+	if _, ok := srv.value("edgex/b"); ok {
+		t.Fatal("edgex/b should be deleted")
+	}
+	if v, _ := srv.value("edgex/c"); v != "3" {
+		t.Fatalf("edgex/c = %q", v)
+	}
+
+	// 同一预览不能重复应用
+	err = client.Apply(ctx, changes)
+	if _, ok := err.(*ConflictError); !ok {
+		t.Fatalf("reapply: err=%v, want ConflictError", err)
+	}
+}
+
+func TestApplyConflict(t *testing.T) {
+	cases := []struct {
+		name     string
+		edits    []*Edit
+		modify   func(f *fakeConsul)
+		conflict string
+	}{
+		{
+			name:     "modified after preview",
+			edits:    []*Edit{{Key: "edgex/a", Value: "one"}, {Key: "edgex/b", Value: "two"}},
+			modify:   func(f *fakeConsul) { f.set("edgex/b", "changed") },
+			conflict: "edgex/b",
+		},
+		{
+			name:     "added after preview",
+			edits:    []*Edit{{Key: "edgex/a", Value: "one"}, {Key: "edgex/c", Value: "3"}},
+			modify:   func(f *fakeConsul) { f.set("edgex/c", "other") },
+			conflict: "edgex/c",
+		},
+		{
+			name:     "deleted after preview",
+			edits:    []*Edit{{Key: "edgex/b", Delete: true}, {Key: "edgex/c", Value: "3"}},
+			modify:   func(f *fakeConsul) { f.set("edgex/b", "changed") },
+			conflict: "edgex/b",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newFakeConsul(t, "")
+			srv.set("edgex/a", "1")
+			srv.set("edgex/b", "2")
+			ctx := context.Background()
+			client := NewClient(srv.URL, "", 0)
+
+			current, err := client.List(ctx, "edgex/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes, err := Diff(current, tc.edits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.modify(srv)
+			before := make(map[string]string)
+			for _, key := range []string{"edgex/a", "edgex/b", "edgex/c"} {
+				before[key], _ = srv.value(key)
+			}
+
+			err = client.Apply(ctx, changes)
+			conflict, ok := err.(*ConflictError)
+			if !ok {
+				t.Fatalf("err=%v, want ConflictError", err)
+			}
+			if conflict.Key != tc.conflict || conflict.What == "" {
+				t.Fatalf("conflict = %+v, want key %s", conflict, tc.conflict)
+			}
+			// 整体回滚, 其他key也未被修改
+			for key, value := range before {
+				if v, _ := srv.value(key); v != value {
+					t.Fatalf("%s = %q, want %q", key, v, value)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyTooManyChanges(t *testing.T) {
+	srv := newFakeConsul(t, "")
+	changes := make([]*Change, 0, MaxTxnOps+1)
+	for i := 0; i <= MaxTxnOps; i++ {
+		changes = append(changes, &Change{Key: fmt.Sprintf("edgex/%d", i), Op: OpAdd, NewValue: "1"})
+	}
+	if err := NewClient(srv.URL, "", 0).Apply(context.Background(), changes); err == nil {
+		t.Fatal("expected error")
+	}
+	if srv.txns != 0 {
+		t.Fatalf("txn should not be sent, got %d", srv.txns)
+	}
+}
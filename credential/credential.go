@@ -0,0 +1,225 @@
+package credential
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+)
+
+// 凭证类型
+const (
+	TypeToken = "token" // Authorization: Bearer, e.g. EdgeX API网关签发的JWT
+	TypeBasic = "basic" // Authorization: Basic
+	TypeCert  = "cert"  // 仅TLS客户端证书
+)
+
+const rotateBatchSize = 200
+
+// Credential 访问edgex网关的凭证, 加密后保存; 不要直接序列化到响应中
+type Credential struct {
+	Type        string `json:"type"`
+	Token       string `json:"token,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	CertPEM     string `json:"cert_pem,omitempty"`
+	KeyPEM      string `json:"key_pem,omitempty"`
+	ConsulToken string `json:"consul_token,omitempty"` // 网关上consul的ACL token, 为空时使用[Consul] Token
+}
+
+// String 日志中只输出类型, 防止凭证通过%v/%+v泄露
+func (c *Credential) String() string {
+	if c == nil {
+		return "credential(nil)"
+	}
+	return fmt.Sprintf("credential(type=%s)", c.Type)
+}
+
+// GoString 同String, 覆盖%#v
+func (c *Credential) GoString() string {
+	return c.String()
+}
+
+// Validate 校验对应类型的必填项; 任何类型都可以附带客户端证书, 证书需与私钥匹配
+func (c *Credential) Validate() error {
+	switch c.Type {
+	case TypeToken:
+		if strings.TrimSpace(c.Token) == "" {
+			return fmt.Errorf("token is required")
+		}
+	case TypeBasic:
+		if c.Username == "" || strings.Contains(c.Username, ":") {
+			return fmt.Errorf("username is required and must not contain ':'")
+		}
+	case TypeCert:
+		if c.CertPEM == "" {
+			return fmt.Errorf("cert_pem is required")
+		}
+	default:
+		return fmt.Errorf("unsupported credential type: %s", c.Type)
+	}
+	if c.CertPEM != "" || c.KeyPEM != "" {
+		if _, err := c.Certificate(); err != nil {
+			// 错误信息不含证书内容
+			return fmt.Errorf("cert_pem/key_pem is invalid: %v", err)
+		}
+	}
+	return nil
+}
+
+// Certificate 客户端证书, Leaf已解析
+func (c *Credential) Certificate() (tls.Certificate, error) {
+	cert, err := tls.X509KeyPair([]byte(c.CertPEM), []byte(c.KeyPEM))
+	if err != nil {
+		return cert, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	return cert, err
+}
+
+// Header 需附加到请求上的头, cert类型无
+func (c *Credential) Header() http.Header {
+	header := http.Header{}
+	switch c.Type {
+	case TypeToken:
+		header.Set("Authorization", "Bearer "+strings.TrimSpace(c.Token))
+	case TypeBasic:
+		req := &http.Request{Header: header}
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return header
+}
+
+// Decrypt 解密凭证记录
+func Decrypt(item *dal.EdgexCredential) (*Credential, error) {
+	r, err := getKeyring()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := r.open(additionalData(item.EdgexID), item.KeyID, item.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	cred := &Credential{}
+	if err = json.Unmarshal(plaintext, cred); err != nil {
+		return nil, fmt.Errorf("credential is invalid: edgex_id=%d", item.EdgexID)
+	}
+	return cred, nil
+}
+
+// Load edgex未配置凭证时返回nil
+func Load(edgexID int64) (*Credential, error) {
+	item, err := dal.GetCredentialByEdgexID(edgexID)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	return Decrypt(item)
+}
+
+// Save 使用当前主密钥加密后保存, 覆盖已有凭证
+func Save(db *gorm.DB, edgexID int64, userID int64, cred *Credential) error {
+	r, err := getKeyring()
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+	keyID, ciphertext, err := r.seal(additionalData(edgexID), plaintext)
+	if err != nil {
+		return err
+	}
+	err = dal.SaveCredential(db, &dal.EdgexCredential{
+		EdgexID:    edgexID,
+		CredType:   cred.Type,
+		KeyID:      keyID,
+		Ciphertext: ciphertext,
+		UpdatedBy:  userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 跟踪客户端证书的过期时间
+	if cred.CertPEM == "" {
+		return dal.DeleteEdgexCert(db, edgexID, dal.CertKindClient)
+	}
+	cert, err := cred.Certificate()
+	if err != nil {
+		return err
+	}
+	return dal.SaveEdgexCert(db, &dal.EdgexCert{
+		EdgexID:     edgexID,
+		Kind:        dal.CertKindClient,
+		Subject:     cert.Leaf.Subject.String(),
+		Issuer:      cert.Leaf.Issuer.String(),
+		NotAfter:    cert.Leaf.NotAfter,
+		CheckedTime: time.Now(),
+	})
+}
+
+// Delete 删除凭证及客户端证书的过期跟踪
+func Delete(db *gorm.DB, edgexID int64) error {
+	if err := dal.DeleteCredential(db, edgexID); err != nil {
+		return err
+	}
+	return dal.DeleteEdgexCert(db, edgexID, dal.CertKindClient)
+}
+
+// Rotate 将非当前主密钥加密的凭证用当前主密钥重新加密, 返回重新加密的数量;
+// 单条失败不影响其他凭证, 全部完成后才可以从OldMasterKeys中移除旧密钥
+func Rotate() (rotated int, err error) {
+	r, err := getKeyring()
+	if err != nil {
+		return 0, err
+	}
+
+	var lastID int64
+	failed := 0
+	for {
+		itemList, err := dal.ScanCredentialsNotKey(r.currentID, lastID, rotateBatchSize)
+		if err != nil {
+			return rotated, err
+		}
+		if len(itemList) == 0 {
+			break
+		}
+		for _, item := range itemList {
+			ok, err := rotateOne(r, item)
+			if err != nil {
+				failed++
+				logs.Error("[credential-Rotate] rotate failed: edgex_id=%d, key_id=%s, err=%v", item.EdgexID, item.KeyID, err)
+				continue
+			}
+			if ok {
+				rotated++
+			}
+		}
+		lastID = itemList[len(itemList)-1].ID
+	}
+	if failed > 0 {
+		return rotated, fmt.Errorf("%d credentials failed to rotate", failed)
+	}
+	return rotated, nil
+}
+
+func rotateOne(r *keyring, item *dal.EdgexCredential) (bool, error) {
+	plaintext, err := r.open(additionalData(item.EdgexID), item.KeyID, item.Ciphertext)
+	if err != nil {
+		return false, err
+	}
+	keyID, ciphertext, err := r.seal(additionalData(item.EdgexID), plaintext)
+	if err != nil {
+		return false, err
+	}
+	return dal.UpdateCredentialCipher(caller.EdgexDB, item.ID, item.Ciphertext, keyID, ciphertext)
+}
@@ -0,0 +1,143 @@
+package credential
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tdycwym/edgex_admin/config"
+)
+
+// ErrNoMasterKey 未配置[Credential] MasterKey
+var ErrNoMasterKey = errors.New("credential master key is not configured")
+
+// keyring 当前密钥用于加密, 全部密钥(含轮换前的)按指纹用于解密
+type keyring struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+}
+
+var (
+	ringOnce sync.Once
+	ring     *keyring
+	ringErr  error
+)
+
+func getKeyring() (*keyring, error) {
+	ringOnce.Do(func() {
+		old := make([]string, 0)
+		for _, key := range strings.Split(config.CredConf.OldMasterKeys, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				old = append(old, key)
+			}
+		}
+		ring, ringErr = newKeyring(strings.TrimSpace(config.CredConf.MasterKey), old)
+	})
+	return ring, ringErr
+}
+
+func newKeyring(current string, old []string) (*keyring, error) {
+	if current == "" {
+		return nil, ErrNoMasterKey
+	}
+	r := &keyring{keys: make(map[string]cipher.AEAD)}
+	for i, encoded := range append([]string{current}, old...) {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			// 不输出密钥内容
+			return nil, fmt.Errorf("master key #%d is invalid: want base64 encoded 32 bytes", i)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(key)
+		if i == 0 {
+			r.currentID = id
+		}
+		r.keys[id] = aead
+	}
+	return r, nil
+}
+
+// keyID 密钥指纹, 用于识别密文由哪个密钥加密
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// additionalData 将密文绑定到edgex, 防止密文被挪用到其他edgex
+func additionalData(edgexID int64) []byte {
+	return []byte("edgex_credential:" + strconv.FormatInt(edgexID, 10))
+}
+
+// seal 返回当前密钥指纹与base64(nonce+密文), ad为绑定密文归属的附加数据
+func (r *keyring) seal(ad []byte, plaintext []byte) (string, string, error) {
+	aead := r.keys[r.currentID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, ad)
+	return r.currentID, base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (r *keyring) open(ad []byte, id string, ciphertext string) ([]byte, error) {
+	aead, ok := r.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("master key not found: key_id=%s", id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("ciphertext is invalid: %v", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, ad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt credential failed: key_id=%s", id)
+	}
+	return plaintext, nil
+}
+
+// SealSecret 使用当前主密钥加密其他模块的密钥材料(如TOTP secret), scope将密文绑定到归属对象
+func SealSecret(scope string, plaintext []byte) (keyID string, ciphertext string, err error) {
+	r, err := getKeyring()
+	if err != nil {
+		return "", "", err
+	}
+	return r.seal([]byte(scope), plaintext)
+}
+
+// OpenSecret 解密SealSecret的结果, scope需与加密时一致
+func OpenSecret(scope string, keyID string, ciphertext string) ([]byte, error) {
+	r, err := getKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return r.open([]byte(scope), keyID, ciphertext)
+}
+
+// CurrentKeyID 当前主密钥的指纹, 用于判断密文是否需要轮换
+func CurrentKeyID() (string, error) {
+	r, err := getKeyring()
+	if err != nil {
+		return "", err
+	}
+	return r.currentID, nil
+}
@@ -46,6 +46,7 @@ DROP TABLE IF EXISTS `edgex_service_item`;
 CREATE TABLE `edgex_service_item` (
 	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
 	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '创建者',
+	`org_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '所属组织id, 0-未分配',
 	`edgex_name` varchar(200) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT 'edgex服务名',
 	`prefix` varchar(200) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT 'edgex网关前缀',
 	`status` tinyint NOT NULL DEFAULT '0' COMMENT '状态: 0-inactive 1-active',
@@ -56,11 +57,16 @@ CREATE TABLE `edgex_service_item` (
 	`description` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci COMMENT '描述',
 	`location` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci COMMENT 'edgex服务位置信息',
 	`extra` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci COMMENT '额外信息',
+	`scheme` varchar(10) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '地址不含协议时使用的协议 http/https, 空-http',
+	`tls_ca` text COLLATE utf8mb4_general_ci COMMENT '校验网关证书的CA证书(PEM), 空-使用系统CA',
+	`tls_server_name` varchar(200) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT 'TLS SNI及证书校验使用的域名, 空-取地址中的主机名',
+	`tls_skip_verify` tinyint NOT NULL DEFAULT '0' COMMENT '1-不校验网关证书',
 	PRIMARY KEY (`id`),
 	UNIQUE KEY `idx_prefix` (`prefix`,`deleted`),
 	KEY `idx_created_time` (`created_time`),
 	KEY `idx_modify_time` (`modified_time`),
 	KEY `idx_user_id` (`user_id`),
+	KEY `idx_org_id` (`org_id`),
 	KEY `idx_edgex_name` (`edgex_name`)
 ) ENGINE=InnoDB AUTO_INCREMENT=100005 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='edgex服务表';
 
@@ -70,7 +76,7 @@ CREATE TABLE `edgex_service_item` (
 
 LOCK TABLES `edgex_service_item` WRITE;
 /*!40000 ALTER TABLE `edgex_service_item` DISABLE KEYS */;
-INSERT INTO `edgex_service_item` (`id`, `user_id`, `edgex_name`, `prefix`, `status`, `deleted`, `address`, `created_time`, `modified_time`, `description`, `location`, `extra`) VALUES (100000,654321,'edgex inactive','edgex-inactive',0,0,'106.15.79.230:8080','2021-04-22 10:59:51','2021-04-22 10:59:51','edgex服务创建测试-inactive','{\"province\":\"江苏\",\"city\":\"南京市\"}',''),(100004,123456,'edgex test','edgex-test',1,0,'106.15.79.230:8080','2021-04-22 11:02:50','2021-04-22 11:02:52','edgex服务创建测试','{\"province\":\"江苏\",\"city\":\"南京市\"}','');
+INSERT INTO `edgex_service_item` (`id`, `user_id`, `edgex_name`, `prefix`, `status`, `deleted`, `address`, `created_time`, `modified_time`, `description`, `location`, `extra`, `tls_ca`) VALUES (100000,654321,'edgex inactive','edgex-inactive',0,0,'106.15.79.230:8080','2021-04-22 10:59:51','2021-04-22 10:59:51','edgex服务创建测试-inactive','{\"province\":\"江苏\",\"city\":\"南京市\"}','',''),(100004,123456,'edgex test','edgex-test',1,0,'106.15.79.230:8080','2021-04-22 11:02:50','2021-04-22 11:02:52','edgex服务创建测试','{\"province\":\"江苏\",\"city\":\"南京市\"}','','');
 /*!40000 ALTER TABLE `edgex_service_item` ENABLE KEYS */;
 UNLOCK TABLES;
 
@@ -85,9 +91,368 @@ CREATE TABLE `edgex_user` (
 	`phone_number` varchar (200) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '电话号码',
 	`email` varchar (200) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '邮箱',
 	`deleted` tinyint NOT NULL DEFAULT '0' COMMENT '0-未删除 1-已删除',
-	`entrypted` varchar(255) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '密码保护问题',
+	`role` tinyint NOT NULL DEFAULT '0' COMMENT '角色: 0-普通用户 1-管理员',
 	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     `modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     PRIMARY KEY (`id`),
     UNIQUE KEY `idx_username` (`username`)
-) ENGINE=InnoDB AUTO_INCREMENT=251 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
\ No newline at end of file
+) ENGINE=InnoDB AUTO_INCREMENT=251 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+--
+-- Table structure for table `edgex_attribute_schema`
+--
+
+DROP TABLE IF EXISTS `edgex_attribute_schema`;
+
+CREATE TABLE `edgex_attribute_schema` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`org_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '组织id, 0-全局',
+	`schema` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT 'extra属性的JSON Schema',
+	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '最后修改人',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `idx_org_id` (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='edgex extra属性schema表';
+
+--
+-- Table structure for table `edgex_event_outbox`
+--
+
+DROP TABLE IF EXISTS `edgex_event_outbox`;
+
+CREATE TABLE `edgex_event_outbox` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT COMMENT '事件id',
+	`event_type` varchar(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '事件类型, e.g. edgex.created',
+	`edgex_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'edgex服务id',
+	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '触发事件的用户, 0-系统',
+	`payload` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '事件数据',
+	`status` tinyint NOT NULL DEFAULT '0' COMMENT '0-待分发 1-已分发 2-分发中',
+	`attempts` int NOT NULL DEFAULT '0' COMMENT '分发次数',
+	`delivered` varchar(255) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '已处理成功的订阅者, 逗号分隔, 重试时跳过',
+	`owner` varchar(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '抢占标识',
+	`locked_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '抢占时间',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`dispatched_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '分发完成时间',
+	PRIMARY KEY (`id`),
+	KEY `idx_status` (`status`,`id`),
+	KEY `idx_owner` (`owner`),
+	KEY `idx_edgex_id` (`edgex_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='edgex事件outbox表';
+
+--
+-- Table structure for table `webhook_subscription`
+--
+
+DROP TABLE IF EXISTS `webhook_subscription`;
+
+CREATE TABLE `webhook_subscription` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '创建人',
+	`url` varchar(1024) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '回调地址',
+	`secret` varchar(255) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '签名密钥',
+	`events` varchar(1024) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '*' COMMENT '逗号分隔的事件类型, *-全部',
+	`status` tinyint NOT NULL DEFAULT '1' COMMENT '1-启用 2-停用',
+	`description` varchar(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '描述',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	KEY `idx_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='webhook订阅表';
+
+--
+-- Table structure for table `webhook_delivery`
+--
+
+DROP TABLE IF EXISTS `webhook_delivery`;
+
+CREATE TABLE `webhook_delivery` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT COMMENT '投递id',
+	`subscription_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'webhook订阅id',
+	`event_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'edgex_event_outbox.id',
+	`event_type` varchar(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '事件类型',
+	`payload` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '请求体',
+	`status` tinyint NOT NULL DEFAULT '0' COMMENT '0-待投递 1-成功 2-失败',
+	`attempts` int NOT NULL DEFAULT '0' COMMENT '已尝试次数',
+	`next_attempt_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下次尝试时间',
+	`last_status_code` int NOT NULL DEFAULT '0' COMMENT '最近一次响应状态码',
+	`last_error` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci COMMENT '最近一次错误',
+	`last_response` varchar(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '最近一次响应体',
+	`last_latency_ms` int NOT NULL DEFAULT '0' COMMENT '最近一次耗时',
+	`owner` varchar(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '抢占标识',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `idx_subscription_event` (`subscription_id`,`event_id`),
+	KEY `idx_status_next_attempt` (`status`,`next_attempt_time`),
+	KEY `idx_owner` (`owner`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='webhook投递记录表';
+
+--
+-- Table structure for table `edgex_probe_result`
+--
+
+DROP TABLE IF EXISTS `edgex_probe_result`;
+
+CREATE TABLE `edgex_probe_result` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`edgex_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'edgex服务id',
+	`probe_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '探测时间',
+	`up` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否可达',
+	`latency_ms` int NOT NULL DEFAULT '0' COMMENT '延迟 单位：ms',
+	`error` varchar(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '探测失败原因',
+	PRIMARY KEY (`id`),
+	KEY `idx_edgex_probe_time` (`edgex_id`,`probe_time`),
+	KEY `idx_probe_time` (`probe_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='edgex探测结果表';
+
+--
+-- Table structure for table `edgex_probe_hourly`
+--
+
+DROP TABLE IF EXISTS `edgex_probe_hourly`;
+
+CREATE TABLE `edgex_probe_hourly` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`edgex_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'edgex服务id',
+	`hour` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '整点时间',
+	`samples` int NOT NULL DEFAULT '0' COMMENT '探测次数',
+	`up_samples` int NOT NULL DEFAULT '0' COMMENT '可达次数',
+	`latency_sum` bigint NOT NULL DEFAULT '0' COMMENT '可达样本延迟之和 单位：ms',
+	`latency_min` int NOT NULL DEFAULT '0' COMMENT '最小延迟 单位：ms',
+	`latency_max` int NOT NULL DEFAULT '0' COMMENT '最大延迟 单位：ms',
+	`latency_histo` varchar(1024) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '延迟直方图各桶计数',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `idx_edgex_hour` (`edgex_id`,`hour`),
+	KEY `idx_hour` (`hour`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='edgex探测结果小时聚合表';
+
+--
+-- Table structure for table `edgex_outage`
+--
+
+DROP TABLE IF EXISTS `edgex_outage`;
+
+CREATE TABLE `edgex_outage` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`edgex_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'edgex服务id',
+	`start_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '开始不可达时间',
+	`end_time` timestamp NULL DEFAULT NULL COMMENT '恢复时间, NULL-未恢复',
+	`error` varchar(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '首次探测失败原因',
+	PRIMARY KEY (`id`),
+	KEY `idx_edgex_start_time` (`edgex_id`,`start_time`),
+	KEY `idx_end_time` (`end_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='edgex不可达区间表';
+
+--
+-- Table structure for table `edgex_config_change`
+--
+
+DROP TABLE IF EXISTS `edgex_config_change`;
+
+CREATE TABLE `edgex_config_change` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT COMMENT '变更id',
+	`edgex_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'edgex服务id',
+	`service` varchar(200) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT 'edgex微服务名, e.g. core-data',
+	`changes` mediumtext CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '变更明细, 含旧值与新值',
+	`status` tinyint NOT NULL DEFAULT '0' COMMENT '0-待确认 1-应用中 2-已生效 3-失败 4-已取消',
+	`created_by` bigint unsigned NOT NULL DEFAULT '0' COMMENT '预览人',
+	`applied_by` bigint unsigned NOT NULL DEFAULT '0' COMMENT '确认人',
+	`error` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci COMMENT '失败原因',
+	`expire_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '预览过期时间',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`applied_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '确认时间',
+	PRIMARY KEY (`id`),
+	KEY `idx_edgex_service` (`edgex_id`,`service`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='edgex consul配置变更记录表';
+
+--
+-- Table structure for table `edgex_credential`
+--
+
+DROP TABLE IF EXISTS `edgex_credential`;
+
+CREATE TABLE `edgex_credential` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`edgex_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'edgex服务id',
+	`cred_type` varchar(20) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '凭证类型 token/basic/cert',
+	`key_id` varchar(32) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '加密所用主密钥的指纹',
+	`ciphertext` text COLLATE utf8mb4_general_ci NOT NULL COMMENT 'AES-GCM加密的凭证, base64(nonce+密文)',
+	`updated_by` bigint unsigned NOT NULL DEFAULT '0' COMMENT '最后修改人',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `uniq_edgex_id` (`edgex_id`),
+	KEY `idx_key_id` (`key_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='edgex网关访问凭证表';
+
+--
+-- Table structure for table `edgex_cert`
+--
+
+DROP TABLE IF EXISTS `edgex_cert`;
+
+CREATE TABLE `edgex_cert` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`edgex_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'edgex服务id',
+	`kind` varchar(20) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT 'server-网关证书 client-客户端证书',
+	`subject` varchar(512) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '证书主题',
+	`issuer` varchar(512) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '签发者',
+	`not_after` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '过期时间',
+	`checked_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '最近一次获取证书的时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `uniq_edgex_kind` (`edgex_id`,`kind`),
+	KEY `idx_not_after` (`not_after`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='edgex证书过期跟踪表';
+
+--
+-- Table structure for table `edgex_notification`
+--
+
+DROP TABLE IF EXISTS `edgex_notification`;
+
+CREATE TABLE `edgex_notification` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '接收人',
+	`edgex_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '相关的edgex服务id, 没有时为0',
+	`event_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '触发通知的事件id, 没有时为0',
+	`category` varchar(50) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '通知类型 e.g. alert.offline',
+	`title` varchar(255) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '标题',
+	`content` text COLLATE utf8mb4_general_ci NOT NULL COMMENT '内容',
+	`is_read` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否已读',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	KEY `idx_user_read` (`user_id`,`is_read`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='站内通知表';
+
+--
+-- Table structure for table `edgex_notify_pref`
+--
+
+DROP TABLE IF EXISTS `edgex_notify_pref`;
+
+CREATE TABLE `edgex_notify_pref` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '用户id',
+	`email_enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否接收邮件通知',
+	`inbox_enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否接收站内通知',
+	`alerts` varchar(255) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '*' COMMENT '接收的告警类型, 逗号分隔, *表示全部',
+	`digest_enabled` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否接收每周摘要邮件',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `uniq_user_id` (`user_id`),
+	KEY `idx_digest_enabled` (`digest_enabled`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='用户通知偏好表';
+
+--
+-- Table structure for table `edgex_security_question`
+--
+
+DROP TABLE IF EXISTS `edgex_security_question`;
+
+CREATE TABLE `edgex_security_question` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`content` varchar(255) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '问题',
+	`deleted` tinyint NOT NULL DEFAULT '0' COMMENT '0-未删除 1-已删除',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='密保问题表';
+
+INSERT INTO `edgex_security_question` (`id`, `content`) VALUES (1,'你出生的城市是？'),(2,'你小学的校名是？'),(3,'你母亲的姓名是？'),(4,'你第一只宠物的名字是？'),(5,'你最喜欢的一本书是？');
+
+--
+-- Table structure for table `edgex_security_answer`
+--
+
+DROP TABLE IF EXISTS `edgex_security_answer`;
+
+CREATE TABLE `edgex_security_answer` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '用户id',
+	`question_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '密保问题id',
+	`answer` varchar(255) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '规范化后答案的哈希',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `uniq_user_question` (`user_id`,`question_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='用户密保答案表';
+
+--
+-- Table structure for table `edgex_user_mfa`
+--
+
+DROP TABLE IF EXISTS `edgex_user_mfa`;
+
+CREATE TABLE `edgex_user_mfa` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '用户id',
+	`key_id` varchar(32) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '加密使用的主密钥指纹',
+	`secret` varchar(255) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '加密后的TOTP密钥',
+	`enabled` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否已确认启用',
+	`confirmed_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '确认时间',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `uniq_user_id` (`user_id`),
+	KEY `idx_key_id` (`key_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='用户两步验证表';
+
+--
+-- Table structure for table `edgex_recovery_code`
+--
+
+DROP TABLE IF EXISTS `edgex_recovery_code`;
+
+CREATE TABLE `edgex_recovery_code` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '用户id',
+	`code_hash` char(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '恢复码的sha256',
+	`used` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否已使用',
+	`used_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '使用时间',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `uniq_user_code` (`user_id`,`code_hash`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='两步验证恢复码表';
+
+--
+-- Table structure for table `edgex_mfa_policy`
+--
+
+DROP TABLE IF EXISTS `edgex_mfa_policy`;
+
+CREATE TABLE `edgex_mfa_policy` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`role` tinyint NOT NULL DEFAULT '0' COMMENT '角色: 0-普通用户 1-管理员',
+	`required` tinyint(1) NOT NULL DEFAULT '0' COMMENT '该角色是否必须开启两步验证',
+	`updated_by` bigint unsigned NOT NULL DEFAULT '0' COMMENT '最后修改人',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `uniq_role` (`role`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='角色两步验证策略表';
+
+--
+-- Table structure for table `edgex_api_token`
+--
+
+DROP TABLE IF EXISTS `edgex_api_token`;
+
+CREATE TABLE `edgex_api_token` (
+	`id` bigint unsigned NOT NULL AUTO_INCREMENT,
+	`user_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '所属用户id',
+	`name` varchar(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT 'token名称',
+	`token_prefix` varchar(16) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT 'token明文前缀, 用于识别',
+	`token_hash` char(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT 'token的sha256',
+	`scopes` varchar(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '逗号分隔的权限: read/write/admin',
+	`expire_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '过期时间',
+	`last_used_time` timestamp NULL DEFAULT NULL COMMENT '最近使用时间',
+	`last_used_ip` varchar(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '最近使用的ip',
+	`created_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+	`modified_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `idx_token_hash` (`token_hash`),
+	UNIQUE KEY `idx_user_token_name` (`user_id`,`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT='用户API token表';
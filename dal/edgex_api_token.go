@@ -0,0 +1,115 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+)
+
+// EdgexAPIToken 用户创建的API token, 只保存哈希, 明文仅在创建时返回一次
+type EdgexAPIToken struct {
+	ID           int64      `gorm:"column:id" json:"id"`
+	UserID       int64      `gorm:"column:user_id" json:"user_id"`
+	Name         string     `gorm:"column:name" json:"name"`
+	TokenPrefix  string     `gorm:"column:token_prefix" json:"token_prefix"`
+	TokenHash    string     `gorm:"column:token_hash" json:"-"`
+	Scopes       string     `gorm:"column:scopes" json:"scopes"` // 逗号分隔的权限
+	ExpireTime   time.Time  `gorm:"column:expire_time" json:"expire_time"`
+	LastUsedTime *time.Time `gorm:"column:last_used_time" json:"last_used_time"` // 从未使用时为NULL
+	LastUsedIP   string     `gorm:"column:last_used_ip" json:"last_used_ip"`
+	CreatedTime  time.Time  `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime time.Time  `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// TableName ...
+func (EdgexAPIToken) TableName() string {
+	return "edgex_api_token"
+}
+
+// AddAPIToken 同一用户下name重复时返回DuplicateError
+func AddAPIToken(db *gorm.DB, item *EdgexAPIToken) error {
+	dbRes := db.Debug().Model(&EdgexAPIToken{}).Create(item)
+	if dbRes.Error != nil {
+		err := translateError(dbRes.Error)
+		logs.Error("[AddAPIToken] create api token failed: userID=%v, name=%v, err=%v", item.UserID, item.Name, err)
+		return err
+	}
+	return nil
+}
+
+// GetAPITokenByHash 每个请求都会调用, 不使用Debug(); 不存在时返回nil
+func GetAPITokenByHash(tokenHash string) (item *EdgexAPIToken, err error) {
+	itemList := make([]*EdgexAPIToken, 0)
+	dbRes := caller.EdgexDB.Model(&EdgexAPIToken{}).Where("token_hash = ?", tokenHash).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetAPITokenByHash] get api token failed: err=%v", err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// GetAPITokenList 按创建时间倒序
+func GetAPITokenList(userID int64) (itemList []*EdgexAPIToken, err error) {
+	itemList = make([]*EdgexAPIToken, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexAPIToken{}).Where("user_id = ?", userID).Order("id DESC").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetAPITokenList] get api tokens failed: userID=%v, err=%v", userID, err)
+		return
+	}
+	return
+}
+
+// CountAPITokens 包含已过期的token
+func CountAPITokens(userID int64) (count int64, err error) {
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexAPIToken{}).Where("user_id = ?", userID).Count(&count)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountAPITokens] count api tokens failed: userID=%v, err=%v", userID, err)
+		return
+	}
+	return
+}
+
+// DeleteAPIToken userID为0时不校验所属用户, deleted为false表示token不存在
+func DeleteAPIToken(db *gorm.DB, id int64, userID int64) (deleted bool, err error) {
+	db = db.Debug().Where("id = ?", id)
+	if userID > 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+	dbRes := db.Delete(&EdgexAPIToken{})
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[DeleteAPIToken] delete api token failed: id=%v, userID=%v, err=%v", id, userID, err)
+		return
+	}
+	return dbRes.RowsAffected > 0, nil
+}
+
+// DeleteUserAPITokens 吊销该用户的全部token, 用于重置密码等
+func DeleteUserAPITokens(db *gorm.DB, userID int64) (count int64, err error) {
+	dbRes := db.Debug().Where("user_id = ?", userID).Delete(&EdgexAPIToken{})
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[DeleteUserAPITokens] delete api tokens failed: userID=%v, err=%v", userID, err)
+		return
+	}
+	return dbRes.RowsAffected, nil
+}
+
+// TouchAPIToken 记录最近一次使用
+func TouchAPIToken(id int64, ip string, usedTime time.Time) error {
+	dbRes := caller.EdgexDB.Model(&EdgexAPIToken{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"last_used_time": usedTime, "last_used_ip": ip})
+	if dbRes.Error != nil {
+		logs.Error("[TouchAPIToken] update last used failed: id=%v, err=%v", id, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
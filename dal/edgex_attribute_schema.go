@@ -0,0 +1,81 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+)
+
+// GlobalOrgID org_id为0的schema对所有组织生效
+const GlobalOrgID = 0
+
+// EdgexAttributeSchema extra属性的schema定义, 每个组织至多一条
+type EdgexAttributeSchema struct {
+	ID           int64     `gorm:"column:id" json:"id"`
+	OrgID        int64     `gorm:"column:org_id" json:"org_id"`
+	Schema       string    `gorm:"column:schema" json:"schema"`
+	UserID       int64     `gorm:"column:user_id" json:"user_id"`
+	CreatedTime  time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// GetAttributeSchemaByOrgID ...
+func GetAttributeSchemaByOrgID(orgID int64) (item *EdgexAttributeSchema, err error) {
+	itemList := make([]*EdgexAttributeSchema, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexAttributeSchema{}).Where("org_id = ?", orgID).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetAttributeSchemaByOrgID] get schema failed: orgID=%v, err=%v", orgID, err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// GetEffectiveAttributeSchema 组织schema优先, 不存在时使用全局schema
+func GetEffectiveAttributeSchema(orgID int64) (item *EdgexAttributeSchema, err error) {
+	if orgID != GlobalOrgID {
+		item, err = GetAttributeSchemaByOrgID(orgID)
+		if err != nil || item != nil {
+			return
+		}
+	}
+	return GetAttributeSchemaByOrgID(GlobalOrgID)
+}
+
+// SaveAttributeSchema 按org_id新增或覆盖
+func SaveAttributeSchema(db *gorm.DB, item *EdgexAttributeSchema) error {
+	fieldsMap := map[string]interface{}{
+		"schema":        item.Schema,
+		"user_id":       item.UserID,
+		"modified_time": item.ModifiedTime,
+	}
+	dbRes := db.Debug().Model(&EdgexAttributeSchema{}).Where("org_id = ?", item.OrgID).Updates(fieldsMap)
+	if dbRes.Error != nil {
+		logs.Error("[SaveAttributeSchema] update schema failed: item=%+v, err=%v", item, dbRes.Error)
+		return dbRes.Error
+	}
+	if dbRes.RowsAffected > 0 {
+		return nil
+	}
+	dbRes = db.Debug().Model(&EdgexAttributeSchema{}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[SaveAttributeSchema] create schema failed: item=%+v, err=%v", item, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// DeleteAttributeSchema ...
+func DeleteAttributeSchema(db *gorm.DB, orgID int64) error {
+	dbRes := db.Debug().Where("org_id = ?", orgID).Delete(&EdgexAttributeSchema{})
+	if dbRes.Error != nil {
+		logs.Error("[DeleteAttributeSchema] delete schema failed: orgID=%v, err=%v", orgID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
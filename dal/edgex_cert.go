@@ -0,0 +1,70 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 证书类型
+const (
+	CertKindServer = "server" // 网关的服务端证书, 由探测时的TLS握手获取
+	CertKindClient = "client" // 访问网关的客户端证书, 保存凭证时解析
+)
+
+// EdgexCert 证书过期跟踪, 每个edgex每种证书一行
+type EdgexCert struct {
+	ID          int64     `gorm:"column:id" json:"id"`
+	EdgexID     int64     `gorm:"column:edgex_id" json:"edgex_id"`
+	Kind        string    `gorm:"column:kind" json:"kind"`
+	Subject     string    `gorm:"column:subject" json:"subject"`
+	Issuer      string    `gorm:"column:issuer" json:"issuer"`
+	NotAfter    time.Time `gorm:"column:not_after" json:"not_after"`
+	CheckedTime time.Time `gorm:"column:checked_time" json:"checked_time"`
+}
+
+// SaveEdgexCert 已存在时覆盖
+func SaveEdgexCert(db *gorm.DB, item *EdgexCert) error {
+	dbRes := db.Debug().Model(&EdgexCert{}).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"subject", "issuer", "not_after", "checked_time"}),
+	}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[SaveEdgexCert] save cert failed: edgexID=%v, kind=%v, err=%v", item.EdgexID, item.Kind, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// DeleteEdgexCert kind为空时删除edgex的全部证书记录
+func DeleteEdgexCert(db *gorm.DB, edgexID int64, kind string) error {
+	db = db.Debug().Where("edgex_id = ?", edgexID)
+	if kind != "" {
+		db = db.Where("kind = ?", kind)
+	}
+	dbRes := db.Delete(&EdgexCert{})
+	if dbRes.Error != nil {
+		logs.Error("[DeleteEdgexCert] delete cert failed: edgexID=%v, kind=%v, err=%v", edgexID, kind, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetExpiringCertList 未删除edgex中before之前过期(含已过期)的证书, 按过期时间升序
+func GetExpiringCertList(before time.Time, offset int, count int) (itemList []*EdgexCert, err error) {
+	itemList = make([]*EdgexCert, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexCert{}).Select("edgex_cert.*").
+		Joins("JOIN edgex_service_item ON edgex_service_item.id = edgex_cert.edgex_id AND edgex_service_item.deleted = 0").
+		Where("edgex_cert.not_after < ?", before).
+		Order("edgex_cert.not_after ASC").
+		Offset(offset).Limit(count).
+		Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetExpiringCertList] get certs failed: before=%v, err=%v", before, err)
+		return
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+)
+
+// 配置变更状态
+const (
+	ConfigChangePending   = 0 // ConfigChangePending 已预览, 等待确认
+	ConfigChangeApplying  = 1 // ConfigChangeApplying 已确认, 正在写入consul
+	ConfigChangeApplied   = 2 // ConfigChangeApplied 已生效
+	ConfigChangeFailed    = 3 // ConfigChangeFailed 写入失败或冲突
+	ConfigChangeCancelled = 4 // ConfigChangeCancelled 已取消
+)
+
+// EdgexConfigChange edgex服务在consul中的配置变更记录
+type EdgexConfigChange struct {
+	ID          int64     `gorm:"column:id" json:"id"`
+	EdgexID     int64     `gorm:"column:edgex_id" json:"edgex_id"`
+	Service     string    `gorm:"column:service" json:"service"`
+	Changes     string    `gorm:"column:changes" json:"changes"` // []*consul.Change的JSON
+	Status      int32     `gorm:"column:status" json:"status"`
+	CreatedBy   int64     `gorm:"column:created_by" json:"created_by"`
+	AppliedBy   int64     `gorm:"column:applied_by" json:"applied_by"`
+	Error       string    `gorm:"column:error" json:"error"`
+	ExpireTime  time.Time `gorm:"column:expire_time" json:"expire_time"`
+	CreatedTime time.Time `gorm:"column:created_time" json:"created_time"`
+	AppliedTime time.Time `gorm:"column:applied_time" json:"applied_time"`
+}
+
+// AddConfigChange applied_time使用表默认值
+func AddConfigChange(db *gorm.DB, item *EdgexConfigChange) error {
+	dbRes := db.Debug().Model(&EdgexConfigChange{}).Omit("applied_time").Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[AddConfigChange] create config change failed: edgexID=%v, err=%v", item.EdgexID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetConfigChangeByID ...
+func GetConfigChangeByID(id int64) (item *EdgexConfigChange, err error) {
+	itemList := make([]*EdgexConfigChange, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexConfigChange{}).Where("id = ?", id).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetConfigChangeByID] get config change failed: id=%v, err=%v", id, err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// UpdateConfigChangeStatus 仅当当前状态为from时更新, 防止同一变更被重复确认
+func UpdateConfigChangeStatus(db *gorm.DB, id int64, from int32, fieldsMap map[string]interface{}) (updated bool, err error) {
+	dbRes := db.Debug().Model(&EdgexConfigChange{}).Where("id = ? AND status = ?", id, from).Updates(fieldsMap)
+	if dbRes.Error != nil {
+		logs.Error("[UpdateConfigChangeStatus] update config change failed: id=%v, from=%v, fieldsMap=%+v, err=%v", id, from, fieldsMap, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return dbRes.RowsAffected > 0, nil
+}
+
+// GetConfigChangeList service为空时不过滤, 按id倒序
+func GetConfigChangeList(edgexID int64, service string, offset int, count int) (itemList []*EdgexConfigChange, err error) {
+	itemList = make([]*EdgexConfigChange, 0)
+	db := caller.EdgexDB.Debug().Model(&EdgexConfigChange{}).Where("edgex_id = ?", edgexID)
+	if service != "" {
+		db = db.Where("service = ?", service)
+	}
+	dbRes := db.Order("id DESC").Offset(offset).Limit(count).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetConfigChangeList] get config changes failed: edgexID=%v, service=%v, err=%v", edgexID, service, err)
+		return
+	}
+	return
+}
+
+// CountAppliedConfigChanges edgexIDs在[start, end)内已生效的配置变更数量
+func CountAppliedConfigChanges(edgexIDs []int64, start time.Time, end time.Time) (countMap map[int64]int64, err error) {
+	countMap = make(map[int64]int64)
+	if len(edgexIDs) == 0 {
+		return
+	}
+	itemList := make([]*EdgexCount, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexConfigChange{}).Select("edgex_id, COUNT(*) AS cnt").
+		Where("status = ? AND edgex_id IN (?)", ConfigChangeApplied, edgexIDs).
+		Where("applied_time >= ? AND applied_time < ?", start, end).
+		Group("edgex_id").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountAppliedConfigChanges] count config changes failed: edgexIDs=%v, err=%v", edgexIDs, err)
+		return
+	}
+	for _, item := range itemList {
+		countMap[item.EdgexID] = item.Count
+	}
+	return
+}
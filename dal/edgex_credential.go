@@ -0,0 +1,105 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EdgexCredential edgex网关访问凭证, Ciphertext为加密后的凭证JSON, 由credential包加解密
+// 注意: 读写凭证不使用Debug(), 避免密文写入日志
+type EdgexCredential struct {
+	ID           int64     `gorm:"column:id" json:"id"`
+	EdgexID      int64     `gorm:"column:edgex_id" json:"edgex_id"`
+	CredType     string    `gorm:"column:cred_type" json:"cred_type"`
+	KeyID        string    `gorm:"column:key_id" json:"key_id"`
+	Ciphertext   string    `gorm:"column:ciphertext" json:"-"`
+	UpdatedBy    int64     `gorm:"column:updated_by" json:"updated_by"`
+	CreatedTime  time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// SaveCredential 每个edgex只有一份凭证, 已存在时覆盖
+func SaveCredential(db *gorm.DB, item *EdgexCredential) error {
+	dbRes := db.Model(&EdgexCredential{}).Omit("created_time", "modified_time").Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"cred_type", "key_id", "ciphertext", "updated_by"}),
+	}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[SaveCredential] save credential failed: edgexID=%v, err=%v", item.EdgexID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetCredentialByEdgexID 不存在时返回nil
+func GetCredentialByEdgexID(edgexID int64) (item *EdgexCredential, err error) {
+	itemList := make([]*EdgexCredential, 0)
+	dbRes := caller.EdgexDB.Model(&EdgexCredential{}).Where("edgex_id = ?", edgexID).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetCredentialByEdgexID] get credential failed: edgexID=%v, err=%v", edgexID, err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// GetCredentialMapByEdgexIDs key为edgex_id
+func GetCredentialMapByEdgexIDs(edgexIDs []int64) (itemMap map[int64]*EdgexCredential, err error) {
+	itemMap = make(map[int64]*EdgexCredential)
+	if len(edgexIDs) == 0 {
+		return
+	}
+	itemList := make([]*EdgexCredential, 0)
+	dbRes := caller.EdgexDB.Model(&EdgexCredential{}).Where("edgex_id IN ?", edgexIDs).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetCredentialMapByEdgexIDs] get credentials failed: count=%v, err=%v", len(edgexIDs), err)
+		return
+	}
+	for _, item := range itemList {
+		itemMap[item.EdgexID] = item
+	}
+	return
+}
+
+// ScanCredentialsNotKey 按id分页扫描非keyID加密的凭证, 用于密钥轮换
+func ScanCredentialsNotKey(keyID string, lastID int64, count int) (itemList []*EdgexCredential, err error) {
+	itemList = make([]*EdgexCredential, 0)
+	dbRes := caller.EdgexDB.Model(&EdgexCredential{}).
+		Where("id > ? AND key_id != ?", lastID, keyID).
+		Order("id ASC").Limit(count).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[ScanCredentialsNotKey] scan credentials failed: lastID=%v, err=%v", lastID, err)
+		return
+	}
+	return
+}
+
+// UpdateCredentialCipher 以原密文为条件更新, 轮换期间凭证被修改时跳过; 不改变modified_time
+func UpdateCredentialCipher(db *gorm.DB, id int64, fromCiphertext string, keyID string, ciphertext string) (updated bool, err error) {
+	dbRes := db.Model(&EdgexCredential{}).Where("id = ? AND ciphertext = ?", id, fromCiphertext).
+		Updates(map[string]interface{}{"key_id": keyID, "ciphertext": ciphertext, "modified_time": gorm.Expr("modified_time")})
+	if dbRes.Error != nil {
+		logs.Error("[UpdateCredentialCipher] update credential failed: id=%v, err=%v", id, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return dbRes.RowsAffected > 0, nil
+}
+
+// DeleteCredential ...
+func DeleteCredential(db *gorm.DB, edgexID int64) error {
+	dbRes := db.Where("edgex_id = ?", edgexID).Delete(&EdgexCredential{})
+	if dbRes.Error != nil {
+		logs.Error("[DeleteCredential] delete credential failed: edgexID=%v, err=%v", edgexID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+)
+
+// outbox状态
+const (
+	OutboxStatusPending    = 0 // OutboxStatusPending 待分发
+	OutboxStatusDispatched = 1 // OutboxStatusDispatched 已分发
+	OutboxStatusProcessing = 2 // OutboxStatusProcessing 分发中
+)
+
+// EdgexEventOutbox 与业务变更同事务写入的事件, 由event relay异步分发
+type EdgexEventOutbox struct {
+	ID             int64     `gorm:"column:id" json:"id"`
+	EventType      string    `gorm:"column:event_type" json:"event_type"`
+	EdgexID        int64     `gorm:"column:edgex_id" json:"edgex_id"`
+	UserID         int64     `gorm:"column:user_id" json:"user_id"`
+	Payload        string    `gorm:"column:payload" json:"payload"`
+	Status         int32     `gorm:"column:status" json:"status"`
+	Attempts       int32     `gorm:"column:attempts" json:"attempts"`
+	Delivered      string    `gorm:"column:delivered" json:"delivered"` // 已处理成功的订阅者, 逗号分隔
+	Owner          string    `gorm:"column:owner" json:"owner"`
+	LockedTime     time.Time `gorm:"column:locked_time" json:"locked_time"`
+	CreatedTime    time.Time `gorm:"column:created_time" json:"created_time"`
+	DispatchedTime time.Time `gorm:"column:dispatched_time" json:"dispatched_time"`
+}
+
+// AddEventOutbox locked_time/dispatched_time使用表默认值, 避免写入零值时间
+func AddEventOutbox(db *gorm.DB, item *EdgexEventOutbox) error {
+	dbRes := db.Debug().Model(&EdgexEventOutbox{}).Omit("locked_time", "dispatched_time").Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[AddEventOutbox] create outbox failed: item=%+v, err=%v", item, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// ClaimEventOutbox 抢占待分发(或锁超时)的事件, 多实例部署时保证同一事件只被一个实例处理;
+// owner每次抢占都应唯一
+func ClaimEventOutbox(owner string, lockTimeout time.Duration, count int) (itemList []*EdgexEventOutbox, err error) {
+	itemList = make([]*EdgexEventOutbox, 0)
+	now := time.Now()
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexEventOutbox{}).
+		Where("status = ? OR (status = ? AND locked_time < ?)", OutboxStatusPending, OutboxStatusProcessing, now.Add(-lockTimeout)).
+		Order("id ASC").
+		Limit(count).
+		Updates(map[string]interface{}{"status": OutboxStatusProcessing, "owner": owner, "locked_time": now})
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[ClaimEventOutbox] claim outbox failed: owner=%v, err=%v", owner, err)
+		return
+	}
+	if dbRes.RowsAffected == 0 {
+		return
+	}
+	dbRes = caller.EdgexDB.Debug().Model(&EdgexEventOutbox{}).
+		Where("status = ? AND owner = ?", OutboxStatusProcessing, owner).
+		Order("id ASC").
+		Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[ClaimEventOutbox] get claimed outbox failed: owner=%v, err=%v", owner, err)
+		return
+	}
+	return
+}
+
+// UpdateEventOutbox ...
+func UpdateEventOutbox(id int64, fieldsMap map[string]interface{}) error {
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexEventOutbox{}).Where("id = ?", id).Updates(fieldsMap)
+	if dbRes.Error != nil {
+		logs.Error("[UpdateEventOutbox] update outbox failed: id=%v, fieldsMap=%+v, err=%v", id, fieldsMap, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetEventOutboxAfter id大于lastID的事件, 按id升序, 用于事件流断线补发
+func GetEventOutboxAfter(lastID int64, count int) (itemList []*EdgexEventOutbox, err error) {
+	itemList = make([]*EdgexEventOutbox, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexEventOutbox{}).
+		Where("id > ?", lastID).
+		Order("id ASC").
+		Limit(count).
+		Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetEventOutboxAfter] get outbox failed: lastID=%v, err=%v", lastID, err)
+		return
+	}
+	return
+}
+
+// CountEventsByEdgex edgexIDs在[start, end)内eventType事件的数量
+func CountEventsByEdgex(eventType string, edgexIDs []int64, start time.Time, end time.Time) (countMap map[int64]int64, err error) {
+	countMap = make(map[int64]int64)
+	if len(edgexIDs) == 0 {
+		return
+	}
+	itemList := make([]*EdgexCount, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexEventOutbox{}).Select("edgex_id, COUNT(*) AS cnt").
+		Where("event_type = ? AND edgex_id IN (?)", eventType, edgexIDs).
+		Where("created_time >= ? AND created_time < ?", start, end).
+		Group("edgex_id").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountEventsByEdgex] count events failed: eventType=%v, edgexIDs=%v, err=%v", eventType, edgexIDs, err)
+		return
+	}
+	for _, item := range itemList {
+		countMap[item.EdgexID] = item.Count
+	}
+	return
+}
@@ -0,0 +1,97 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+)
+
+// EdgexNotification 站内通知
+type EdgexNotification struct {
+	ID           int64     `gorm:"column:id" json:"id"`
+	UserID       int64     `gorm:"column:user_id" json:"user_id"`
+	EdgexID      int64     `gorm:"column:edgex_id" json:"edgex_id"`
+	EventID      int64     `gorm:"column:event_id" json:"event_id"`
+	Category     string    `gorm:"column:category" json:"category"`
+	Title        string    `gorm:"column:title" json:"title"`
+	Content      string    `gorm:"column:content" json:"content"`
+	IsRead       bool      `gorm:"column:is_read" json:"is_read"`
+	CreatedTime  time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// AddNotifications 批量写入通知
+func AddNotifications(db *gorm.DB, itemList []*EdgexNotification) error {
+	if len(itemList) == 0 {
+		return nil
+	}
+	dbRes := db.Debug().Model(&EdgexNotification{}).Create(&itemList)
+	if dbRes.Error != nil {
+		logs.Error("[AddNotifications] create notifications failed: count=%v, err=%v", len(itemList), dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetNotificationList 用户的通知, 按id倒序; category为空时不过滤
+func GetNotificationList(userID int64, unreadOnly bool, category string, offset int, count int) (itemList []*EdgexNotification, err error) {
+	itemList = make([]*EdgexNotification, 0)
+	db := caller.EdgexDB.Debug().Model(&EdgexNotification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		db = db.Where("is_read = 0")
+	}
+	if category != "" {
+		db = db.Where("category = ?", category)
+	}
+	dbRes := db.Order("id DESC").Offset(offset).Limit(count).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetNotificationList] get notifications failed: userID=%v, unreadOnly=%v, category=%v, err=%v", userID, unreadOnly, category, err)
+		return
+	}
+	return
+}
+
+// CountNotifications 用户的通知总数和未读数
+func CountNotifications(userID int64) (total int64, unread int64, err error) {
+	result := struct {
+		Total  int64 `gorm:"column:total"`
+		Unread int64 `gorm:"column:unread"`
+	}{}
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexNotification{}).
+		Select("COUNT(*) AS total, COALESCE(SUM(is_read = 0), 0) AS unread").
+		Where("user_id = ?", userID).
+		Scan(&result)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountNotifications] count notifications failed: userID=%v, err=%v", userID, err)
+		return
+	}
+	return result.Total, result.Unread, nil
+}
+
+// MarkNotificationsRead ids为空时标记该用户全部通知, 返回实际更新的条数
+func MarkNotificationsRead(db *gorm.DB, userID int64, ids []int64) (int64, error) {
+	db = db.Debug().Model(&EdgexNotification{}).Where("user_id = ? AND is_read = 0", userID)
+	if len(ids) > 0 {
+		db = db.Where("id IN (?)", ids)
+	}
+	dbRes := db.Updates(map[string]interface{}{"is_read": true, "modified_time": time.Now()})
+	if dbRes.Error != nil {
+		logs.Error("[MarkNotificationsRead] update notifications failed: userID=%v, ids=%v, err=%v", userID, ids, dbRes.Error)
+		return 0, dbRes.Error
+	}
+	return dbRes.RowsAffected, nil
+}
+
+// DeleteNotifications 只删除属于该用户的通知, 返回实际删除的条数
+func DeleteNotifications(db *gorm.DB, userID int64, ids []int64) (int64, error) {
+	dbRes := db.Debug().Where("user_id = ? AND id IN (?)", userID, ids).Delete(&EdgexNotification{})
+	if dbRes.Error != nil {
+		logs.Error("[DeleteNotifications] delete notifications failed: userID=%v, ids=%v, err=%v", userID, ids, dbRes.Error)
+		return 0, dbRes.Error
+	}
+	return dbRes.RowsAffected, nil
+}
@@ -0,0 +1,84 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EdgexNotifyPref 用户的通知偏好, 没有记录时使用默认偏好
+type EdgexNotifyPref struct {
+	ID            int64     `gorm:"column:id" json:"id"`
+	UserID        int64     `gorm:"column:user_id" json:"user_id"`
+	EmailEnabled  bool      `gorm:"column:email_enabled" json:"email_enabled"`
+	InboxEnabled  bool      `gorm:"column:inbox_enabled" json:"inbox_enabled"`
+	Alerts        string    `gorm:"column:alerts" json:"alerts"`                 // 逗号分隔的告警类型, *表示全部
+	DigestEnabled bool      `gorm:"column:digest_enabled" json:"digest_enabled"` // 每周摘要邮件, 需用户主动开启
+	CreatedTime   time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime  time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// SaveNotifyPref 每个用户一条, 已存在时覆盖
+func SaveNotifyPref(db *gorm.DB, item *EdgexNotifyPref) error {
+	dbRes := db.Debug().Model(&EdgexNotifyPref{}).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"email_enabled", "inbox_enabled", "alerts", "digest_enabled", "modified_time"}),
+	}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[SaveNotifyPref] save notify pref failed: item=%+v, err=%v", item, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetNotifyPrefByUserID 不存在时返回nil
+func GetNotifyPrefByUserID(userID int64) (item *EdgexNotifyPref, err error) {
+	itemList := make([]*EdgexNotifyPref, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexNotifyPref{}).Where("user_id = ?", userID).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetNotifyPrefByUserID] get notify pref failed: userID=%v, err=%v", userID, err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// GetNotifyPrefMapByUserIDs key为user_id, 没有设置偏好的用户不在map中
+func GetNotifyPrefMapByUserIDs(userIDs []int64) (prefMap map[int64]*EdgexNotifyPref, err error) {
+	prefMap = make(map[int64]*EdgexNotifyPref)
+	if len(userIDs) == 0 {
+		return
+	}
+	itemList := make([]*EdgexNotifyPref, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexNotifyPref{}).Where("user_id IN (?)", userIDs).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetNotifyPrefMapByUserIDs] get notify pref failed: userIDs=%v, err=%v", userIDs, err)
+		return
+	}
+	for _, item := range itemList {
+		prefMap[item.UserID] = item
+	}
+	return
+}
+
+// ScanDigestPrefs 按id递增分批扫描开启了每周摘要的偏好
+func ScanDigestPrefs(lastID int64, count int) (itemList []*EdgexNotifyPref, err error) {
+	itemList = make([]*EdgexNotifyPref, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexNotifyPref{}).
+		Where("id > ? AND digest_enabled = 1", lastID).
+		Order("id ASC").
+		Limit(count).
+		Find(&itemList)
+	if dbRes.Error != nil {
+		logs.Error("[ScanDigestPrefs] select from database failed: lastID=%v, count=%v, err=%v", lastID, count, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return
+}
@@ -29,7 +29,7 @@ func AddEdgexRelatedUser(db *gorm.DB, item *EdgexRelatedUser) error {
 	dbRes := db.Debug().Model(&EdgexRelatedUser{}).Create(item)
 	if dbRes.Error != nil {
 		logs.Error("[AddEdgexRelatedUser] create AddEdgexRelatedUser record failed: item=%+v, err=%v", item, dbRes.Error)
-		return dbRes.Error
+		return translateError(dbRes.Error)
 	}
 	return nil
 }
@@ -83,3 +83,43 @@ func GetFollowMapByUserID(userID int64) (followMap map[int64]bool, err error) {
 	}
 	return
 }
+
+// UpdateEdgexRelatedUserByEdgexID 更新某个edgex的全部关注记录, 用于同步冗余的edgex_name
+func UpdateEdgexRelatedUserByEdgexID(db *gorm.DB, edgexID int64, fieldsMap map[string]interface{}) error {
+	dbRes := db.Debug().Model(&EdgexRelatedUser{}).Where("edgex_id = ?", edgexID).Updates(fieldsMap)
+	if dbRes.Error != nil {
+		logs.Error("[UpdateEdgexRelatedUserByEdgexID] update EdgexRelatedUser failed: edgex_id=%+v, filedsMap=%+v, err=%v", edgexID, fieldsMap, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// ScanEdgexRelatedUser 按id递增分批扫描关注记录
+func ScanEdgexRelatedUser(lastID int64, count int) (itemList []*EdgexRelatedUser, err error) {
+	itemList = make([]*EdgexRelatedUser, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexRelatedUser{}).
+		Where("id > ?", lastID).
+		Order("id ASC").
+		Limit(count).
+		Find(&itemList)
+	if dbRes.Error != nil {
+		logs.Error("[ScanEdgexRelatedUser] select from database failed: lastID=%v, count=%v, err=%v", lastID, count, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return
+}
+
+// GetFollowerList 关注某个edgex的全部用户
+func GetFollowerList(edgexID int64) (itemList []*EdgexRelatedUser, err error) {
+	itemList = make([]*EdgexRelatedUser, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexRelatedUser{}).
+		Where("edgex_id = ? AND status = ?", edgexID, StatusFollow).
+		Find(&itemList)
+	if dbRes.Error != nil {
+		logs.Error("[GetFollowerList] select from database failed: edgexID=%v, err=%v", edgexID, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return
+}
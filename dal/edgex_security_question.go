@@ -0,0 +1,110 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+)
+
+// EdgexSecurityQuestion 密保问题目录, 由管理员维护
+type EdgexSecurityQuestion struct {
+	ID           int64     `gorm:"column:id" json:"id"`
+	Content      string    `gorm:"column:content" json:"content"`
+	Deleted      int32     `gorm:"column:deleted" json:"deleted"`
+	CreatedTime  time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// EdgexSecurityAnswer 用户设置的密保答案, 只保存规范化后的哈希
+type EdgexSecurityAnswer struct {
+	ID           int64     `gorm:"column:id" json:"id"`
+	UserID       int64     `gorm:"column:user_id" json:"user_id"`
+	QuestionID   int64     `gorm:"column:question_id" json:"question_id"`
+	Answer       string    `gorm:"column:answer" json:"-"`
+	CreatedTime  time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// GetSecurityQuestionList 未删除的全部问题
+func GetSecurityQuestionList() (itemList []*EdgexSecurityQuestion, err error) {
+	itemList = make([]*EdgexSecurityQuestion, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexSecurityQuestion{}).Where("deleted = 0").Order("id ASC").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetSecurityQuestionList] get security question failed: err=%v", err)
+		return
+	}
+	return
+}
+
+// GetSecurityQuestionMapByIDs 包含已删除的问题
+func GetSecurityQuestionMapByIDs(ids []int64) (itemMap map[int64]*EdgexSecurityQuestion, err error) {
+	itemMap = make(map[int64]*EdgexSecurityQuestion)
+	if len(ids) == 0 {
+		return
+	}
+	itemList := make([]*EdgexSecurityQuestion, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexSecurityQuestion{}).Where("id IN (?)", ids).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetSecurityQuestionMapByIDs] get security question failed: ids=%v, err=%v", ids, err)
+		return
+	}
+	for _, item := range itemList {
+		itemMap[item.ID] = item
+	}
+	return
+}
+
+// AddSecurityQuestion ...
+func AddSecurityQuestion(db *gorm.DB, item *EdgexSecurityQuestion) error {
+	dbRes := db.Debug().Model(&EdgexSecurityQuestion{}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[AddSecurityQuestion] add security question failed: item=%+v, err=%v", item, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// UpdateSecurityQuestion 返回是否存在未删除的该问题
+func UpdateSecurityQuestion(db *gorm.DB, id int64, fieldsMap map[string]interface{}) (updated bool, err error) {
+	dbRes := db.Debug().Model(&EdgexSecurityQuestion{}).Where("id = ? AND deleted = 0", id).Updates(fieldsMap)
+	if dbRes.Error != nil {
+		logs.Error("[UpdateSecurityQuestion] update security question failed: id=%v, fieldsMap=%+v, err=%v", id, fieldsMap, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return dbRes.RowsAffected > 0, nil
+}
+
+// GetSecurityAnswerList 用户已设置的答案, 按问题id排序
+func GetSecurityAnswerList(userID int64) (itemList []*EdgexSecurityAnswer, err error) {
+	itemList = make([]*EdgexSecurityAnswer, 0)
+	dbRes := caller.EdgexDB.Model(&EdgexSecurityAnswer{}).Where("user_id = ?", userID).Order("question_id ASC").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetSecurityAnswerList] get security answer failed: userID=%v, err=%v", userID, err)
+		return
+	}
+	return
+}
+
+// ReplaceSecurityAnswers 删除用户原有答案后写入新答案, 需在事务中调用; 不使用Debug(), 避免答案哈希写入日志
+func ReplaceSecurityAnswers(db *gorm.DB, userID int64, itemList []*EdgexSecurityAnswer) error {
+	dbRes := db.Where("user_id = ?", userID).Delete(&EdgexSecurityAnswer{})
+	if dbRes.Error != nil {
+		logs.Error("[ReplaceSecurityAnswers] delete security answer failed: userID=%v, err=%v", userID, dbRes.Error)
+		return dbRes.Error
+	}
+	if len(itemList) == 0 {
+		return nil
+	}
+	dbRes = db.Model(&EdgexSecurityAnswer{}).Create(itemList)
+	if dbRes.Error != nil {
+		logs.Error("[ReplaceSecurityAnswers] add security answer failed: userID=%v, err=%v", userID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
@@ -1,17 +1,26 @@
 package dal
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/tdycwym/edgex_admin/attribute"
 	"github.com/tdycwym/edgex_admin/caller"
 	"github.com/tdycwym/edgex_admin/logs"
 	"gorm.io/gorm"
 )
 
+// edgex服务状态, 由uptime探测维护
+const (
+	EdgexInactive = 0 // EdgexInactive 探测不可达
+	EdgexActive   = 1 // EdgexActive 探测可达
+)
+
 // EdgexServiceItem ...
 type EdgexServiceItem struct {
 	ID           int64     `gorm:"column:id" json:"id"`
 	UserID       int64     `gorm:"column:user_id" json:"user_id"`
+	OrgID        int64     `gorm:"column:org_id" json:"org_id"`
 	EdgexName    string    `gorm:"column:edgex_name" json:"edgex_name"`
 	Prefix       string    `gorm:"column:prefix" json:"prefix"`
 	Status       int32     `gorm:"column:status" json:"status"`
@@ -22,6 +31,11 @@ type EdgexServiceItem struct {
 	Description  string    `gorm:"column:description" json:"description"`
 	Location     string    `gorm:"column:location" json:"location"`
 	Extra        string    `gorm:"column:extra" json:"extra"`
+	// TLS选项, 客户端证书保存在EdgexCredential中
+	Scheme        string `gorm:"column:scheme" json:"scheme"`
+	TLSCA         string `gorm:"column:tls_ca" json:"tls_ca"`
+	TLSServerName string `gorm:"column:tls_server_name" json:"tls_server_name"`
+	TLSSkipVerify bool   `gorm:"column:tls_skip_verify" json:"tls_skip_verify"`
 }
 
 // AddEdgex ...
@@ -29,7 +43,7 @@ func AddEdgex(db *gorm.DB, edgex *EdgexServiceItem) error {
 	dbRes := db.Debug().Model(&EdgexServiceItem{}).Create(edgex)
 	if dbRes.Error != nil {
 		logs.Error("[AddEdgex] create edgex failed: edgex=%+v, err=%v", edgex, dbRes.Error)
-		return dbRes.Error
+		return translateError(dbRes.Error)
 	}
 	return nil
 }
@@ -39,13 +53,28 @@ func UpdateEdgex(db *gorm.DB, edgexID int64, fieldsMap map[string]interface{}) e
 	dbRes := db.Debug().Model(&EdgexServiceItem{}).Where("id = ?", edgexID).Updates(fieldsMap)
 	if dbRes.Error != nil {
 		logs.Error("[UpdateEdgex] update edgex failed: edgexID=%+v, filedsMap=%+v, err=%v", edgexID, fieldsMap, dbRes.Error)
-		return dbRes.Error
+		return translateError(dbRes.Error)
 	}
 	return nil
 }
 
+// GetEdgexByID ...
+func GetEdgexByID(edgexID int64) (edgex *EdgexServiceItem, err error) {
+	edgexList := make([]*EdgexServiceItem, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexServiceItem{}).Where("id = ?", edgexID).Find(&edgexList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetEdgexByID] get edgex failed: edgexID=%v, err=%v", edgexID, err)
+		return
+	}
+	if len(edgexList) > 0 {
+		edgex = edgexList[0]
+	}
+	return
+}
+
 // GetEdgexList ...
-func GetEdgexList(edgexIDs []int64, userIDs []int64, keyword string, status int, offset int, count int) (edgexList []*EdgexServiceItem, err error) {
+func GetEdgexList(edgexIDs []int64, userIDs []int64, keyword string, extraFilters []*attribute.Filter, status int, offset int, count int) (edgexList []*EdgexServiceItem, err error) {
 	edgexList = make([]*EdgexServiceItem, 0)
 
 	db := caller.EdgexDB.Debug().Model(&EdgexServiceItem{}).Where("deleted = 0")
@@ -62,17 +91,35 @@ func GetEdgexList(edgexIDs []int64, userIDs []int64, keyword string, status int,
 		db = db.Where("edgex_name LIKE ? OR prefix LIKE ?", likeKey, likeKey)
 	}
 
+	for _, filter := range extraFilters {
+		db = whereExtraFilter(db, filter)
+	}
+
 	statusList := getStatusList(status)
 	dbRes := db.Where("status in (?)", statusList).Offset(offset).Limit(count).Find(&edgexList)
 	if dbRes.Error != nil {
-		logs.Error("[GetEdgexList] get edgexList failed: edgexIDs=%+v, userIDs=%+v, key=%v, status=%+v, offset=%v, count=%v",
-			edgexIDs, userIDs, keyword, statusList, offset, count)
+		logs.Error("[GetEdgexList] get edgexList failed: edgexIDs=%+v, userIDs=%+v, key=%v, status=%+v, offset=%v, count=%v, err=%v",
+			edgexIDs, userIDs, keyword, statusList, offset, count, dbRes.Error)
 		err = dbRes.Error
 		return
 	}
 	return
 }
 
+// extra不是合法json时按空对象处理, 避免JSON_EXTRACT报错
+const extraJSONExpr = "JSON_EXTRACT(IF(JSON_VALID(extra), extra, '{}'), ?)"
+
+func whereExtraFilter(db *gorm.DB, filter *attribute.Filter) *gorm.DB {
+	switch value := filter.Value.(type) {
+	case float64:
+		return db.Where("CAST("+extraJSONExpr+" AS DECIMAL(30,10)) "+filter.Op+" ?", filter.JSONPath(), value)
+	case bool:
+		return db.Where(extraJSONExpr+" "+filter.Op+" CAST(? AS JSON)", filter.JSONPath(), fmt.Sprintf("%v", value))
+	default:
+		return db.Where("JSON_UNQUOTE("+extraJSONExpr+") "+filter.Op+" ?", filter.JSONPath(), value)
+	}
+}
+
 func getStatusList(status int) []int {
 	switch status {
 	case 1:
@@ -82,3 +129,86 @@ func getStatusList(status int) []int {
 	}
 	return []int{0, 1}
 }
+
+// GetEdgexMapByIDs ...
+func GetEdgexMapByIDs(edgexIDs []int64) (edgexMap map[int64]*EdgexServiceItem, err error) {
+	edgexMap = make(map[int64]*EdgexServiceItem)
+	if len(edgexIDs) == 0 {
+		return
+	}
+	edgexList := make([]*EdgexServiceItem, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexServiceItem{}).Where("id IN (?)", edgexIDs).Find(&edgexList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetEdgexMapByIDs] get edgex failed: edgexIDs=%v, err=%v", edgexIDs, err)
+		return
+	}
+	for _, item := range edgexList {
+		edgexMap[item.ID] = item
+	}
+	return
+}
+
+// GetEdgexMapByPrefixes 查询未删除的edgex, key为prefix
+func GetEdgexMapByPrefixes(db *gorm.DB, prefixes []string) (edgexMap map[string]*EdgexServiceItem, err error) {
+	edgexMap = make(map[string]*EdgexServiceItem)
+	if len(prefixes) == 0 {
+		return
+	}
+	edgexList := make([]*EdgexServiceItem, 0)
+	dbRes := db.Debug().Model(&EdgexServiceItem{}).Where("deleted = 0 AND prefix IN (?)", prefixes).Find(&edgexList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetEdgexMapByPrefixes] get edgex failed: prefixes=%v, err=%v", prefixes, err)
+		return
+	}
+	for _, item := range edgexList {
+		edgexMap[item.Prefix] = item
+	}
+	return
+}
+
+// ScanEdgex 按id递增分批扫描未删除的edgex服务
+func ScanEdgex(lastID int64, count int) (edgexList []*EdgexServiceItem, err error) {
+	edgexList = make([]*EdgexServiceItem, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexServiceItem{}).
+		Where("id > ? AND deleted = 0", lastID).
+		Order("id ASC").
+		Limit(count).
+		Find(&edgexList)
+	if dbRes.Error != nil {
+		logs.Error("[ScanEdgex] get edgexList failed: lastID=%v, count=%v, err=%v", lastID, count, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return
+}
+
+// GetEdgexListByOrgID 组织下在[start, end)内存在过的edgex服务, 包括期间被删除的
+func GetEdgexListByOrgID(orgID int64, start time.Time, end time.Time) (edgexList []*EdgexServiceItem, err error) {
+	edgexList = make([]*EdgexServiceItem, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexServiceItem{}).
+		Where("org_id = ? AND created_time < ?", orgID, end).
+		Where("deleted = 0 OR modified_time >= ?", start).
+		Order("id ASC").
+		Find(&edgexList)
+	if dbRes.Error != nil {
+		logs.Error("[GetEdgexListByOrgID] get edgexList failed: orgID=%v, err=%v", orgID, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return
+}
+
+// UpdateEdgexStatus 仅当当前状态为from时更新, 返回是否更新成功; 不改变modified_time
+func UpdateEdgexStatus(db *gorm.DB, edgexID int64, from int32, to int32) (updated bool, err error) {
+	dbRes := db.Debug().Model(&EdgexServiceItem{}).
+		Where("id = ? AND status = ? AND deleted = 0", edgexID, from).
+		Updates(map[string]interface{}{"status": to, "modified_time": gorm.Expr("modified_time")})
+	if dbRes.Error != nil {
+		logs.Error("[UpdateEdgexStatus] update status failed: edgexID=%v, from=%v, to=%v, err=%v", edgexID, from, to, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return dbRes.RowsAffected > 0, nil
+}
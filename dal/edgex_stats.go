@@ -0,0 +1,124 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+)
+
+// StatsScope 统计范围, 与搜索的action一致; 均为0时统计全部未删除的edgex
+type StatsScope struct {
+	OwnerID    int64 // 只统计该用户创建的
+	FollowerID int64 // 只统计该用户关注的
+}
+
+// GroupCount 分组计数, SubKey仅用于二级分组
+type GroupCount struct {
+	Key    string `gorm:"column:group_key"`
+	SubKey string `gorm:"column:sub_key"`
+	Count  int64  `gorm:"column:cnt"`
+}
+
+// EdgexCount 按edgex分组的计数, e.g. 关注人数
+type EdgexCount struct {
+	EdgexID int64 `gorm:"column:edgex_id"`
+	Count   int64 `gorm:"column:cnt"`
+}
+
+// location不是合法json时按空对象处理
+const locationJSONExpr = "IFNULL(JSON_UNQUOTE(JSON_EXTRACT(IF(JSON_VALID(location), location, '{}'), ?)), '')"
+
+func scopedEdgex(scope *StatsScope) *gorm.DB {
+	db := caller.EdgexDB.Debug().Model(&EdgexServiceItem{}).Where("deleted = 0")
+	if scope.OwnerID > 0 {
+		db = db.Where("user_id = ?", scope.OwnerID)
+	}
+	if scope.FollowerID > 0 {
+		followed := caller.EdgexDB.Model(&EdgexRelatedUser{}).Select("edgex_id").
+			Where("user_id = ? AND status = ?", scope.FollowerID, StatusFollow)
+		db = db.Where("id IN (?)", followed)
+	}
+	return db
+}
+
+// CountEdgexByStatus key为status
+func CountEdgexByStatus(scope *StatsScope) (itemList []*GroupCount, err error) {
+	itemList = make([]*GroupCount, 0)
+	dbRes := scopedEdgex(scope).Select("CAST(status AS CHAR) AS group_key, COUNT(*) AS cnt").
+		Group("status").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountEdgexByStatus] count failed: scope=%+v, err=%v", scope, err)
+		return
+	}
+	return
+}
+
+// CountEdgexByLocation key为省份, SubKey为城市, 未填写时为空
+func CountEdgexByLocation(scope *StatsScope) (itemList []*GroupCount, err error) {
+	itemList = make([]*GroupCount, 0)
+	dbRes := scopedEdgex(scope).
+		Select(locationJSONExpr+" AS group_key, "+locationJSONExpr+" AS sub_key, COUNT(*) AS cnt", "$.province", "$.city").
+		Group("group_key, sub_key").Order("cnt DESC").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountEdgexByLocation] count failed: scope=%+v, err=%v", scope, err)
+		return
+	}
+	return
+}
+
+// CountEdgexByOwner key为创建人user_id, 按数量倒序取前limit个
+func CountEdgexByOwner(scope *StatsScope, limit int) (itemList []*GroupCount, err error) {
+	itemList = make([]*GroupCount, 0)
+	dbRes := scopedEdgex(scope).Select("CAST(user_id AS CHAR) AS group_key, COUNT(*) AS cnt").
+		Group("user_id").Order("cnt DESC").Limit(limit).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountEdgexByOwner] count failed: scope=%+v, err=%v", scope, err)
+		return
+	}
+	return
+}
+
+// CountEdgexByMonth key为创建月份, e.g. 2021-04, 只统计since之后创建的
+func CountEdgexByMonth(scope *StatsScope, since time.Time) (itemList []*GroupCount, err error) {
+	itemList = make([]*GroupCount, 0)
+	dbRes := scopedEdgex(scope).Select("DATE_FORMAT(created_time, '%Y-%m') AS group_key, COUNT(*) AS cnt").
+		Where("created_time >= ?", since).
+		Group("group_key").Order("group_key ASC").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountEdgexByMonth] count failed: scope=%+v, err=%v", scope, err)
+		return
+	}
+	return
+}
+
+// GetTopFollowedEdgex 关注人数最多的limit个edgex
+func GetTopFollowedEdgex(scope *StatsScope, limit int) (itemList []*EdgexCount, err error) {
+	itemList = make([]*EdgexCount, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexRelatedUser{}).Select("edgex_id, COUNT(*) AS cnt").
+		Where("status = ? AND edgex_id IN (?)", StatusFollow, scopedEdgex(scope).Select("id")).
+		Group("edgex_id").Order("cnt DESC, edgex_id ASC").Limit(limit).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetTopFollowedEdgex] count failed: scope=%+v, err=%v", scope, err)
+		return
+	}
+	return
+}
+
+// GetRecentlyChangedEdgex 最近修改的limit个edgex, 探测引起的状态变化不改变modified_time
+func GetRecentlyChangedEdgex(scope *StatsScope, limit int) (edgexList []*EdgexServiceItem, err error) {
+	edgexList = make([]*EdgexServiceItem, 0)
+	dbRes := scopedEdgex(scope).Order("modified_time DESC").Limit(limit).Find(&edgexList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetRecentlyChangedEdgex] get edgex failed: scope=%+v, err=%v", scope, err)
+		return
+	}
+	return
+}
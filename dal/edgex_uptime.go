@@ -0,0 +1,221 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EdgexProbeResult 单次探测结果, 超过保留期后聚合到EdgexProbeHourly
+type EdgexProbeResult struct {
+	ID        int64     `gorm:"column:id" json:"id"`
+	EdgexID   int64     `gorm:"column:edgex_id" json:"edgex_id"`
+	ProbeTime time.Time `gorm:"column:probe_time" json:"probe_time"`
+	Up        bool      `gorm:"column:up" json:"up"`
+	LatencyMs int64     `gorm:"column:latency_ms" json:"latency_ms"`
+	Error     string    `gorm:"column:error" json:"error"`
+}
+
+// EdgexProbeHourly 按小时聚合的探测结果
+type EdgexProbeHourly struct {
+	ID           int64     `gorm:"column:id" json:"id"`
+	EdgexID      int64     `gorm:"column:edgex_id" json:"edgex_id"`
+	Hour         time.Time `gorm:"column:hour" json:"hour"`
+	Samples      int64     `gorm:"column:samples" json:"samples"`
+	UpSamples    int64     `gorm:"column:up_samples" json:"up_samples"`
+	LatencySum   int64     `gorm:"column:latency_sum" json:"latency_sum"` // 仅统计up的样本
+	LatencyMin   int64     `gorm:"column:latency_min" json:"latency_min"`
+	LatencyMax   int64     `gorm:"column:latency_max" json:"latency_max"`
+	LatencyHisto string    `gorm:"column:latency_histo" json:"latency_histo"` // 延迟直方图各桶计数, JSON数组
+}
+
+// EdgexOutage 不可达区间, 由探测状态变化维护, 不参与降采样
+type EdgexOutage struct {
+	ID        int64      `gorm:"column:id" json:"id"`
+	EdgexID   int64      `gorm:"column:edgex_id" json:"edgex_id"`
+	StartTime time.Time  `gorm:"column:start_time" json:"start_time"`
+	EndTime   *time.Time `gorm:"column:end_time" json:"end_time"` // 为空表示仍未恢复
+	Error     string     `gorm:"column:error" json:"error"`
+}
+
+// AddProbeResult ...
+func AddProbeResult(db *gorm.DB, item *EdgexProbeResult) error {
+	dbRes := db.Debug().Model(&EdgexProbeResult{}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[AddProbeResult] create probe result failed: edgexID=%v, err=%v", item.EdgexID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetProbeResultList edgexID为0时查询全部edgex, 按探测时间升序
+func GetProbeResultList(edgexID int64, start time.Time, end time.Time) (itemList []*EdgexProbeResult, err error) {
+	itemList = make([]*EdgexProbeResult, 0)
+	db := caller.EdgexDB.Debug().Model(&EdgexProbeResult{}).
+		Where("probe_time >= ? AND probe_time < ?", start, end)
+	if edgexID > 0 {
+		db = db.Where("edgex_id = ?", edgexID)
+	}
+	dbRes := db.Order("probe_time ASC").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetProbeResultList] get probe results failed: edgexID=%v, err=%v", edgexID, err)
+		return
+	}
+	return
+}
+
+// GetOldestProbeResult ...
+func GetOldestProbeResult() (item *EdgexProbeResult, err error) {
+	itemList := make([]*EdgexProbeResult, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexProbeResult{}).Order("probe_time ASC").Limit(1).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetOldestProbeResult] get probe result failed: err=%v", err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// DeleteProbeResults 删除[start, end)内的探测结果
+func DeleteProbeResults(db *gorm.DB, start time.Time, end time.Time) error {
+	dbRes := db.Debug().Where("probe_time >= ? AND probe_time < ?", start, end).Delete(&EdgexProbeResult{})
+	if dbRes.Error != nil {
+		logs.Error("[DeleteProbeResults] delete probe results failed: start=%v, end=%v, err=%v", start, end, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetProbeHourlyByHour 该小时已有的聚合结果, 按edgex_id索引; 在事务中加锁读取
+func GetProbeHourlyByHour(db *gorm.DB, hour time.Time, edgexIDs []int64) (itemMap map[int64]*EdgexProbeHourly, err error) {
+	itemMap = make(map[int64]*EdgexProbeHourly)
+	if len(edgexIDs) == 0 {
+		return
+	}
+	itemList := make([]*EdgexProbeHourly, 0)
+	dbRes := db.Debug().Model(&EdgexProbeHourly{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("hour = ? AND edgex_id IN (?)", hour, edgexIDs).
+		Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetProbeHourlyByHour] get hourly failed: hour=%v, err=%v", hour, err)
+		return
+	}
+	for _, item := range itemList {
+		itemMap[item.EdgexID] = item
+	}
+	return
+}
+
+// SaveProbeHourly 同一edgex同一小时已存在时覆盖, 调用方需先用GetProbeHourlyByHour合并已有的计数
+func SaveProbeHourly(db *gorm.DB, itemList []*EdgexProbeHourly) error {
+	if len(itemList) == 0 {
+		return nil
+	}
+	dbRes := db.Debug().Model(&EdgexProbeHourly{}).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"samples", "up_samples", "latency_sum", "latency_min", "latency_max", "latency_histo"}),
+	}).Create(itemList)
+	if dbRes.Error != nil {
+		logs.Error("[SaveProbeHourly] save hourly failed: count=%v, err=%v", len(itemList), dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetProbeHourlyList 按小时升序
+func GetProbeHourlyList(edgexID int64, start time.Time, end time.Time) (itemList []*EdgexProbeHourly, err error) {
+	itemList = make([]*EdgexProbeHourly, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexProbeHourly{}).
+		Where("edgex_id = ? AND hour >= ? AND hour < ?", edgexID, start, end).
+		Order("hour ASC").
+		Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetProbeHourlyList] get hourly failed: edgexID=%v, err=%v", edgexID, err)
+		return
+	}
+	return
+}
+
+// DeleteProbeHourlyBefore ...
+func DeleteProbeHourlyBefore(before time.Time) error {
+	dbRes := caller.EdgexDB.Debug().Where("hour < ?", before).Delete(&EdgexProbeHourly{})
+	if dbRes.Error != nil {
+		logs.Error("[DeleteProbeHourlyBefore] delete hourly failed: before=%v, err=%v", before, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// AddOutage ...
+func AddOutage(db *gorm.DB, item *EdgexOutage) error {
+	dbRes := db.Debug().Model(&EdgexOutage{}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[AddOutage] create outage failed: edgexID=%v, err=%v", item.EdgexID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// CloseOutage 结束edgex未恢复的不可达区间
+func CloseOutage(db *gorm.DB, edgexID int64, endTime time.Time) error {
+	dbRes := db.Debug().Model(&EdgexOutage{}).
+		Where("edgex_id = ? AND end_time IS NULL", edgexID).
+		Update("end_time", endTime)
+	if dbRes.Error != nil {
+		logs.Error("[CloseOutage] close outage failed: edgexID=%v, err=%v", edgexID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetOpenOutage edgex当前未恢复的不可达区间
+func GetOpenOutage(edgexID int64) (item *EdgexOutage, err error) {
+	itemList := make([]*EdgexOutage, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexOutage{}).
+		Where("edgex_id = ? AND end_time IS NULL", edgexID).
+		Limit(1).
+		Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetOpenOutage] get outage failed: edgexID=%v, err=%v", edgexID, err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// GetOutageList 与[start, end)有交集的不可达区间, 按开始时间升序
+func GetOutageList(edgexID int64, start time.Time, end time.Time) (itemList []*EdgexOutage, err error) {
+	itemList = make([]*EdgexOutage, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexOutage{}).
+		Where("edgex_id = ? AND start_time < ?", edgexID, end).
+		Where("end_time IS NULL OR end_time > ?", start).
+		Order("start_time ASC").
+		Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetOutageList] get outages failed: edgexID=%v, err=%v", edgexID, err)
+		return
+	}
+	return
+}
+
+// DeleteOutageBefore 删除在before之前已恢复的不可达区间
+func DeleteOutageBefore(before time.Time) error {
+	dbRes := caller.EdgexDB.Debug().Where("end_time IS NOT NULL AND end_time < ?", before).Delete(&EdgexOutage{})
+	if dbRes.Error != nil {
+		logs.Error("[DeleteOutageBefore] delete outages failed: before=%v, err=%v", before, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
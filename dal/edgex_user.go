@@ -8,15 +8,21 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户角色
+const (
+	RoleUser  = 0 // RoleUser 普通用户
+	RoleAdmin = 1 // RoleAdmin 管理员
+)
+
 // EdgexUser ...
 type EdgexUser struct {
 	ID           int64     `gorm:"column:id" json:"id"`
 	Username     string    `gorm:"column:username" json:"username"`
-	Password     string    `gorm:"column:password" json:"password"`
+	Password     string    `gorm:"column:password" json:"-"`
 	PhoneNumber  string    `gorm:"column:phone_number" json:"phone_number"`
 	Email        string    `gorm:"column:email" json:"email"`
 	Deleted      int32     `gorm:"column:deleted" json:"deleted"`
-	Entrypted    string    `gorm:"column:entrypted" json:"entrypted"`
+	Role         int32     `gorm:"column:role" json:"role"`
 	CreatedTime  time.Time `gorm:"column:created_time" json:"created_time"`
 	ModifiedTime time.Time `gorm:"column:modified_time" json:"modified_time"`
 }
@@ -25,7 +31,7 @@ type EdgexUser struct {
 func AddEdgexUser(db *gorm.DB, user *EdgexUser) (err error) {
 	dbRes := db.Debug().Model(&EdgexUser{}).Create(user)
 	if dbRes.Error != nil {
-		err = dbRes.Error
+		err = translateError(dbRes.Error)
 		logs.Error("[AddEdgexUser] add user failed: user=%v, err=%v", user, err)
 		return
 	}
@@ -77,6 +83,32 @@ func GetEdgexUserByEmail(email string) (user *EdgexUser, err error) {
 	return
 }
 
+// GetEdgexUserByPhone phone_number为E.164格式
+func GetEdgexUserByPhone(phone string) (user *EdgexUser, err error) {
+	userList := make([]*EdgexUser, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexUser{}).Where("phone_number = ?", phone).Find(&userList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetEdgexUserByPhone] get edgex user failed: err=%v", err)
+		return
+	}
+	if len(userList) > 0 {
+		user = userList[0]
+	}
+	return
+}
+
+// UpdateEdgexUserPhone ...
+func UpdateEdgexUserPhone(db *gorm.DB, userID int64, phone string) error {
+	dbRes := db.Debug().Model(&EdgexUser{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"phone_number": phone, "modified_time": time.Now()})
+	if dbRes.Error != nil {
+		logs.Error("[UpdateEdgexUserPhone] update phone failed: userID=%v, err=%v", userID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
 func UpdateEdgexUser(user_name string, fieldsMap map[string]interface{}) error {
 	dbRes := caller.EdgexDB.Debug().Model(&EdgexUser{}).Where("username = ?", user_name).Updates(fieldsMap)
 	if dbRes.Error != nil {
@@ -85,3 +117,84 @@ func UpdateEdgexUser(user_name string, fieldsMap map[string]interface{}) error {
 	}
 	return nil
 }
+
+// GetEdgexUserMapByIDs ...
+func GetEdgexUserMapByIDs(ids []int64) (userMap map[int64]*EdgexUser, err error) {
+	userMap = make(map[int64]*EdgexUser)
+	if len(ids) == 0 {
+		return
+	}
+	userList := make([]*EdgexUser, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexUser{}).Where("id IN (?)", ids).Find(&userList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetEdgexUserMapByIDs] get edgex user failed: userIDs=%v, err=%v", ids, err)
+		return
+	}
+	for _, user := range userList {
+		userMap[user.ID] = user
+	}
+	return
+}
+
+// UpdateEdgexUserPassword 仅当当前密码为from时更新, 返回是否更新成功; 不使用Debug(), 避免密码写入日志
+func UpdateEdgexUserPassword(db *gorm.DB, userID int64, from string, to string) (updated bool, err error) {
+	dbRes := db.Model(&EdgexUser{}).Where("id = ? AND password = ?", userID, from).
+		Updates(map[string]interface{}{"password": to, "modified_time": time.Now()})
+	if dbRes.Error != nil {
+		logs.Error("[UpdateEdgexUserPassword] update password failed: userID=%v, err=%v", userID, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return dbRes.RowsAffected > 0, nil
+}
+
+// ScanEdgexUser 按id递增分批扫描用户
+func ScanEdgexUser(lastID int64, count int) (userList []*EdgexUser, err error) {
+	userList = make([]*EdgexUser, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexUser{}).
+		Where("id > ?", lastID).
+		Order("id ASC").
+		Limit(count).
+		Find(&userList)
+	if dbRes.Error != nil {
+		logs.Error("[ScanEdgexUser] select from database failed: lastID=%v, count=%v, err=%v", lastID, count, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return
+}
+
+// legacyAnswerColumn 旧版以明文"<question_id> <answer>"保存的密码保护问题, 已改为edgex_security_answer
+const legacyAnswerColumn = "entrypted"
+
+// CountLegacyAnswers 统计仍保存明文密保的用户数, 列已删除时exist为false
+func CountLegacyAnswers() (exist bool, count int64, err error) {
+	if !caller.EdgexDB.Migrator().HasColumn(&EdgexUser{}, legacyAnswerColumn) {
+		return false, 0, nil
+	}
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexUser{}).Where(legacyAnswerColumn + " <> ''").Count(&count)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountLegacyAnswers] count legacy answers failed: err=%v", err)
+		return
+	}
+	return true, count, nil
+}
+
+// DropLegacyAnswers 先清空明文密保再删除该列, 删除列失败时明文也已清除
+func DropLegacyAnswers() (cleared int64, err error) {
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexUser{}).Where(legacyAnswerColumn + " <> ''").
+		Updates(map[string]interface{}{legacyAnswerColumn: ""})
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[DropLegacyAnswers] clear legacy answers failed: err=%v", err)
+		return
+	}
+	cleared = dbRes.RowsAffected
+	if err = caller.EdgexDB.Migrator().DropColumn(&EdgexUser{}, legacyAnswerColumn); err != nil {
+		logs.Error("[DropLegacyAnswers] drop column failed: err=%v", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,193 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EdgexUserMFA 用户的TOTP密钥, 以[Credential] MasterKey加密保存; 确认前enabled为false
+type EdgexUserMFA struct {
+	ID            int64     `gorm:"column:id" json:"id"`
+	UserID        int64     `gorm:"column:user_id" json:"user_id"`
+	KeyID         string    `gorm:"column:key_id" json:"key_id"`
+	Secret        string    `gorm:"column:secret" json:"-"`
+	Enabled       bool      `gorm:"column:enabled" json:"enabled"`
+	ConfirmedTime time.Time `gorm:"column:confirmed_time" json:"confirmed_time"`
+	CreatedTime   time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime  time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// EdgexRecoveryCode 两步验证的恢复码, 只保存哈希, 每个只能使用一次
+type EdgexRecoveryCode struct {
+	ID          int64     `gorm:"column:id" json:"id"`
+	UserID      int64     `gorm:"column:user_id" json:"user_id"`
+	CodeHash    string    `gorm:"column:code_hash" json:"-"`
+	Used        bool      `gorm:"column:used" json:"used"`
+	UsedTime    time.Time `gorm:"column:used_time" json:"used_time"`
+	CreatedTime time.Time `gorm:"column:created_time" json:"created_time"`
+}
+
+// EdgexMFAPolicy 角色是否必须开启两步验证, 没有记录时不要求
+type EdgexMFAPolicy struct {
+	ID           int64     `gorm:"column:id" json:"id"`
+	Role         int32     `gorm:"column:role" json:"role"`
+	Required     bool      `gorm:"column:required" json:"required"`
+	UpdatedBy    int64     `gorm:"column:updated_by" json:"updated_by"`
+	CreatedTime  time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// TableName ...
+func (EdgexUserMFA) TableName() string {
+	return "edgex_user_mfa"
+}
+
+// TableName ...
+func (EdgexMFAPolicy) TableName() string {
+	return "edgex_mfa_policy"
+}
+
+// SaveUserMFA 每个用户一条, 已存在时覆盖; 不使用Debug(), 避免密文写入日志
+func SaveUserMFA(db *gorm.DB, item *EdgexUserMFA) error {
+	dbRes := db.Model(&EdgexUserMFA{}).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"key_id", "secret", "enabled", "confirmed_time", "modified_time"}),
+	}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[SaveUserMFA] save user mfa failed: userID=%v, err=%v", item.UserID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetUserMFA 不存在时返回nil
+func GetUserMFA(userID int64) (item *EdgexUserMFA, err error) {
+	itemList := make([]*EdgexUserMFA, 0)
+	dbRes := caller.EdgexDB.Model(&EdgexUserMFA{}).Where("user_id = ?", userID).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetUserMFA] get user mfa failed: userID=%v, err=%v", userID, err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// EnableUserMFA 仅当密钥仍为确认时的密钥时启用, 返回是否更新成功
+func EnableUserMFA(db *gorm.DB, userID int64, secret string) (updated bool, err error) {
+	now := time.Now()
+	dbRes := db.Model(&EdgexUserMFA{}).Where("user_id = ? AND secret = ?", userID, secret).
+		Updates(map[string]interface{}{"enabled": true, "confirmed_time": now, "modified_time": now})
+	if dbRes.Error != nil {
+		logs.Error("[EnableUserMFA] enable user mfa failed: userID=%v, err=%v", userID, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return dbRes.RowsAffected > 0, nil
+}
+
+// DeleteUserMFA 同时删除恢复码
+func DeleteUserMFA(db *gorm.DB, userID int64) error {
+	dbRes := db.Debug().Where("user_id = ?", userID).Delete(&EdgexUserMFA{})
+	if dbRes.Error != nil {
+		logs.Error("[DeleteUserMFA] delete user mfa failed: userID=%v, err=%v", userID, dbRes.Error)
+		return dbRes.Error
+	}
+	return ReplaceRecoveryCodes(db, userID, nil)
+}
+
+// ScanUserMFANotKey 按id递增扫描非keyID加密的记录, 用于轮换主密钥
+func ScanUserMFANotKey(keyID string, lastID int64, count int) (itemList []*EdgexUserMFA, err error) {
+	itemList = make([]*EdgexUserMFA, 0)
+	dbRes := caller.EdgexDB.Model(&EdgexUserMFA{}).
+		Where("id > ? AND key_id != ?", lastID, keyID).
+		Order("id ASC").Limit(count).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[ScanUserMFANotKey] scan user mfa failed: lastID=%v, err=%v", lastID, err)
+		return
+	}
+	return
+}
+
+// UpdateUserMFACipher 以原密文为条件更新, 轮换期间重新绑定时跳过; 不改变modified_time
+func UpdateUserMFACipher(db *gorm.DB, id int64, fromSecret string, keyID string, secret string) (updated bool, err error) {
+	dbRes := db.Model(&EdgexUserMFA{}).Where("id = ? AND secret = ?", id, fromSecret).
+		Updates(map[string]interface{}{"key_id": keyID, "secret": secret, "modified_time": gorm.Expr("modified_time")})
+	if dbRes.Error != nil {
+		logs.Error("[UpdateUserMFACipher] update user mfa failed: id=%v, err=%v", id, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return dbRes.RowsAffected > 0, nil
+}
+
+// ReplaceRecoveryCodes 删除用户原有恢复码后写入新恢复码, itemList为空时只删除
+func ReplaceRecoveryCodes(db *gorm.DB, userID int64, itemList []*EdgexRecoveryCode) error {
+	dbRes := db.Where("user_id = ?", userID).Delete(&EdgexRecoveryCode{})
+	if dbRes.Error != nil {
+		logs.Error("[ReplaceRecoveryCodes] delete recovery code failed: userID=%v, err=%v", userID, dbRes.Error)
+		return dbRes.Error
+	}
+	if len(itemList) == 0 {
+		return nil
+	}
+	dbRes = db.Model(&EdgexRecoveryCode{}).Create(itemList)
+	if dbRes.Error != nil {
+		logs.Error("[ReplaceRecoveryCodes] add recovery code failed: userID=%v, err=%v", userID, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// UseRecoveryCode 将未使用的恢复码标记为已使用, 返回是否存在该恢复码
+func UseRecoveryCode(db *gorm.DB, userID int64, codeHash string) (used bool, err error) {
+	dbRes := db.Model(&EdgexRecoveryCode{}).Where("user_id = ? AND code_hash = ? AND used = 0", userID, codeHash).
+		Updates(map[string]interface{}{"used": true, "used_time": time.Now()})
+	if dbRes.Error != nil {
+		logs.Error("[UseRecoveryCode] use recovery code failed: userID=%v, err=%v", userID, dbRes.Error)
+		err = dbRes.Error
+		return
+	}
+	return dbRes.RowsAffected > 0, nil
+}
+
+// CountUnusedRecoveryCodes ...
+func CountUnusedRecoveryCodes(userID int64) (count int64, err error) {
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexRecoveryCode{}).Where("user_id = ? AND used = 0", userID).Count(&count)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[CountUnusedRecoveryCodes] count recovery code failed: userID=%v, err=%v", userID, err)
+		return
+	}
+	return
+}
+
+// GetMFAPolicyList ...
+func GetMFAPolicyList() (itemList []*EdgexMFAPolicy, err error) {
+	itemList = make([]*EdgexMFAPolicy, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&EdgexMFAPolicy{}).Order("role ASC").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetMFAPolicyList] get mfa policy failed: err=%v", err)
+		return
+	}
+	return
+}
+
+// SaveMFAPolicy 每个角色一条, 已存在时覆盖
+func SaveMFAPolicy(db *gorm.DB, item *EdgexMFAPolicy) error {
+	dbRes := db.Debug().Model(&EdgexMFAPolicy{}).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"required", "updated_by", "modified_time"}),
+	}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[SaveMFAPolicy] save mfa policy failed: item=%+v, err=%v", item, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysql唯一键冲突错误码
+const mysqlErrDuplicateEntry = 1062
+
+// 唯一索引与冲突字段的对应关系
+var duplicateKeyFields = map[string]string{
+	"idx_prefix":          "prefix",
+	"idx_username":        "username",
+	"idx_relation":        "edgex_id",
+	"idx_org_id":          "org_id",
+	"idx_user_token_name": "name",
+}
+
+// e.g. Duplicate entry 'edgex-test-0' for key 'edgex_service_item.idx_prefix'
+var duplicateEntryRegexp = regexp.MustCompile(`Duplicate entry '(.*)' for key '([^']+)'`)
+
+// DuplicateError 唯一键冲突
+type DuplicateError struct {
+	Key   string
+	Field string
+	Value string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate %s: value=%s, key=%s", e.Field, e.Value, e.Key)
+}
+
+// AsDuplicateError ...
+func AsDuplicateError(err error) (*DuplicateError, bool) {
+	var dupErr *DuplicateError
+	if errors.As(err, &dupErr) {
+		return dupErr, true
+	}
+	return nil, false
+}
+
+// translateError 将mysql唯一键冲突转换为DuplicateError, 其余错误原样返回
+func translateError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrDuplicateEntry {
+		return err
+	}
+	dupErr := &DuplicateError{}
+	if match := duplicateEntryRegexp.FindStringSubmatch(mysqlErr.Message); match != nil {
+		dupErr.Value = match[1]
+		// mysql8.0.19之后key带表名前缀
+		dupErr.Key = match[2][strings.LastIndex(match[2], ".")+1:]
+		// 组合索引的值以'-'拼接, 只保留第一列
+		if idx := strings.LastIndex(dupErr.Value, "-"); idx > 0 && dupErr.Key == "idx_prefix" {
+			dupErr.Value = dupErr.Value[:idx]
+		}
+	}
+	dupErr.Field = duplicateKeyFields[dupErr.Key]
+	if dupErr.Field == "" {
+		dupErr.Field = dupErr.Key
+	}
+	return dupErr
+}
@@ -0,0 +1,202 @@
+package dal
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// webhook订阅状态
+const (
+	WebhookEnabled  = 1 // WebhookEnabled 启用
+	WebhookDisabled = 2 // WebhookDisabled 停用
+)
+
+// webhook投递状态
+const (
+	DeliveryStatusPending = 0 // DeliveryStatusPending 待投递/等待重试
+	DeliveryStatusSuccess = 1 // DeliveryStatusSuccess 投递成功
+	DeliveryStatusFailed  = 2 // DeliveryStatusFailed 超过最大重试次数
+)
+
+// WebhookSubscription ...
+type WebhookSubscription struct {
+	ID           int64     `gorm:"column:id" json:"id"`
+	UserID       int64     `gorm:"column:user_id" json:"user_id"`
+	URL          string    `gorm:"column:url" json:"url"`
+	Secret       string    `gorm:"column:secret" json:"-"`
+	Events       string    `gorm:"column:events" json:"events"` // 逗号分隔的事件类型, *表示全部
+	Status       int32     `gorm:"column:status" json:"status"`
+	Description  string    `gorm:"column:description" json:"description"`
+	CreatedTime  time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// WebhookDelivery 每个订阅对每个事件的一次投递, 记录最近一次尝试的结果
+type WebhookDelivery struct {
+	ID              int64     `gorm:"column:id" json:"id"`
+	SubscriptionID  int64     `gorm:"column:subscription_id" json:"subscription_id"`
+	EventID         int64     `gorm:"column:event_id" json:"event_id"`
+	EventType       string    `gorm:"column:event_type" json:"event_type"`
+	Payload         string    `gorm:"column:payload" json:"payload"`
+	Status          int32     `gorm:"column:status" json:"status"`
+	Attempts        int32     `gorm:"column:attempts" json:"attempts"`
+	NextAttemptTime time.Time `gorm:"column:next_attempt_time" json:"next_attempt_time"`
+	LastStatusCode  int32     `gorm:"column:last_status_code" json:"last_status_code"`
+	LastError       string    `gorm:"column:last_error" json:"last_error"`
+	LastResponse    string    `gorm:"column:last_response" json:"last_response"`
+	LastLatencyMs   int64     `gorm:"column:last_latency_ms" json:"last_latency_ms"`
+	Owner           string    `gorm:"column:owner" json:"-"`
+	CreatedTime     time.Time `gorm:"column:created_time" json:"created_time"`
+	ModifiedTime    time.Time `gorm:"column:modified_time" json:"modified_time"`
+}
+
+// AddWebhookSubscription 不使用Debug(), 避免secret写入日志
+func AddWebhookSubscription(db *gorm.DB, item *WebhookSubscription) error {
+	dbRes := db.Model(&WebhookSubscription{}).Create(item)
+	if dbRes.Error != nil {
+		logs.Error("[AddWebhookSubscription] create subscription failed: url=%v, err=%v", item.URL, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// UpdateWebhookSubscription fieldsMap可能含有secret, 不使用Debug()
+func UpdateWebhookSubscription(db *gorm.DB, id int64, fieldsMap map[string]interface{}) error {
+	dbRes := db.Model(&WebhookSubscription{}).Where("id = ?", id).Updates(fieldsMap)
+	if dbRes.Error != nil {
+		logs.Error("[UpdateWebhookSubscription] update subscription failed: id=%v, err=%v", id, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// DeleteWebhookSubscription ...
+func DeleteWebhookSubscription(db *gorm.DB, id int64) error {
+	dbRes := db.Debug().Where("id = ?", id).Delete(&WebhookSubscription{})
+	if dbRes.Error != nil {
+		logs.Error("[DeleteWebhookSubscription] delete subscription failed: id=%v, err=%v", id, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetWebhookSubscriptionByID ...
+func GetWebhookSubscriptionByID(id int64) (item *WebhookSubscription, err error) {
+	itemList := make([]*WebhookSubscription, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&WebhookSubscription{}).Where("id = ?", id).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetWebhookSubscriptionByID] get subscription failed: id=%v, err=%v", id, err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// GetWebhookSubscriptionList status为0时不过滤状态
+func GetWebhookSubscriptionList(status int32) (itemList []*WebhookSubscription, err error) {
+	itemList = make([]*WebhookSubscription, 0)
+	db := caller.EdgexDB.Debug().Model(&WebhookSubscription{})
+	if status > 0 {
+		db = db.Where("status = ?", status)
+	}
+	dbRes := db.Order("id ASC").Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetWebhookSubscriptionList] get subscriptions failed: status=%v, err=%v", status, err)
+		return
+	}
+	return
+}
+
+// AddWebhookDeliveries 同一订阅同一事件只投递一次, 重复写入忽略
+func AddWebhookDeliveries(db *gorm.DB, itemList []*WebhookDelivery) error {
+	if len(itemList) == 0 {
+		return nil
+	}
+	dbRes := db.Debug().Model(&WebhookDelivery{}).Clauses(clause.Insert{Modifier: "IGNORE"}).Create(itemList)
+	if dbRes.Error != nil {
+		logs.Error("[AddWebhookDeliveries] create deliveries failed: count=%v, err=%v", len(itemList), dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries 抢占到期的待投递记录, owner每次抢占都应唯一
+func ClaimWebhookDeliveries(owner string, lockDuration time.Duration, count int) (itemList []*WebhookDelivery, err error) {
+	itemList = make([]*WebhookDelivery, 0)
+	now := time.Now()
+	// 抢占时顺延next_attempt_time作为锁, 实例崩溃后到期会被重新抢占
+	dbRes := caller.EdgexDB.Debug().Model(&WebhookDelivery{}).
+		Where("status = ? AND next_attempt_time <= ?", DeliveryStatusPending, now).
+		Order("next_attempt_time ASC").
+		Limit(count).
+		Updates(map[string]interface{}{"owner": owner, "next_attempt_time": now.Add(lockDuration)})
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[ClaimWebhookDeliveries] claim deliveries failed: err=%v", err)
+		return
+	}
+	if dbRes.RowsAffected == 0 {
+		return
+	}
+	dbRes = caller.EdgexDB.Debug().Model(&WebhookDelivery{}).
+		Where("status = ? AND owner = ?", DeliveryStatusPending, owner).
+		Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[ClaimWebhookDeliveries] get claimed deliveries failed: owner=%v, err=%v", owner, err)
+		return
+	}
+	return
+}
+
+// UpdateWebhookDelivery ...
+func UpdateWebhookDelivery(db *gorm.DB, id int64, fieldsMap map[string]interface{}) error {
+	dbRes := db.Debug().Model(&WebhookDelivery{}).Where("id = ?", id).Updates(fieldsMap)
+	if dbRes.Error != nil {
+		logs.Error("[UpdateWebhookDelivery] update delivery failed: id=%v, err=%v", id, dbRes.Error)
+		return dbRes.Error
+	}
+	return nil
+}
+
+// GetWebhookDeliveryByID ...
+func GetWebhookDeliveryByID(id int64) (item *WebhookDelivery, err error) {
+	itemList := make([]*WebhookDelivery, 0)
+	dbRes := caller.EdgexDB.Debug().Model(&WebhookDelivery{}).Where("id = ?", id).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetWebhookDeliveryByID] get delivery failed: id=%v, err=%v", id, err)
+		return
+	}
+	if len(itemList) > 0 {
+		item = itemList[0]
+	}
+	return
+}
+
+// GetWebhookDeliveryList subscriptionID/status小于0时不过滤, 按id倒序
+func GetWebhookDeliveryList(subscriptionID int64, status int32, offset int, count int) (itemList []*WebhookDelivery, err error) {
+	itemList = make([]*WebhookDelivery, 0)
+	db := caller.EdgexDB.Debug().Model(&WebhookDelivery{})
+	if subscriptionID > 0 {
+		db = db.Where("subscription_id = ?", subscriptionID)
+	}
+	if status >= 0 {
+		db = db.Where("status = ?", status)
+	}
+	dbRes := db.Order("id DESC").Offset(offset).Limit(count).Find(&itemList)
+	if dbRes.Error != nil {
+		err = dbRes.Error
+		logs.Error("[GetWebhookDeliveryList] get deliveries failed: subscriptionID=%v, status=%v, err=%v", subscriptionID, status, err)
+		return
+	}
+	return
+}
@@ -0,0 +1,247 @@
+package digest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/mail"
+	"github.com/tdycwym/edgex_admin/metrics"
+	"github.com/tdycwym/edgex_admin/uptime"
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+const (
+	checkInterval = 10 * time.Minute
+	scanBatchSize = 100
+	period        = 7 * 24 * time.Hour
+)
+
+// ErrNoSecret 未配置[Digest] Secret时无法生成退订链接, 不发送摘要
+var ErrNoSecret = errors.New("digest secret is not configured")
+
+var startOnce sync.Once
+
+// Start 定时检查是否到了发送时间, 每周只由一个实例发送一次
+func Start() {
+	if !config.DigestConf.Enabled {
+		return
+	}
+	startOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(checkInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				round(time.Now())
+			}
+		}()
+	})
+}
+
+func round(now time.Time) {
+	defer utils.RecoverPanic()
+
+	if int(now.Weekday()) != config.DigestConf.Weekday || now.Hour() != config.DigestConf.Hour {
+		return
+	}
+	// 锁覆盖整周, 同一周内其他实例和后续检查都不会重复发送
+	year, week := now.ISOWeek()
+	ok, err := caller.TryLock(fmt.Sprintf("digest_weekly:%d-%02d", year, week), period+24*time.Hour)
+	if err != nil || !ok {
+		return
+	}
+	start := time.Now()
+	sent, err := SendAll(now)
+	metrics.ObserveJob("digest_weekly", start, err)
+	if err != nil {
+		logs.Error("[digest-round] send digest failed: sent=%d, err=%v", sent, err)
+		return
+	}
+	logs.Info("[digest-round] send digest finished: sent=%d, cost=%v", sent, time.Since(start))
+}
+
+// SendAll 为开启摘要的用户发送[now-7d, now)的摘要, 返回发送成功的人数
+func SendAll(now time.Time) (sent int, err error) {
+	if config.DigestConf.Secret == "" {
+		return 0, ErrNoSecret
+	}
+	b := newBuilder(now.Add(-period), now)
+	var lastID int64
+	for {
+		prefList, err := dal.ScanDigestPrefs(lastID, scanBatchSize)
+		if err != nil {
+			return sent, err
+		}
+		if len(prefList) == 0 {
+			return sent, nil
+		}
+		userIDs := make([]int64, 0, len(prefList))
+		for _, pref := range prefList {
+			userIDs = append(userIDs, pref.UserID)
+			lastID = pref.ID
+		}
+		userMap, err := dal.GetEdgexUserMapByIDs(userIDs)
+		if err != nil {
+			return sent, err
+		}
+		for _, userID := range userIDs {
+			user, ok := userMap[userID]
+			if !ok || user.Deleted != 0 || user.Email == "" {
+				continue
+			}
+			// 单个用户失败不影响其他用户
+			ok, err := b.Send(user)
+			if err != nil {
+				logs.Warn("[digest-SendAll] send digest failed: user_id=%d, err=%v", userID, err)
+				continue
+			}
+			if ok {
+				sent++
+			}
+		}
+	}
+}
+
+// builder 一次发送过程中缓存每个网关的统计, 多个用户关注同一网关时只查询一次
+type builder struct {
+	start  time.Time
+	end    time.Time
+	rowMap map[int64]*Row
+}
+
+func newBuilder(start time.Time, end time.Time) *builder {
+	return &builder{
+		start:  start,
+		end:    end,
+		rowMap: make(map[int64]*Row),
+	}
+}
+
+// Send 没有关注任何网关时不发送, 返回false
+func (b *builder) Send(user *dal.EdgexUser) (bool, error) {
+	digest, err := b.Build(user)
+	if err != nil || len(digest.Rows) == 0 {
+		return false, err
+	}
+	body, err := Render(digest)
+	if err != nil {
+		return false, err
+	}
+	subject := fmt.Sprintf("EdgeX网关每周摘要 %s ~ %s", digest.Start, digest.End)
+	if err = mail.Send(&mail.Message{To: []string{user.Email}, Subject: subject, HTML: body}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Build 用户关注的网关按可用率从低到高排列
+func (b *builder) Build(user *dal.EdgexUser) (*Digest, error) {
+	followMap, err := dal.GetFollowMapByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	edgexIDs := make([]int64, 0, len(followMap))
+	for edgexID := range followMap {
+		edgexIDs = append(edgexIDs, edgexID)
+	}
+	if err = b.load(edgexIDs); err != nil {
+		return nil, err
+	}
+
+	digest := &Digest{
+		Username:       user.Username,
+		Start:          b.start.Format("2006-01-02"),
+		End:            b.end.Format("2006-01-02"),
+		Rows:           make([]*Row, 0, len(edgexIDs)),
+		UnsubscribeURL: UnsubscribeURL(user.ID),
+	}
+	for _, edgexID := range edgexIDs {
+		if row, ok := b.rowMap[edgexID]; ok && row != nil {
+			digest.Rows = append(digest.Rows, row)
+		}
+	}
+	sort.Slice(digest.Rows, func(i, j int) bool {
+		if digest.Rows[i].UptimePercent != digest.Rows[j].UptimePercent {
+			return digest.Rows[i].UptimePercent < digest.Rows[j].UptimePercent
+		}
+		return digest.Rows[i].EdgexID < digest.Rows[j].EdgexID
+	})
+	return digest, nil
+}
+
+// load 查询尚未缓存的网关, 本周之前已删除的网关记为nil
+func (b *builder) load(edgexIDs []int64) error {
+	missing := make([]int64, 0)
+	for _, edgexID := range edgexIDs {
+		if _, ok := b.rowMap[edgexID]; !ok {
+			missing = append(missing, edgexID)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	edgexMap, err := dal.GetEdgexMapByIDs(missing)
+	if err != nil {
+		return err
+	}
+	statusMap, err := dal.CountEventsByEdgex(event.TypeEdgexStatusChanged, missing, b.start, b.end)
+	if err != nil {
+		return err
+	}
+	configMap, err := dal.CountAppliedConfigChanges(missing, b.start, b.end)
+	if err != nil {
+		return err
+	}
+	for _, edgexID := range missing {
+		item, ok := edgexMap[edgexID]
+		if !ok || (item.Deleted != 0 && item.ModifiedTime.Before(b.start)) {
+			b.rowMap[edgexID] = nil
+			continue
+		}
+		report, err := uptime.Report(item, b.start, b.end)
+		if err != nil {
+			return err
+		}
+		b.rowMap[edgexID] = &Row{
+			EdgexID:       item.ID,
+			EdgexName:     item.EdgexName,
+			Prefix:        item.Prefix,
+			Status:        statusText(item),
+			UptimePercent: report.UptimePercent,
+			Outages:       len(report.Outages),
+			Downtime:      (time.Duration(report.DowntimeSeconds) * time.Second).String(),
+			StatusChanges: statusMap[edgexID],
+			ConfigChanges: configMap[edgexID],
+		}
+	}
+	return nil
+}
+
+func statusText(item *dal.EdgexServiceItem) string {
+	switch {
+	case item.Deleted != 0:
+		return "已删除"
+	case item.Status == dal.EdgexActive:
+		return "在线"
+	default:
+		return "离线"
+	}
+}
+
+// Render ...
+func Render(digest *Digest) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := digestTemplate.Execute(buf, digest); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
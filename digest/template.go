@@ -0,0 +1,42 @@
+package digest
+
+import "html/template"
+
+// Digest 一个用户的每周摘要
+type Digest struct {
+	Username       string
+	Start          string
+	End            string
+	Rows           []*Row
+	UnsubscribeURL string
+}
+
+// Row 一个关注的网关在本周的情况
+type Row struct {
+	EdgexID       int64
+	EdgexName     string
+	Prefix        string
+	Status        string
+	UptimePercent float64
+	Outages       int
+	Downtime      string
+	StatusChanges int64
+	ConfigChanges int64
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<html>
+<body style="font-family: sans-serif; font-size: 14px;">
+<p>{{.Username}}，你好：</p>
+<p>以下是你关注的 {{len .Rows}} 个网关在 {{.Start}} 至 {{.End}} 的情况。</p>
+<table border="1" cellspacing="0" cellpadding="6" style="border-collapse: collapse;">
+<tr>
+<th>网关</th><th>当前状态</th><th>可用率</th><th>不可达次数</th><th>不可达时长</th><th>状态变化</th><th>配置变更</th>
+</tr>
+{{range .Rows}}<tr>
+<td>{{.EdgexName}} ({{.Prefix}})</td><td>{{.Status}}</td><td>{{printf "%.2f" .UptimePercent}}%</td><td>{{.Outages}}</td><td>{{.Downtime}}</td><td>{{.StatusChanges}}</td><td>{{.ConfigChanges}}</td>
+</tr>
+{{end}}</table>
+<p style="color: #888;">不想再收到每周摘要？<a href="{{.UnsubscribeURL}}">退订</a>，或在通知设置中关闭。</p>
+</body>
+</html>
+`))
@@ -0,0 +1,47 @@
+package digest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tdycwym/edgex_admin/config"
+)
+
+// UnsubscribeToken 退订链接中的token, 形如 <user_id>.<hmac>; 不过期, 更换Secret后旧链接失效
+func UnsubscribeToken(userID int64) string {
+	return fmt.Sprintf("%d.%s", userID, sign(userID))
+}
+
+// ParseUnsubscribeToken 校验token并返回用户id
+func ParseUnsubscribeToken(token string) (int64, bool) {
+	if config.DigestConf.Secret == "" {
+		return 0, false
+	}
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return 0, false
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, false
+	}
+	if !hmac.Equal([]byte(sign(userID)), []byte(parts[1])) {
+		return 0, false
+	}
+	return userID, true
+}
+
+// UnsubscribeURL ...
+func UnsubscribeURL(userID int64) string {
+	return strings.TrimRight(config.DigestConf.BaseURL, "/") + "/edgex_admin/notification/digest/unsubscribe?token=" + UnsubscribeToken(userID)
+}
+
+func sign(userID int64) string {
+	mac := hmac.New(sha256.New, []byte(config.DigestConf.Secret))
+	mac.Write([]byte("digest-unsubscribe:" + strconv.FormatInt(userID, 10)))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
@@ -0,0 +1,105 @@
+package event
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/dal"
+	"gorm.io/gorm"
+)
+
+// 网关生命周期事件类型
+const (
+	TypeEdgexCreated       = "edgex.created"
+	TypeEdgexUpdated       = "edgex.updated"
+	TypeEdgexDeleted       = "edgex.deleted"
+	TypeEdgexFollowed      = "edgex.followed"
+	TypeEdgexUnfollowed    = "edgex.unfollowed"
+	TypeEdgexStatusChanged = "edgex.status_changed"
+)
+
+// AllTypes ...
+var AllTypes = []string{
+	TypeEdgexCreated,
+	TypeEdgexUpdated,
+	TypeEdgexDeleted,
+	TypeEdgexFollowed,
+	TypeEdgexUnfollowed,
+	TypeEdgexStatusChanged,
+}
+
+// Event 对外推送的事件体, ID即outbox的自增id
+type Event struct {
+	ID         int64                  `json:"id"`
+	Type       string                 `json:"type"`
+	EdgexID    int64                  `json:"edgex_id"`
+	UserID     int64                  `json:"user_id"` // 触发事件的用户, 系统触发时为0
+	OccurredAt int64                  `json:"occurred_at"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// Emit 在业务事务db中写入outbox, 事务回滚则事件一并丢弃
+func Emit(db *gorm.DB, eventType string, edgexID int64, userID int64, data map[string]interface{}) error {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return dal.AddEventOutbox(db, &dal.EdgexEventOutbox{
+		EventType:   eventType,
+		EdgexID:     edgexID,
+		UserID:      userID,
+		Payload:     string(payload),
+		Status:      dal.OutboxStatusPending,
+		CreatedTime: time.Now(),
+	})
+}
+
+// EdgexData 事件中携带的网关快照
+func EdgexData(item *dal.EdgexServiceItem) map[string]interface{} {
+	return map[string]interface{}{
+		"edgex_id":        item.ID,
+		"edgex_name":      item.EdgexName,
+		"prefix":          item.Prefix,
+		"address":         item.Address,
+		"status":          item.Status,
+		"org_id":          item.OrgID,
+		"user_id":         item.UserID,
+		"description":     item.Description,
+		"location":        item.Location,
+		"extra":           item.Extra,
+		"scheme":          item.Scheme,
+		"tls_ca":          item.TLSCA,
+		"tls_server_name": item.TLSServerName,
+		"tls_skip_verify": item.TLSSkipVerify,
+	}
+}
+
+// EdgexUpdateData 更新后的快照, 附带changes(新值)和previous(旧值)
+func EdgexUpdateData(item *dal.EdgexServiceItem, fieldsMap map[string]interface{}) map[string]interface{} {
+	data := EdgexData(item)
+	previous := make(map[string]interface{})
+	for field, value := range fieldsMap {
+		previous[field] = data[field]
+		data[field] = value
+	}
+	data["changes"] = fieldsMap
+	data["previous"] = previous
+	return data
+}
+
+// FromOutbox ...
+func FromOutbox(item *dal.EdgexEventOutbox) *Event {
+	evt := &Event{
+		ID:         item.ID,
+		Type:       item.EventType,
+		EdgexID:    item.EdgexID,
+		UserID:     item.UserID,
+		OccurredAt: item.CreatedTime.Unix(),
+		Data:       make(map[string]interface{}),
+	}
+	_ = json.Unmarshal([]byte(item.Payload), &evt.Data)
+	return evt
+}
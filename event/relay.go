@@ -0,0 +1,135 @@
+package event
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/metrics"
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+const (
+	relayInterval    = time.Second
+	relayBatchSize   = 100
+	relayLockTimeout = 5 * time.Minute
+	relayMaxAttempts = 10
+)
+
+// Handler 事件订阅者, 返回错误时稍后只对该订阅者重新分发, 订阅者需保证幂等
+type Handler func(evt *Event) error
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+var (
+	subscriberLock sync.RWMutex
+	subscribers    []*subscriber
+	relayOnce      sync.Once
+)
+
+// Subscribe 注册事件订阅者, 需在StartRelay之前调用
+func Subscribe(name string, handler Handler) {
+	subscriberLock.Lock()
+	defer subscriberLock.Unlock()
+	subscribers = append(subscribers, &subscriber{name: name, handler: handler})
+}
+
+// StartRelay 启动outbox分发协程
+func StartRelay() {
+	relayOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(relayInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				relayOnceBatch()
+			}
+		}()
+	})
+}
+
+func relayOnceBatch() {
+	defer utils.RecoverPanic()
+
+	for {
+		owner := uuid.NewV4().String()
+		itemList, err := dal.ClaimEventOutbox(owner, relayLockTimeout, relayBatchSize)
+		if err != nil || len(itemList) == 0 {
+			return
+		}
+		for _, item := range itemList {
+			dispatch(item)
+		}
+		if len(itemList) < relayBatchSize {
+			return
+		}
+	}
+}
+
+func dispatch(item *dal.EdgexEventOutbox) {
+	evt := FromOutbox(item)
+
+	subscriberLock.RLock()
+	subscriberList := subscribers
+	subscriberLock.RUnlock()
+
+	// 重试时跳过已处理成功的订阅者
+	delivered := make([]string, 0, len(subscriberList))
+	deliveredSet := make(map[string]bool)
+	for _, name := range strings.Split(item.Delivered, ",") {
+		if name != "" {
+			delivered = append(delivered, name)
+			deliveredSet[name] = true
+		}
+	}
+
+	var dispatchErr error
+	for _, sub := range subscriberList {
+		if deliveredSet[sub.name] {
+			continue
+		}
+		if err := safeHandle(sub, evt); err != nil {
+			logs.Error("[event-dispatch] subscriber failed: subscriber=%s, event_id=%d, type=%s, err=%v",
+				sub.name, evt.ID, evt.Type, err)
+			dispatchErr = err
+			continue
+		}
+		delivered = append(delivered, sub.name)
+	}
+
+	fieldsMap := map[string]interface{}{
+		"status":          dal.OutboxStatusDispatched,
+		"attempts":        item.Attempts + 1,
+		"delivered":       strings.Join(delivered, ","),
+		"dispatched_time": time.Now(),
+	}
+	result := "dispatched"
+	if dispatchErr != nil {
+		result = "dropped"
+	}
+	if dispatchErr != nil && item.Attempts+1 < relayMaxAttempts {
+		fieldsMap = map[string]interface{}{
+			"status":    dal.OutboxStatusPending,
+			"attempts":  item.Attempts + 1,
+			"delivered": strings.Join(delivered, ","),
+		}
+		result = "retry"
+	}
+	metrics.ObserveEvent(result)
+	_ = dal.UpdateEventOutbox(item.ID, fieldsMap)
+}
+
+func safeHandle(sub *subscriber, evt *Event) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("panic: %v", x)
+		}
+	}()
+	return sub.handler(evt)
+}
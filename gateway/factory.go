@@ -0,0 +1,155 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/credential"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/probe"
+)
+
+const (
+	defaultMaxIdleConnsPerHost = 2
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// Factory 按edgex复用http.Transport(连接池), TLS选项或客户端证书变化时重建;
+// 没有TLS选项的edgex共用一个transport
+type Factory struct {
+	mu         sync.Mutex
+	shared     *http.Transport
+	transports map[int64]*transportEntry
+}
+
+type transportEntry struct {
+	fingerprint string
+	transport   *http.Transport
+}
+
+var defaultFactory = NewFactory()
+
+// NewFactory ...
+func NewFactory() *Factory {
+	return &Factory{
+		transports: make(map[int64]*transportEntry),
+	}
+}
+
+// Client 访问edgex的http.Client, 默认复用连接池
+func Client(item *dal.EdgexServiceItem, cred *credential.Credential, timeout time.Duration) (*http.Client, error) {
+	return defaultFactory.Client(item, cred, timeout)
+}
+
+// Prober 访问edgex的探测器, 附带凭证请求头
+func Prober(item *dal.EdgexServiceItem, cred *credential.Credential, timeout time.Duration) (*probe.Prober, error) {
+	return defaultFactory.Prober(item, cred, timeout)
+}
+
+// Forget edgex删除后释放其连接池
+func Forget(edgexID int64) {
+	defaultFactory.Forget(edgexID)
+}
+
+// Client ...
+func (f *Factory) Client(item *dal.EdgexServiceItem, cred *credential.Credential, timeout time.Duration) (*http.Client, error) {
+	transport, err := f.Transport(item, cred)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// Prober timeout<=0时使用probe的默认超时
+func (f *Factory) Prober(item *dal.EdgexServiceItem, cred *credential.Credential, timeout time.Duration) (*probe.Prober, error) {
+	prober := probe.NewProber(timeout)
+	client, err := f.Client(item, cred, prober.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	prober.Client = client
+	if cred != nil {
+		prober.Header = cred.Header()
+	}
+	return prober, nil
+}
+
+// Transport 未保存的edgex(ID为0, e.g. 创建前探测)不缓存且不保持连接
+func (f *Factory) Transport(item *dal.EdgexServiceItem, cred *credential.Credential) (*http.Transport, error) {
+	if !hasTLSOptions(item, cred) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.shared == nil {
+			f.shared = newTransport(nil)
+		}
+		return f.shared, nil
+	}
+
+	tlsConfig, err := TLSConfig(item, cred)
+	if err != nil {
+		return nil, err
+	}
+	if item.ID <= 0 {
+		transport := newTransport(tlsConfig)
+		transport.DisableKeepAlives = true
+		return transport, nil
+	}
+
+	fp := fingerprint(item, cred)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if entry, ok := f.transports[item.ID]; ok {
+		if entry.fingerprint == fp {
+			return entry.transport, nil
+		}
+		entry.transport.CloseIdleConnections()
+	}
+	transport := newTransport(tlsConfig)
+	f.transports[item.ID] = &transportEntry{fingerprint: fp, transport: transport}
+	return transport, nil
+}
+
+// Forget ...
+func (f *Factory) Forget(edgexID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if entry, ok := f.transports[edgexID]; ok {
+		entry.transport.CloseIdleConnections()
+		delete(f.transports, edgexID)
+	}
+}
+
+func newTransport(tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	if config.GatewayConf.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = config.GatewayConf.MaxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = defaultIdleConnTimeout
+	if config.GatewayConf.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = time.Duration(config.GatewayConf.IdleConnTimeout) * time.Second
+	}
+	return transport
+}
+
+// fingerprint TLS选项与客户端证书的摘要, 只保存在内存中
+func fingerprint(item *dal.EdgexServiceItem, cred *credential.Credential) string {
+	h := sha256.New()
+	for _, part := range []string{item.TLSCA, item.TLSServerName, strconv.FormatBool(item.TLSSkipVerify)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if cred != nil {
+		h.Write([]byte(cred.CertPEM))
+		h.Write([]byte{0})
+		h.Write([]byte(cred.KeyPEM))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,71 @@
+package gateway
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/tdycwym/edgex_admin/credential"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/probe"
+)
+
+// Address 带协议的edgex地址, 地址本身不含协议时使用Scheme
+func Address(item *dal.EdgexServiceItem) string {
+	address := strings.TrimSpace(item.Address)
+	if item.Scheme == "" || address == "" || strings.Contains(address, "://") {
+		return address
+	}
+	return item.Scheme + "://" + address
+}
+
+// Validate 校验协议与TLS选项, 地址为空时只校验TLS选项
+func Validate(item *dal.EdgexServiceItem) error {
+	if item.Scheme != "" && item.Scheme != "http" && item.Scheme != "https" {
+		return fmt.Errorf("scheme is invalid: scheme=%s", item.Scheme)
+	}
+	if item.Address != "" {
+		u, err := probe.ParseAddress(Address(item))
+		if err != nil {
+			return err
+		}
+		if item.Scheme != "" && u.Scheme != item.Scheme {
+			return fmt.Errorf("scheme conflicts with address: scheme=%s, address=%s", item.Scheme, item.Address)
+		}
+	}
+	if item.TLSCA != "" {
+		if !x509.NewCertPool().AppendCertsFromPEM([]byte(item.TLSCA)) {
+			return fmt.Errorf("tls_ca has no valid PEM certificate")
+		}
+	}
+	return nil
+}
+
+// hasTLSOptions 是否需要独立的transport
+func hasTLSOptions(item *dal.EdgexServiceItem, cred *credential.Credential) bool {
+	return item.TLSCA != "" || item.TLSServerName != "" || item.TLSSkipVerify || (cred != nil && cred.CertPEM != "")
+}
+
+// TLSConfig edgex的CA、SNI、证书校验及客户端证书
+func TLSConfig(item *dal.EdgexServiceItem, cred *credential.Credential) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         item.TLSServerName,
+		InsecureSkipVerify: item.TLSSkipVerify,
+	}
+	if item.TLSCA != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(item.TLSCA)) {
+			return nil, fmt.Errorf("tls_ca has no valid PEM certificate: edgex_id=%d", item.ID)
+		}
+		cfg.RootCAs = pool
+	}
+	if cred != nil && cred.CertPEM != "" {
+		cert, err := cred.Certificate()
+		if err != nil {
+			return nil, fmt.Errorf("client certificate is invalid: edgex_id=%d", item.ID)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,150 @@
+package attribute
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/attribute"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+// AttributeSchemaParams ...
+type AttributeSchemaParams struct {
+	UserID int64
+	OrgID  int64  `form:"org_id" json:"org_id"`
+	Schema string `form:"schema" json:"schema"`
+}
+
+// AttributeSchemaInfo ...
+type AttributeSchemaInfo struct {
+	OrgID        int64             `json:"org_id"`
+	Inherited    bool              `json:"inherited"` // 组织未定义schema, 使用全局schema
+	Schema       *attribute.Schema `json:"schema"`
+	UserID       int64             `json:"user_id"`
+	ModifiedTime int64             `json:"modified_timestamp"`
+}
+
+type attributeSchemaHandler struct {
+	Ctx    *gin.Context
+	Params AttributeSchemaParams
+	Schema *attribute.Schema
+}
+
+func buildAttributeSchemaHandler(c *gin.Context) *attributeSchemaHandler {
+	return &attributeSchemaHandler{
+		Ctx: c,
+	}
+}
+
+// CheckParams ...
+func (h *attributeSchemaHandler) CheckParams(needSchema bool) (err error) {
+
+	err = h.Ctx.Bind(&h.Params)
+	if err != nil {
+		logs.Error("[attributeSchemaHandler-checkParams] params-err: err=%v", err)
+		return err
+	}
+
+	if h.Params.OrgID < 0 {
+		return fmt.Errorf("org_id is invalid: org_id=%v", h.Params.OrgID)
+	}
+
+	if needSchema {
+		h.Schema, err = attribute.ParseSchema(h.Params.Schema)
+		if err != nil {
+			return err
+		}
+	}
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
+	return nil
+}
+
+// GetAttributeSchema 查询组织生效的schema
+func GetAttributeSchema(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildAttributeSchemaHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams(false)
+	if err != nil {
+		logs.Warn("[GetAttributeSchema] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step2. get schema
+	item, err := dal.GetEffectiveAttributeSchema(h.Params.OrgID)
+	if err != nil {
+		logs.Warn("[GetAttributeSchema] get schema failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if item == nil {
+		return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+	}
+
+	s, err := attribute.ParseSchema(item.Schema)
+	if err != nil {
+		logs.Error("[GetAttributeSchema] stored schema is invalid: org_id=%v, err=%v", item.OrgID, err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, &AttributeSchemaInfo{
+		OrgID:        h.Params.OrgID,
+		Inherited:    item.OrgID != h.Params.OrgID,
+		Schema:       s,
+		UserID:       item.UserID,
+		ModifiedTime: item.ModifiedTime.Unix(),
+	})
+}
+
+// SaveAttributeSchema 新增或覆盖组织的schema, org_id=0为全局schema
+func SaveAttributeSchema(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildAttributeSchemaHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams(true)
+	if err != nil {
+		logs.Warn("[SaveAttributeSchema] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+
+	// Step2. save
+	item := &dal.EdgexAttributeSchema{
+		OrgID:        h.Params.OrgID,
+		Schema:       h.Params.Schema,
+		UserID:       h.Params.UserID,
+		CreatedTime:  time.Now(),
+		ModifiedTime: time.Now(),
+	}
+	err = dal.SaveAttributeSchema(caller.EdgexDB, item)
+	if err != nil {
+		logs.Warn("[SaveAttributeSchema] save schema failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// DeleteAttributeSchema 删除后组织回退到全局schema
+func DeleteAttributeSchema(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildAttributeSchemaHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams(false)
+	if err != nil {
+		logs.Warn("[DeleteAttributeSchema] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step2. delete
+	err = dal.DeleteAttributeSchema(caller.EdgexDB, h.Params.OrgID)
+	if err != nil {
+		logs.Warn("[DeleteAttributeSchema] delete schema failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
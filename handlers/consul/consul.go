@@ -0,0 +1,419 @@
+package consul
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/constdef"
+	"github.com/tdycwym/edgex_admin/consul"
+	"github.com/tdycwym/edgex_admin/credential"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/gateway"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/probe"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+const (
+	defaultPort          = 8500
+	defaultKVPrefix      = "edgex/core/2.0"
+	defaultPreviewExpire = 10 * time.Minute
+)
+
+// 服务名作为KV路径的一段, 不允许包含/和..
+var serviceRegexp = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// ConsulParams ...
+type ConsulParams struct {
+	UserID   int64
+	EdgexID  int64          `form:"edgex_id" json:"edgex_id"`
+	Service  string         `form:"service" json:"service"`
+	Edits    []*consul.Edit `json:"edits"` // 预览时提交, key相对服务根路径
+	ChangeID int64          `form:"change_id" json:"change_id"`
+	Confirm  bool           `form:"confirm" json:"confirm"` // 应用变更时必须为true
+	Offset   int            `form:"offset" json:"offset"`
+	Count    int            `form:"count" json:"count"`
+}
+
+type consulHandler struct {
+	Ctx    *gin.Context
+	Params ConsulParams
+	Edgex  *dal.EdgexServiceItem
+	Change *dal.EdgexConfigChange
+	Client *consul.Client
+}
+
+func buildConsulHandler(c *gin.Context) *consulHandler {
+	return &consulHandler{
+		Ctx: c,
+	}
+}
+
+// CheckParams 加载edgex并校验权限, 仅创建人和管理员可以查看和修改配置; 返回需要响应的错误码
+func (h *consulHandler) CheckParams(needService bool) (code resp.ErrorCode, err error) {
+
+	err = h.Ctx.Bind(&h.Params)
+	if err != nil {
+		logs.Error("[consulHandler-checkParams] params-err: err=%v", err)
+		return resp.RespCodeParamsError, err
+	}
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
+
+	// 按变更操作时以变更记录中的edgex与服务为准
+	if h.Params.ChangeID > 0 {
+		h.Change, err = dal.GetConfigChangeByID(h.Params.ChangeID)
+		if err != nil {
+			return resp.RespDatabaseError, err
+		}
+		if h.Change == nil {
+			return resp.RespCodeParamsError, fmt.Errorf("change not found: change_id=%v", h.Params.ChangeID)
+		}
+		h.Params.EdgexID, h.Params.Service = h.Change.EdgexID, h.Change.Service
+	}
+
+	if h.Params.EdgexID <= 0 {
+		return resp.RespCodeParamsError, fmt.Errorf("edgex_id is invalid: edgex_id=%v", h.Params.EdgexID)
+	}
+	if needService && !serviceRegexp.MatchString(h.Params.Service) {
+		return resp.RespCodeParamsError, fmt.Errorf("service is invalid: service=%s", h.Params.Service)
+	}
+
+	h.Edgex, err = dal.GetEdgexByID(h.Params.EdgexID)
+	if err != nil {
+		return resp.RespDatabaseError, err
+	}
+	if h.Edgex == nil || h.Edgex.Deleted != 0 {
+		return resp.RespCodeParamsError, fmt.Errorf("edgex is not exist: edgex_id=%v", h.Params.EdgexID)
+	}
+
+	if h.Edgex.UserID != h.Params.UserID {
+		user, err := dal.GetEdgexUserByID(h.Params.UserID)
+		if err != nil {
+			return resp.RespDatabaseError, err
+		}
+		if user == nil || user.Role != dal.RoleAdmin {
+			return resp.RespCodeNoPermission, fmt.Errorf("permission denied: user_id=%v, edgex_id=%v", h.Params.UserID, h.Params.EdgexID)
+		}
+	}
+
+	cred, err := credential.Load(h.Edgex.ID)
+	if err != nil {
+		logs.Error("[consulHandler-checkParams] load credential failed: edgex_id=%v, err=%v", h.Edgex.ID, err)
+		return resp.RespCodeServerException, err
+	}
+	h.Client, err = newClient(h.Edgex, cred)
+	if err != nil {
+		return resp.RespCodeParamsError, err
+	}
+	return resp.RespCodeSuccess, nil
+}
+
+// newClient consul与edgex网关部署在同一主机, 使用网关的协议与TLS选项; 凭证中的consul token优先于[Consul] Token
+func newClient(edgex *dal.EdgexServiceItem, cred *credential.Credential) (*consul.Client, error) {
+	u, err := probe.ParseAddress(gateway.Address(edgex))
+	if err != nil {
+		return nil, err
+	}
+	port := config.ConsulConf.Port
+	if port <= 0 {
+		port = defaultPort
+	}
+	baseURL := fmt.Sprintf("%s://%s", u.Scheme, net.JoinHostPort(u.Hostname(), strconv.Itoa(port)))
+	timeout := time.Duration(config.ConsulConf.Timeout) * time.Millisecond
+	client := consul.NewClient(baseURL, config.ConsulConf.Token, timeout)
+	if cred != nil && cred.ConsulToken != "" {
+		client.Token = cred.ConsulToken
+	}
+	client.Client, err = gateway.Client(edgex, cred, client.Client.Timeout)
+	return client, err
+}
+
+func kvPrefix() string {
+	prefix := strings.Trim(config.ConsulConf.KVPrefix, "/")
+	if prefix == "" {
+		prefix = defaultKVPrefix
+	}
+	return prefix + "/"
+}
+
+func (h *consulHandler) serviceRoot() string {
+	return kvPrefix() + h.Params.Service + "/"
+}
+
+// GetConsulServices 列出edgex上在consul中有配置的服务
+func GetConsulServices(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildConsulHandler(c)
+
+	// Step1. checkParams
+	code, err := h.CheckParams(false)
+	if err != nil {
+		logs.Warn("[GetConsulServices] params-err: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+
+	// Step2. list
+	keys, err := h.Client.Keys(c.Request.Context(), kvPrefix())
+	if err != nil {
+		logs.Warn("[GetConsulServices] list consul keys failed: edgex_id=%v, err=%v", h.Params.EdgexID, err)
+		return resp.SampleJSON(c, resp.RespCodeRPCError, err.Error())
+	}
+	services := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "/") {
+			continue
+		}
+		services = append(services, strings.TrimSuffix(strings.TrimPrefix(key, kvPrefix()), "/"))
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, services)
+}
+
+// GetConsulConfig 服务的全部配置项
+func GetConsulConfig(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildConsulHandler(c)
+
+	// Step1. checkParams
+	code, err := h.CheckParams(true)
+	if err != nil {
+		logs.Warn("[GetConsulConfig] params-err: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+
+	// Step2. list
+	pairs, err := h.Client.List(c.Request.Context(), h.serviceRoot())
+	if err != nil {
+		logs.Warn("[GetConsulConfig] list consul kv failed: edgex_id=%v, err=%v", h.Params.EdgexID, err)
+		return resp.SampleJSON(c, resp.RespCodeRPCError, err.Error())
+	}
+	itemList := make([]*model.ConsulConfigItem, 0, len(pairs))
+	for _, pair := range pairs {
+		if strings.HasSuffix(pair.Key, "/") {
+			continue
+		}
+		itemList = append(itemList, &model.ConsulConfigItem{
+			Key:         strings.TrimPrefix(pair.Key, h.serviceRoot()),
+			Value:       pair.Value,
+			ModifyIndex: pair.ModifyIndex,
+		})
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, itemList)
+}
+
+// PreviewConsulConfig 对比当前配置生成变更预览, 需调用ApplyConsulConfig确认后才会写入consul
+func PreviewConsulConfig(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildConsulHandler(c)
+
+	// Step1. checkParams
+	code, err := h.CheckParams(true)
+	if err != nil {
+		logs.Warn("[PreviewConsulConfig] params-err: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+	edits := make([]*consul.Edit, 0, len(h.Params.Edits))
+	for _, edit := range h.Params.Edits {
+		key := strings.Trim(edit.Key, "/")
+		if key == "" || strings.Contains("/"+key+"/", "/../") {
+			return resp.SampleJSON(c, resp.RespCodeParamsError, fmt.Sprintf("key is invalid: %s", edit.Key))
+		}
+		edits = append(edits, &consul.Edit{Key: h.serviceRoot() + key, Value: edit.Value, Delete: edit.Delete})
+	}
+
+	// Step2. diff
+	pairs, err := h.Client.List(c.Request.Context(), h.serviceRoot())
+	if err != nil {
+		logs.Warn("[PreviewConsulConfig] list consul kv failed: edgex_id=%v, err=%v", h.Params.EdgexID, err)
+		return resp.SampleJSON(c, resp.RespCodeRPCError, err.Error())
+	}
+	changes, err := consul.Diff(pairs, edits)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+	if len(changes) == 0 {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "nothing changed")
+	}
+	if len(changes) > consul.MaxTxnOps {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, fmt.Sprintf("too many changes: %d > %d", len(changes), consul.MaxTxnOps))
+	}
+
+	// Step3. 保存待确认的变更
+	changesJSON, _ := json.Marshal(changes)
+	expire := defaultPreviewExpire
+	if config.ConsulConf.PreviewExpire > 0 {
+		expire = time.Duration(config.ConsulConf.PreviewExpire) * time.Second
+	}
+	item := &dal.EdgexConfigChange{
+		EdgexID:     h.Params.EdgexID,
+		Service:     h.Params.Service,
+		Changes:     string(changesJSON),
+		Status:      dal.ConfigChangePending,
+		CreatedBy:   h.Params.UserID,
+		ExpireTime:  time.Now().Add(expire),
+		CreatedTime: time.Now(),
+	}
+	err = dal.AddConfigChange(caller.EdgexDB, item)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, buildChangeInfo(item, nil))
+}
+
+// ApplyConsulConfig 确认并应用预览过的变更; 预览后配置被他人修改时整体回滚
+func ApplyConsulConfig(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildConsulHandler(c)
+
+	// Step1. checkParams
+	code, err := h.CheckParams(true)
+	if err != nil {
+		logs.Warn("[ApplyConsulConfig] params-err: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+	if h.Change == nil || !h.Params.Confirm {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "change_id and confirm=true are required")
+	}
+	if h.Change.Status != dal.ConfigChangePending || time.Now().After(h.Change.ExpireTime) {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "change is not pending or has expired, please preview again")
+	}
+	changes := make([]*consul.Change, 0)
+	if err = json.Unmarshal([]byte(h.Change.Changes), &changes); err != nil {
+		logs.Error("[ApplyConsulConfig] stored changes are invalid: change_id=%v, err=%v", h.Change.ID, err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+	}
+
+	// Step2. 抢占, 同一变更只应用一次
+	updated, err := dal.UpdateConfigChangeStatus(caller.EdgexDB, h.Change.ID, dal.ConfigChangePending, map[string]interface{}{
+		"status":       dal.ConfigChangeApplying,
+		"applied_by":   h.Params.UserID,
+		"applied_time": time.Now(),
+	})
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if !updated {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "change is not pending, please preview again")
+	}
+
+	// Step3. 写入consul
+	applyErr := h.Client.Apply(c.Request.Context(), changes)
+	fieldsMap := map[string]interface{}{"status": dal.ConfigChangeApplied}
+	if applyErr != nil {
+		logs.Warn("[ApplyConsulConfig] apply failed: change_id=%v, err=%v", h.Change.ID, applyErr)
+		fieldsMap = map[string]interface{}{"status": dal.ConfigChangeFailed, "error": applyErr.Error()}
+	}
+	_, err = dal.UpdateConfigChangeStatus(caller.EdgexDB, h.Change.ID, dal.ConfigChangeApplying, fieldsMap)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+
+	conflict := &consul.ConflictError{}
+	switch {
+	case errors.As(applyErr, &conflict):
+		return resp.SampleJSON(c, resp.RespCodeConflict, applyErr.Error())
+	case applyErr != nil:
+		return resp.SampleJSON(c, resp.RespCodeRPCError, applyErr.Error())
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// CancelConsulConfig 取消待确认的变更
+func CancelConsulConfig(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildConsulHandler(c)
+
+	// Step1. checkParams
+	code, err := h.CheckParams(false)
+	if err != nil {
+		logs.Warn("[CancelConsulConfig] params-err: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+	if h.Change == nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "change_id is required")
+	}
+
+	// Step2. cancel
+	updated, err := dal.UpdateConfigChangeStatus(caller.EdgexDB, h.Change.ID, dal.ConfigChangePending, map[string]interface{}{
+		"status": dal.ConfigChangeCancelled,
+	})
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if !updated {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "change is not pending")
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// GetConsulConfigChanges 配置变更记录, service为空时返回edgex全部服务的记录
+func GetConsulConfigChanges(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildConsulHandler(c)
+
+	// Step1. checkParams
+	code, err := h.CheckParams(false)
+	if err != nil {
+		logs.Warn("[GetConsulConfigChanges] params-err: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+	if h.Params.Count <= 0 || h.Params.Count > 100 {
+		h.Params.Count = 20
+	}
+
+	// Step2. list
+	itemList, err := dal.GetConfigChangeList(h.Params.EdgexID, h.Params.Service, h.Params.Offset, h.Params.Count)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	userIDs := make([]int64, 0)
+	for _, item := range itemList {
+		userIDs = append(userIDs, item.CreatedBy, item.AppliedBy)
+	}
+	userMap, err := dal.GetEdgexUserMapByIDs(userIDs)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+
+	infoList := make([]*model.ConfigChangeInfo, 0, len(itemList))
+	for _, item := range itemList {
+		infoList = append(infoList, buildChangeInfo(item, userMap))
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, infoList)
+}
+
+func buildChangeInfo(item *dal.EdgexConfigChange, userMap map[int64]*dal.EdgexUser) *model.ConfigChangeInfo {
+	info := &model.ConfigChangeInfo{
+		ChangeID:    item.ID,
+		EdgexID:     item.EdgexID,
+		Service:     item.Service,
+		Status:      item.Status,
+		Changes:     make([]*consul.Change, 0),
+		CreatedBy:   item.CreatedBy,
+		AppliedBy:   item.AppliedBy,
+		Error:       item.Error,
+		ExpireTime:  item.ExpireTime.Format(constdef.TimeFormat),
+		CreatedTime: item.CreatedTime.Format(constdef.TimeFormat),
+	}
+	_ = json.Unmarshal([]byte(item.Changes), &info.Changes)
+	if item.AppliedBy > 0 {
+		info.AppliedTime = item.AppliedTime.Format(constdef.TimeFormat)
+	}
+	if user, ok := userMap[item.CreatedBy]; ok {
+		info.CreatedByName = user.Username
+	}
+	if user, ok := userMap[item.AppliedBy]; ok {
+		info.AppliedByName = user.Username
+	}
+	return info
+}
@@ -0,0 +1,168 @@
+package discovery
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/gateway"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/probe"
+)
+
+const (
+	labelPrefix   = "__meta_edgex_"
+	scanBatchSize = 500
+)
+
+// Prometheus标签名只允许[a-zA-Z0-9_]
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// TargetGroup Prometheus http_sd_configs的一组target
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// PrometheusTargets 返回全部未删除且探测可达的edgex, 需携带 Authorization: Bearer <[Discovery] Token>
+func PrometheusTargets(c *gin.Context) {
+
+	// Step1. 校验token
+	if !checkToken(c) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Step2. 扫描edgex
+	edgexList := make([]*dal.EdgexServiceItem, 0)
+	userIDs := make([]int64, 0)
+	var lastID int64
+	for {
+		itemList, err := dal.ScanEdgex(lastID, scanBatchSize)
+		if err != nil {
+			logs.Error("[PrometheusTargets] scan edgex failed: err=%v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		if len(itemList) == 0 {
+			break
+		}
+		for _, item := range itemList {
+			if item.Status != dal.EdgexActive {
+				continue
+			}
+			edgexList = append(edgexList, item)
+			userIDs = append(userIDs, item.UserID)
+		}
+		lastID = itemList[len(itemList)-1].ID
+	}
+
+	userMap, err := dal.GetEdgexUserMapByIDs(userIDs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	// Step3. 组装target
+	groupList := make([]*TargetGroup, 0, len(edgexList))
+	for _, item := range edgexList {
+		group, err := buildTargetGroup(item, userMap[item.UserID])
+		if err != nil {
+			logs.Warn("[PrometheusTargets] skip invalid address: edgex_id=%v, address=%v, err=%v", item.ID, item.Address, err)
+			continue
+		}
+		groupList = append(groupList, group)
+	}
+	c.JSON(http.StatusOK, groupList)
+}
+
+func checkToken(c *gin.Context) bool {
+	token := config.SDConf.Token
+	if token == "" {
+		return false
+	}
+	auth := c.GetHeader("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) == 1
+}
+
+func buildTargetGroup(item *dal.EdgexServiceItem, owner *dal.EdgexUser) (*TargetGroup, error) {
+	u, err := probe.ParseAddress(gateway.Address(item))
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{
+		labelPrefix + "id":      strconv.FormatInt(item.ID, 10),
+		labelPrefix + "prefix":  item.Prefix,
+		labelPrefix + "name":    item.EdgexName,
+		labelPrefix + "org_id":  strconv.FormatInt(item.OrgID, 10),
+		labelPrefix + "address": item.Address,
+		labelPrefix + "status":  "active",
+		labelPrefix + "owner":   "",
+		labelPrefix + "tags":    tagsLabel(item.Extra),
+		"__scheme__":            u.Scheme,
+	}
+	if owner != nil {
+		labels[labelPrefix+"owner"] = owner.Username
+	}
+	if u.Path != "" {
+		labels[labelPrefix+"base_path"] = u.Path
+	}
+
+	// location e.g. {"province":"江苏","city":"南京市"} -> __meta_edgex_location_province
+	location := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(item.Location), &location); err == nil {
+		for key, value := range location {
+			if str, ok := value.(string); ok {
+				labels[labelPrefix+"location_"+invalidLabelChars.ReplaceAllString(key, "_")] = str
+			}
+		}
+	}
+
+	return &TargetGroup{
+		Targets: []string{u.Host},
+		Labels:  labels,
+	}, nil
+}
+
+// tagsLabel 取extra.tags(字符串数组或逗号分隔字符串), 按Prometheus惯例以逗号包围, e.g. ",prod,shanghai,"
+func tagsLabel(extra string) string {
+	var v struct {
+		Tags interface{} `json:"tags"`
+	}
+	if err := json.Unmarshal([]byte(extra), &v); err != nil {
+		return ""
+	}
+
+	tags := make([]string, 0)
+	switch value := v.Tags.(type) {
+	case string:
+		tags = strings.Split(value, ",")
+	case []interface{}:
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				tags = append(tags, str)
+			}
+		}
+	}
+
+	cleaned := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	if len(cleaned) == 0 {
+		return ""
+	}
+	return "," + strings.Join(cleaned, ",") + ","
+}
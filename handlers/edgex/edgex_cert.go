@@ -0,0 +1,79 @@
+package edgex
+
+import (
+	"math"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/constdef"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+const defaultCertWarnDays = 30
+
+// ExpiringCertParams ...
+type ExpiringCertParams struct {
+	Days   int `form:"days" json:"days"` // 默认[Gateway] CertWarnDays
+	Offset int `form:"offset" json:"offset"`
+	Count  int `form:"count" json:"count"`
+}
+
+// GetExpiringCerts 证书在days天内过期(含已过期)的edgex, 按过期时间升序
+func GetExpiringCerts(c *gin.Context) (out *resp.JSONOutput) {
+
+	// Step1. checkParams
+	params := ExpiringCertParams{}
+	if err := c.Bind(&params); err != nil {
+		logs.Warn("[GetExpiringCerts] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	if params.Days <= 0 {
+		params.Days = config.GatewayConf.CertWarnDays
+	}
+	if params.Days <= 0 {
+		params.Days = defaultCertWarnDays
+	}
+	if params.Count <= 0 || params.Count > 100 {
+		params.Count = 20
+	}
+
+	// Step2. list
+	now := time.Now()
+	certList, err := dal.GetExpiringCertList(now.AddDate(0, 0, params.Days), params.Offset, params.Count)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	edgexIDs := make([]int64, 0, len(certList))
+	for _, cert := range certList {
+		edgexIDs = append(edgexIDs, cert.EdgexID)
+	}
+	edgexMap, err := dal.GetEdgexMapByIDs(edgexIDs)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+
+	infoList := make([]*model.CertExpiryInfo, 0, len(certList))
+	for _, cert := range certList {
+		info := &model.CertExpiryInfo{
+			EdgexID:     cert.EdgexID,
+			Kind:        cert.Kind,
+			Subject:     cert.Subject,
+			Issuer:      cert.Issuer,
+			NotAfter:    cert.NotAfter.Format(constdef.TimeFormat),
+			DaysLeft:    int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+			CheckedTime: cert.CheckedTime.Format(constdef.TimeFormat),
+		}
+		if item, ok := edgexMap[cert.EdgexID]; ok {
+			info.EdgexName = item.EdgexName
+			info.Prefix = item.Prefix
+			info.Address = item.Address
+			info.UserID = item.UserID
+		}
+		infoList = append(infoList, info)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, infoList)
+}
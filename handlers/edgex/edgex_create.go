@@ -6,23 +6,36 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/attribute"
 	"github.com/tdycwym/edgex_admin/caller"
 	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/gateway"
 	"github.com/tdycwym/edgex_admin/logs"
 	"github.com/tdycwym/edgex_admin/middleware/session"
 	"github.com/tdycwym/edgex_admin/resp"
 )
 
+// prefixRegexp edgex网关前缀规则, 创建/更新/导入共用
+var prefixRegexp = regexp.MustCompile("^[a-z_-]+$")
+
 // CreateEdgexParams ...
 type CreateEdgexParams struct {
 	UserID      int64
 	Username    string
+	OrgID       int64  `form:"org_id" json:"org_id"`
 	EdgexName   string `form:"edgex_name" json:"edgex_name" binding:"required"`
 	Prefix      string `form:"prefix" json:"prefix" binding:"required"`
 	Description string `form:"description" json:"description" binding:"required"`
 	Address     string `form:"address" json:"address"`
 	Location    string `form:"location" json:"location"`
 	Extra       string `form:"extra" json:"extra"`
+	DryRun      bool   `form:"dry_run" json:"dry_run"` // 只校验参数并探测地址, 不写数据库
+	// TLS选项, 客户端证书通过凭证接口配置
+	Scheme        string `form:"scheme" json:"scheme"`
+	TLSCA         string `form:"tls_ca" json:"tls_ca"`
+	TLSServerName string `form:"tls_server_name" json:"tls_server_name"`
+	TLSSkipVerify bool   `form:"tls_skip_verify" json:"tls_skip_verify"`
 }
 
 type createEdgexHandler struct {
@@ -43,13 +56,37 @@ func CreateEdgex(c *gin.Context) (out *resp.JSONOutput) {
 
 	// Step1. checkParams
 	err := h.CheckParams()
+	if h.Params.DryRun {
+		return h.DryRun(err)
+	}
 	if err != nil {
 		logs.Warn("[CreateEdgex] params-err: err=%v", err)
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
 
-	// Step2. createEdgexAndFollow
+	// Step2. validate extra
+	err = validateExtra(h.Params.OrgID, h.Params.Extra)
+	if errList, ok := err.(attribute.ValidationErrors); ok {
+		logs.Warn("[CreateEdgex] extra-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeExtraInvalid, errList)
+	}
+	if err != nil {
+		logs.Warn("[CreateEdgex] validate extra failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+
+	// Step3. 探测地址
+	if report := checkAddress(c, h.ConvertEdgexItem(h.Params)); report != nil {
+		logs.Warn("[CreateEdgex] address unreachable: address=%v, err=%v", h.Params.Address, report.Error())
+		return resp.SampleJSON(c, resp.RespCodeUnreachable, report)
+	}
+
+	// Step4. createEdgexAndFollow
 	err = h.Process()
+	if dupErr, ok := dal.AsDuplicateError(err); ok {
+		logs.Warn("[CreateEdgex] duplicate: err=%v", err)
+		return duplicateJSON(c, dupErr)
+	}
 	if err != nil {
 		logs.Warn("[CreateEdgex] params-err: err=%v", err)
 		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
@@ -66,23 +103,46 @@ func (h *createEdgexHandler) CheckParams() error {
 		return err
 	}
 
-	if match, _ := regexp.MatchString("^[a-z_-]+$", h.Params.Prefix); !match {
+	if !prefixRegexp.MatchString(h.Params.Prefix) {
 		logs.Error("[createEdgexHandler-checkParams] params-err: prefix=%v", h.Params.Prefix)
 		return fmt.Errorf("prefix is invalid: prefix=%v", h.Params.Prefix)
 	}
 
+	if err = gateway.Validate(h.ConvertEdgexItem(h.Params)); err != nil {
+		logs.Error("[createEdgexHandler-checkParams] params-err: address=%v, err=%v", h.Params.Address, err)
+		return err
+	}
+
 	h.Params.UserID = session.GetSessionUserID(h.Ctx)
 	h.Params.Username = session.GetSessionUsername(h.Ctx)
 	return nil
 }
 
+// DryRun 汇总参数校验、extra校验、prefix占用及地址探测结果
+func (h *createEdgexHandler) DryRun(checkErr error) *resp.JSONOutput {
+	report := newDryRunReport()
+	if checkErr != nil {
+		addDryRunErr(report, "params error: %v", checkErr)
+	}
+	prefix := h.Params.Prefix
+	if !prefixRegexp.MatchString(prefix) {
+		prefix = ""
+	}
+	err := dryRunCheck(h.Ctx, report, 0, h.Params.OrgID, prefix, h.Params.Extra, h.ConvertEdgexItem(h.Params))
+	if err != nil {
+		logs.Warn("[createEdgexHandler-DryRun] check failed: err=%v", err)
+		return resp.SampleJSON(h.Ctx, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(h.Ctx, resp.RespCodeSuccess, report)
+}
+
 func (h *createEdgexHandler) Process() (err error) {
 	edgex := h.ConvertEdgexItem(h.Params)
 
 	db := caller.EdgexDB.Begin()
 	defer func() {
 		if err != nil {
-			db.Callback()
+			db.Rollback()
 		} else {
 			db.Commit()
 		}
@@ -106,19 +166,29 @@ func (h *createEdgexHandler) Process() (err error) {
 		logs.Error("[createEdgexHandler-Process] AddEdgexRelatedUser Failed: item=%+v, err=%+v", item, err)
 		return
 	}
+	err = event.Emit(db, event.TypeEdgexCreated, edgex.ID, h.Params.UserID, event.EdgexData(edgex))
+	if err != nil {
+		logs.Error("[createEdgexHandler-Process] emit event Failed: edgex_id=%+v, err=%+v", edgex.ID, err)
+		return
+	}
 	return
 }
 
 func (h *createEdgexHandler) ConvertEdgexItem(params CreateEdgexParams) *dal.EdgexServiceItem {
 	return &dal.EdgexServiceItem{
-		UserID:       params.UserID,
-		EdgexName:    params.EdgexName,
-		Prefix:       params.Prefix,
-		Description:  params.Description,
-		Location:     params.Location,
-		Extra:        params.Extra,
-		Address:      params.Address,
-		CreatedTime:  time.Now(),
-		ModifiedTime: time.Now(),
+		UserID:        params.UserID,
+		OrgID:         params.OrgID,
+		EdgexName:     params.EdgexName,
+		Prefix:        params.Prefix,
+		Description:   params.Description,
+		Location:      params.Location,
+		Extra:         params.Extra,
+		Address:       params.Address,
+		Scheme:        params.Scheme,
+		TLSCA:         params.TLSCA,
+		TLSServerName: params.TLSServerName,
+		TLSSkipVerify: params.TLSSkipVerify,
+		CreatedTime:   time.Now(),
+		ModifiedTime:  time.Now(),
 	}
 }
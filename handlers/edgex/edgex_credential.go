@@ -0,0 +1,200 @@
+package edgex
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/constdef"
+	"github.com/tdycwym/edgex_admin/credential"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+// CredentialParams 凭证字段只写, 任何接口都不会返回
+type CredentialParams struct {
+	UserID      int64
+	EdgexID     int64  `form:"edgex_id" json:"edgex_id" binding:"required"`
+	Type        string `form:"type" json:"type"`
+	Token       string `form:"token" json:"token"`
+	Username    string `form:"username" json:"username"`
+	Password    string `form:"password" json:"password"`
+	CertPEM     string `form:"cert_pem" json:"cert_pem"`
+	KeyPEM      string `form:"key_pem" json:"key_pem"`
+	ConsulToken string `form:"consul_token" json:"consul_token"`
+}
+
+type credentialHandler struct {
+	Ctx    *gin.Context
+	Params CredentialParams
+	Edgex  *dal.EdgexServiceItem
+}
+
+func buildCredentialHandler(c *gin.Context) *credentialHandler {
+	return &credentialHandler{
+		Ctx: c,
+	}
+}
+
+// CheckParams 仅edgex创建人和管理员可以查看和修改凭证; 返回需要响应的错误码
+func (h *credentialHandler) CheckParams() (code resp.ErrorCode, err error) {
+
+	err = h.Ctx.Bind(&h.Params)
+	if err != nil {
+		// 不输出参数, 避免凭证写入日志
+		logs.Error("[credentialHandler-checkParams] bind params failed")
+		return resp.RespCodeParamsError, errors.New("bind params failed")
+	}
+	if h.Params.EdgexID <= 0 {
+		return resp.RespCodeParamsError, fmt.Errorf("edgex_id is invalid: edgex_id=%v", h.Params.EdgexID)
+	}
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
+
+	h.Edgex, err = dal.GetEdgexByID(h.Params.EdgexID)
+	if err != nil {
+		return resp.RespDatabaseError, err
+	}
+	if h.Edgex == nil || h.Edgex.Deleted != 0 {
+		return resp.RespCodeParamsError, fmt.Errorf("edgex is not exist: edgex_id=%v", h.Params.EdgexID)
+	}
+	if h.Edgex.UserID != h.Params.UserID {
+		user, err := dal.GetEdgexUserByID(h.Params.UserID)
+		if err != nil {
+			return resp.RespDatabaseError, err
+		}
+		if user == nil || user.Role != dal.RoleAdmin {
+			return resp.RespCodeNoPermission, fmt.Errorf("permission denied: user_id=%v, edgex_id=%v", h.Params.UserID, h.Params.EdgexID)
+		}
+	}
+	return resp.RespCodeSuccess, nil
+}
+
+// GetEdgexCredential 返回凭证类型等元信息, 未配置时data为null
+func GetEdgexCredential(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildCredentialHandler(c)
+
+	// Step1. checkParams
+	code, err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[GetEdgexCredential] params-err: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+
+	// Step2. get
+	item, err := dal.GetCredentialByEdgexID(h.Params.EdgexID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if item == nil {
+		return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+	}
+	info, err := buildCredentialInfo(item)
+	if err != nil {
+		logs.Error("[GetEdgexCredential] decrypt credential failed: edgex_id=%v, err=%v", h.Params.EdgexID, err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, info)
+}
+
+// SaveEdgexCredential 新增或覆盖edgex凭证
+func SaveEdgexCredential(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildCredentialHandler(c)
+
+	// Step1. checkParams
+	code, err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[SaveEdgexCredential] params-err: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+	cred := &credential.Credential{
+		Type:        h.Params.Type,
+		Token:       h.Params.Token,
+		Username:    h.Params.Username,
+		Password:    h.Params.Password,
+		CertPEM:     h.Params.CertPEM,
+		KeyPEM:      h.Params.KeyPEM,
+		ConsulToken: h.Params.ConsulToken,
+	}
+	if err = cred.Validate(); err != nil {
+		logs.Warn("[SaveEdgexCredential] credential is invalid: edgex_id=%v, type=%s, err=%v", h.Params.EdgexID, cred.Type, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+
+	// Step2. save
+	db := caller.EdgexDB.Begin()
+	err = credential.Save(db, h.Params.EdgexID, h.Params.UserID, cred)
+	if err != nil {
+		db.Rollback()
+	} else {
+		err = db.Commit().Error
+	}
+	if errors.Is(err, credential.ErrNoMasterKey) {
+		return resp.SampleJSON(c, resp.RespCodeServerException, err.Error())
+	}
+	if err != nil {
+		logs.Error("[SaveEdgexCredential] save credential failed: edgex_id=%v, err=%v", h.Params.EdgexID, err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	logs.Info("[SaveEdgexCredential] credential saved: edgex_id=%v, type=%s, user_id=%v", h.Params.EdgexID, cred.Type, h.Params.UserID)
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// DeleteEdgexCredential ...
+func DeleteEdgexCredential(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildCredentialHandler(c)
+
+	// Step1. checkParams
+	code, err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[DeleteEdgexCredential] params-err: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+
+	// Step2. delete
+	db := caller.EdgexDB.Begin()
+	err = credential.Delete(db, h.Params.EdgexID)
+	if err != nil {
+		db.Rollback()
+	} else {
+		err = db.Commit().Error
+	}
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	logs.Info("[DeleteEdgexCredential] credential deleted: edgex_id=%v, user_id=%v", h.Params.EdgexID, h.Params.UserID)
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+func buildCredentialInfo(item *dal.EdgexCredential) (*model.CredentialInfo, error) {
+	cred, err := credential.Decrypt(item)
+	if err != nil {
+		return nil, err
+	}
+	info := &model.CredentialInfo{
+		EdgexID:        item.EdgexID,
+		Type:           cred.Type,
+		HasConsulToken: cred.ConsulToken != "",
+		UpdatedBy:      item.UpdatedBy,
+		ModifiedTime:   item.ModifiedTime.Format(constdef.TimeFormat),
+	}
+	if cred.Type == credential.TypeBasic {
+		info.Username = cred.Username
+	}
+	if cred.CertPEM != "" {
+		if cert, err := cred.Certificate(); err == nil {
+			info.CertSubject = cert.Leaf.Subject.String()
+			info.CertNotAfter = cert.Leaf.NotAfter.Format(constdef.TimeFormat)
+		}
+	}
+	if user, err := dal.GetEdgexUserByID(item.UpdatedBy); err == nil && user != nil {
+		info.UpdatedByName = user.Username
+	}
+	return info, nil
+}
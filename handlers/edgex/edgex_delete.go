@@ -5,19 +5,25 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/credential"
 	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/gateway"
 	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
 	"github.com/tdycwym/edgex_admin/resp"
 )
 
 // DeleteEdgexParams ...
 type DeleteEdgexParams struct {
+	UserID  int64
 	EdgexID int64 `form:"edgex_id" json:"edgex_id" binding:"required"`
 }
 
 type deleteEdgexHandler struct {
 	Ctx    *gin.Context
 	Params DeleteEdgexParams
+	Edgex  *dal.EdgexServiceItem
 }
 
 func buildDeleteEdgexHandler(c *gin.Context) *deleteEdgexHandler {
@@ -38,12 +44,24 @@ func DeleteEdgex(c *gin.Context) (out *resp.JSONOutput) {
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
 
-	// Step2. update deleted
+	// Step2. 获取edgex
+	h.Edgex, err = dal.GetEdgexByID(h.Params.EdgexID)
+	if err != nil {
+		logs.Warn("[DeleteEdgex] get edgex failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if h.Edgex == nil || h.Edgex.Deleted != 0 {
+		logs.Warn("[DeleteEdgex] edgex is Not Exsit: edgex_id=%v", h.Params.EdgexID)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step3. update deleted
 	err = h.Process()
 	if err != nil {
 		logs.Warn("[DeleteEdgex] params-err: err=%v", err)
 		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
 	}
+	gateway.Forget(h.Params.EdgexID)
 
 	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
 }
@@ -60,6 +78,8 @@ func (h *deleteEdgexHandler) CheckParams() error {
 		logs.Error("[deleteEdgexHandler-checkParams] params-err: edgex_id=%v", h.Params.EdgexID)
 		return fmt.Errorf("edgex_id is invalid: edgex_id=%v", h.Params.EdgexID)
 	}
+
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
 	return nil
 }
 
@@ -67,11 +87,33 @@ func (h *deleteEdgexHandler) Process() (err error) {
 
 	fieldsMap := map[string]interface{}{"deleted": 1}
 
-	err = dal.UpdateEdgex(caller.EdgexDB, h.Params.EdgexID, fieldsMap)
+	db := caller.EdgexDB.Begin()
+	defer func() {
+		if err != nil {
+			db.Rollback()
+		} else {
+			db.Commit()
+		}
+	}()
+	err = dal.UpdateEdgex(db, h.Params.EdgexID, fieldsMap)
 	if err != nil {
 		logs.Error("[deleteEdgexHandler-process] UpdateEdgex Failed: edgex_id=%+v, fileds=%+v, err=%+v",
 			h.Params.EdgexID, fieldsMap, err)
 		return
 	}
+	// 凭证与证书跟踪随edgex一起删除, 不保留
+	err = credential.Delete(db, h.Params.EdgexID)
+	if err != nil {
+		return
+	}
+	err = dal.DeleteEdgexCert(db, h.Params.EdgexID, "")
+	if err != nil {
+		return
+	}
+	err = event.Emit(db, event.TypeEdgexDeleted, h.Params.EdgexID, h.Params.UserID, event.EdgexData(h.Edgex))
+	if err != nil {
+		logs.Error("[deleteEdgexHandler-process] emit event Failed: edgex_id=%+v, err=%+v", h.Params.EdgexID, err)
+		return
+	}
 	return
 }
@@ -0,0 +1,100 @@
+package edgex
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/attribute"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/credential"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/gateway"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/probe"
+)
+
+func newDryRunReport() *model.DryRunReport {
+	return &model.DryRunReport{
+		Errors: make([]string, 0),
+	}
+}
+
+// probeAddress 按目标地址与TLS选项探测edgex, 整体耗时不超过4个探测步骤的超时之和; 已有edgex使用其凭证
+func probeAddress(c *gin.Context, target *dal.EdgexServiceItem) *probe.Report {
+	address := gateway.Address(target)
+	var cred *credential.Credential
+	var err error
+	if target.ID > 0 {
+		cred, err = credential.Load(target.ID)
+	}
+	// 目标选项可能尚未保存, 使用不缓存的连接
+	probeItem := *target
+	probeItem.ID = 0
+	var prober *probe.Prober
+	if err == nil {
+		prober, err = gateway.Prober(&probeItem, cred, time.Duration(config.ProbeConf.Timeout)*time.Millisecond)
+	}
+	if err != nil {
+		logs.Error("[probeAddress] build prober failed: edgex_id=%v, err=%v", target.ID, err)
+		return &probe.Report{Address: address, DNS: &probe.StepResult{Error: err.Error()}}
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*prober.Timeout)
+	defer cancel()
+
+	report := prober.Probe(ctx, address)
+	logs.Info("[probeAddress] address=%s, reachable=%v, version=%s, err=%s",
+		address, report.Reachable, report.Version, report.Error())
+	return report
+}
+
+// checkAddress 开启RefuseUnreachable时探测地址, 返回nil表示无需拒绝
+func checkAddress(c *gin.Context, target *dal.EdgexServiceItem) *probe.Report {
+	if target.Address == "" || !config.ProbeConf.RefuseUnreachable {
+		return nil
+	}
+	report := probeAddress(c, target)
+	if report.Reachable {
+		return nil
+	}
+	return report
+}
+
+func addDryRunErr(report *model.DryRunReport, format string, args ...interface{}) {
+	report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+}
+
+// dryRunCheck 参数校验之外的公共检查: extra schema, prefix占用, TLS选项, 地址探测
+func dryRunCheck(c *gin.Context, report *model.DryRunReport, edgexID int64, orgID int64, prefix string, extra string, target *dal.EdgexServiceItem) error {
+	if err := validateExtra(orgID, extra); err != nil {
+		if _, ok := err.(attribute.ValidationErrors); !ok {
+			return err
+		}
+		addDryRunErr(report, "extra is invalid: %v", err)
+	}
+
+	if prefix != "" {
+		existMap, err := dal.GetEdgexMapByPrefixes(caller.EdgexDB, []string{prefix})
+		if err != nil {
+			return err
+		}
+		if exist := existMap[prefix]; exist != nil && exist.ID != edgexID {
+			addDryRunErr(report, "prefix already exists: edgex_id=%v", exist.ID)
+		}
+	}
+
+	if err := gateway.Validate(target); err != nil {
+		addDryRunErr(report, "address or tls options are invalid: %v", err)
+	} else if target.Address != "" {
+		report.Probe = probeAddress(c, target)
+		if !report.Probe.Reachable && config.ProbeConf.RefuseUnreachable {
+			addDryRunErr(report, "address is unreachable: %s", report.Probe.Error())
+		}
+	}
+
+	report.Valid = len(report.Errors) == 0
+	return nil
+}
@@ -0,0 +1,131 @@
+package edgex
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+const (
+	exportPageSize = 500
+	exportMaxCount = 20000
+)
+
+// ExportEdgexParams 其余过滤条件与SearchEdgexParams一致
+type ExportEdgexParams struct {
+	Format string `form:"format" json:"format"`
+}
+
+type exportEdgexHandler struct {
+	Ctx        *gin.Context
+	Params     ExportEdgexParams
+	Search     *searchEdgexHandler
+	RecordList []*model.EdgexRecord
+}
+
+func buildExportEdgexHandler(c *gin.Context) *exportEdgexHandler {
+	return &exportEdgexHandler{
+		Ctx:        c,
+		Search:     buildSearchEdgexHandler(c),
+		RecordList: make([]*model.EdgexRecord, 0),
+	}
+}
+
+// ExportEdgex 按搜索条件导出edgex, 支持csv/json/yaml;
+// 直接写文件内容, 不经过resp.JSONOutPutWrapper
+func ExportEdgex(c *gin.Context) {
+
+	h := buildExportEdgexHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[ExportEdgex] params-err: err=%v", err)
+		resp.SampleJSON(c, resp.RespCodeParamsError, nil).Write()
+		return
+	}
+
+	// Step2. search
+	err = h.Process()
+	if err != nil {
+		logs.Warn("[ExportEdgex] search failed: err=%v", err)
+		resp.SampleJSON(c, resp.RespDatabaseError, nil).Write()
+		return
+	}
+
+	// Step3. encode
+	data, err := encodeRecords(h.Params.Format, h.RecordList)
+	if err != nil {
+		logs.Error("[ExportEdgex] encode failed: format=%v, err=%v", h.Params.Format, err)
+		resp.SampleJSON(c, resp.RespCodeServerException, nil).Write()
+		return
+	}
+
+	filename := fmt.Sprintf("edgex_%s.%s", time.Now().Format("20060102150405"), h.Params.Format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Data(http.StatusOK, recordContentTypes[h.Params.Format], data)
+}
+
+// CheckParams ...
+func (h *exportEdgexHandler) CheckParams() error {
+
+	err := h.Ctx.Bind(&h.Params)
+	if err != nil {
+		logs.Error("[exportEdgexHandler-checkParams] params-err: err=%v", err)
+		return err
+	}
+
+	if h.Params.Format == "" {
+		h.Params.Format = FormatCSV
+	}
+	format := normalizeFormat(h.Params.Format)
+	if format == "" {
+		return fmt.Errorf("format is invalid: format=%s", h.Params.Format)
+	}
+	h.Params.Format = format
+
+	return h.Search.CheckParams()
+}
+
+// Process 分页拉取全部匹配的记录, count未指定时最多导出exportMaxCount条
+func (h *exportEdgexHandler) Process() (err error) {
+
+	limit := exportMaxCount
+	if h.Ctx.Query("count") != "" && h.Search.Params.Count < limit {
+		limit = h.Search.Params.Count
+	}
+
+	h.Search.Params.Count = exportPageSize
+	for len(h.RecordList) < limit {
+		if err = h.Search.Process(); err != nil {
+			return
+		}
+		for _, item := range h.Search.EdgexList {
+			h.RecordList = append(h.RecordList, &model.EdgexRecord{
+				EdgexID:     item.EdgexID,
+				Prefix:      item.Prefix,
+				EdgexName:   item.EdgexName,
+				OrgID:       item.OrgID,
+				Address:     item.Address,
+				Status:      item.Status,
+				Description: item.Description,
+				Location:    item.Location,
+				Extra:       item.Extra,
+				CreatedTime: item.CreatedTime,
+			})
+		}
+		if len(h.Search.EdgexList) < exportPageSize {
+			break
+		}
+		h.Search.Params.Offset += exportPageSize
+	}
+	if len(h.RecordList) > limit {
+		h.RecordList = h.RecordList[:limit]
+	}
+	return
+}
@@ -0,0 +1,31 @@
+package edgex
+
+import (
+	"github.com/tdycwym/edgex_admin/attribute"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+// getExtraSchema 组织生效的schema, 未定义时返回nil
+func getExtraSchema(orgID int64) (*attribute.Schema, error) {
+	schemaItem, err := dal.GetEffectiveAttributeSchema(orgID)
+	if err != nil || schemaItem == nil {
+		return nil, err
+	}
+	s, err := attribute.ParseSchema(schemaItem.Schema)
+	if err != nil {
+		logs.Error("[getExtraSchema] stored schema is invalid: org_id=%v, err=%v", schemaItem.OrgID, err)
+		return nil, err
+	}
+	return s, nil
+}
+
+// validateExtra 按组织生效的schema校验extra, 未定义schema时不做限制;
+// 校验不通过时返回attribute.ValidationErrors
+func validateExtra(orgID int64, extra string) error {
+	s, err := getExtraSchema(orgID)
+	if err != nil || s == nil {
+		return err
+	}
+	return s.ValidateExtra(extra)
+}
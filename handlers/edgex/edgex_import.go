@@ -0,0 +1,410 @@
+package edgex
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/tdycwym/edgex_admin/attribute"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/gateway"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+	"github.com/tdycwym/edgex_admin/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	importMaxRows          = 2000
+	importProbeConcurrency = 8 // 开启RefuseUnreachable时并发探测的行数
+)
+
+// ImportEdgexParams 文件通过multipart字段file上传, 或直接作为请求体
+type ImportEdgexParams struct {
+	UserID   int64
+	Username string
+	Format   string `form:"format" json:"format"`
+	Upsert   bool   `form:"upsert" json:"upsert"` // prefix已存在时更新, 否则报错
+}
+
+// ImportRowError 单行错误, row从1开始
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Prefix  string `json:"prefix"`
+	Message string `json:"message"`
+}
+
+// ImportEdgexResult ...
+type ImportEdgexResult struct {
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Errors  []*ImportRowError `json:"errors"`
+}
+
+type importEdgexHandler struct {
+	Ctx        *gin.Context
+	Params     ImportEdgexParams
+	RecordList []*model.EdgexRecord
+	ExistMap   map[string]*dal.EdgexServiceItem
+	Result     *ImportEdgexResult
+}
+
+func buildImportEdgexHandler(c *gin.Context) *importEdgexHandler {
+	return &importEdgexHandler{
+		Ctx:    c,
+		Result: &ImportEdgexResult{Errors: make([]*ImportRowError, 0)},
+	}
+}
+
+// ImportEdgex 批量导入edgex, 任意一行校验失败则整体不写入
+func ImportEdgex(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildImportEdgexHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[ImportEdgex] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+
+	// Step2. 逐行校验
+	err = h.Validate()
+	if err != nil {
+		logs.Warn("[ImportEdgex] validate failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if len(h.Result.Errors) > 0 {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, h.Result)
+	}
+
+	// Step3. 单事务写入
+	err = h.Process()
+	if dupErr, ok := dal.AsDuplicateError(err); ok {
+		logs.Warn("[ImportEdgex] duplicate: err=%v", err)
+		return duplicateJSON(c, dupErr)
+	}
+	if err != nil {
+		logs.Warn("[ImportEdgex] import failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, h.Result)
+}
+
+// CheckParams ...
+func (h *importEdgexHandler) CheckParams() error {
+
+	// 请求体可能是json/yaml文件本身, 只从query和表单取参数
+	err := h.Ctx.ShouldBindWith(&h.Params, binding.Form)
+	if err != nil {
+		logs.Error("[importEdgexHandler-checkParams] params-err: err=%v", err)
+		return err
+	}
+
+	var reader io.Reader = h.Ctx.Request.Body
+	if fileHeader, err := h.Ctx.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+		if h.Params.Format == "" {
+			h.Params.Format = strings.TrimPrefix(filepath.Ext(fileHeader.Filename), ".")
+		}
+	}
+
+	format := normalizeFormat(h.Params.Format)
+	if format == "" {
+		return fmt.Errorf("format is invalid: format=%s", h.Params.Format)
+	}
+	h.Params.Format = format
+
+	h.RecordList, err = decodeRecords(format, reader)
+	if err != nil {
+		return fmt.Errorf("decode %s failed: %v", format, err)
+	}
+	if len(h.RecordList) == 0 {
+		return fmt.Errorf("no records found")
+	}
+	if len(h.RecordList) > importMaxRows {
+		return fmt.Errorf("too many records: count=%d, max=%d", len(h.RecordList), importMaxRows)
+	}
+
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
+	h.Params.Username = session.GetSessionUsername(h.Ctx)
+	h.Result.Total = len(h.RecordList)
+	return nil
+}
+
+// Validate 校验规则与CreateEdgex一致, 结果写入h.Result.Errors
+func (h *importEdgexHandler) Validate() (err error) {
+
+	prefixes := make([]string, 0, len(h.RecordList))
+	for _, record := range h.RecordList {
+		prefixes = append(prefixes, record.Prefix)
+	}
+	h.ExistMap, err = dal.GetEdgexMapByPrefixes(caller.EdgexDB, prefixes)
+	if err != nil {
+		return
+	}
+
+	// 仅能更新自己创建的edgex, 管理员不受限制
+	user, err := dal.GetEdgexUserByID(h.Params.UserID)
+	if err != nil {
+		return
+	}
+	isAdmin := user != nil && user.Role == dal.RoleAdmin
+
+	seen := make(map[string]int)
+	schemaMap := make(map[int64]*attribute.Schema)
+	probeRows := make(map[int]*dal.EdgexServiceItem)
+	for i, record := range h.RecordList {
+		row := i + 1
+		addErr := func(format string, args ...interface{}) {
+			h.Result.Errors = append(h.Result.Errors, &ImportRowError{
+				Row:     row,
+				Prefix:  record.Prefix,
+				Message: fmt.Sprintf(format, args...),
+			})
+		}
+
+		if !prefixRegexp.MatchString(record.Prefix) {
+			addErr("prefix is invalid: prefix=%v", record.Prefix)
+			continue
+		}
+		if firstRow, ok := seen[record.Prefix]; ok {
+			addErr("prefix is duplicated with row %d", firstRow)
+			continue
+		}
+		seen[record.Prefix] = row
+
+		exist := h.ExistMap[record.Prefix]
+		if exist != nil && !h.Params.Upsert {
+			addErr("prefix already exists: edgex_id=%v", exist.ID)
+			continue
+		}
+		if exist == nil && (record.EdgexName == "" || record.Description == "") {
+			addErr("edgex_name and description are required")
+			continue
+		}
+		if exist != nil && exist.UserID != h.Params.UserID && !isAdmin {
+			addErr("permission denied: edgex_id=%v", exist.ID)
+			continue
+		}
+
+		// 与CreateEdgex/UpdateEdgex一致, 地址变化时校验并探测
+		target := importTarget(exist, record)
+		if validateErr := gateway.Validate(target); validateErr != nil {
+			addErr("address is invalid: %v", validateErr)
+			continue
+		}
+		if exist == nil || target.Address != exist.Address {
+			probeRows[row] = target
+		}
+
+		orgID, extra := record.OrgID, record.Extra
+		if exist != nil {
+			if orgID == 0 {
+				orgID = exist.OrgID
+			}
+			if extra == "" {
+				extra = exist.Extra
+			}
+		}
+		s, ok := schemaMap[orgID]
+		if !ok {
+			s, err = getExtraSchema(orgID)
+			if err != nil {
+				return
+			}
+			schemaMap[orgID] = s
+		}
+		if s == nil {
+			continue
+		}
+		if validateErr := s.ValidateExtra(extra); validateErr != nil {
+			addErr("extra is invalid: %v", validateErr)
+			continue
+		}
+	}
+	if len(h.Result.Errors) > 0 {
+		return nil
+	}
+	h.probe(probeRows)
+	return nil
+}
+
+// probe 并发探测, 不可达的行写入h.Result.Errors
+func (h *importEdgexHandler) probe(probeRows map[int]*dal.EdgexServiceItem) {
+	mutex := sync.Mutex{}
+	sem := make(chan struct{}, importProbeConcurrency)
+	wg := sync.WaitGroup{}
+	for row, target := range probeRows {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(row int, target *dal.EdgexServiceItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			defer utils.RecoverPanic()
+			report := checkAddress(h.Ctx, target)
+			if report == nil {
+				return
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			h.Result.Errors = append(h.Result.Errors, &ImportRowError{
+				Row:     row,
+				Prefix:  target.Prefix,
+				Message: fmt.Sprintf("address is unreachable: %s", report.Error()),
+			})
+		}(row, target)
+	}
+	wg.Wait()
+	sort.Slice(h.Result.Errors, func(i, j int) bool {
+		return h.Result.Errors[i].Row < h.Result.Errors[j].Row
+	})
+}
+
+// importTarget 导入后的地址与TLS选项, 用于校验和探测
+func importTarget(exist *dal.EdgexServiceItem, record *model.EdgexRecord) *dal.EdgexServiceItem {
+	target := &dal.EdgexServiceItem{Prefix: record.Prefix}
+	if exist != nil {
+		copied := *exist
+		target = &copied
+	}
+	if record.Address != "" {
+		target.Address = record.Address
+	}
+	return target
+}
+
+// Process 新增记录同时关注, 更新记录同步edgex_name, 全部成功才提交
+func (h *importEdgexHandler) Process() (err error) {
+
+	db := caller.EdgexDB.Begin()
+	defer func() {
+		if err != nil {
+			db.Rollback()
+		} else {
+			db.Commit()
+		}
+	}()
+
+	for _, record := range h.RecordList {
+		exist := h.ExistMap[record.Prefix]
+		if exist == nil {
+			err = h.create(db, record)
+			if err != nil {
+				return
+			}
+			h.Result.Created++
+			continue
+		}
+		err = h.update(db, exist, record)
+		if err != nil {
+			return
+		}
+		h.Result.Updated++
+	}
+	return
+}
+
+func (h *importEdgexHandler) create(db *gorm.DB, record *model.EdgexRecord) (err error) {
+	edgex := &dal.EdgexServiceItem{
+		UserID:       h.Params.UserID,
+		OrgID:        record.OrgID,
+		EdgexName:    record.EdgexName,
+		Prefix:       record.Prefix,
+		Description:  record.Description,
+		Location:     record.Location,
+		Extra:        record.Extra,
+		Address:      record.Address,
+		CreatedTime:  time.Now(),
+		ModifiedTime: time.Now(),
+	}
+	err = dal.AddEdgex(db, edgex)
+	if err != nil {
+		logs.Error("[importEdgexHandler-create] AddEdgex Failed: edgex=%+v, err=%+v", edgex, err)
+		return
+	}
+	item := &dal.EdgexRelatedUser{
+		UserID:       h.Params.UserID,
+		Username:     h.Params.Username,
+		EdgexID:      edgex.ID,
+		EdgexName:    edgex.EdgexName,
+		Status:       dal.StatusFollow,
+		CreatedTime:  time.Now(),
+		ModifiedTime: time.Now(),
+	}
+	err = dal.AddEdgexRelatedUser(db, item)
+	if err != nil {
+		logs.Error("[importEdgexHandler-create] AddEdgexRelatedUser Failed: item=%+v, err=%+v", item, err)
+		return
+	}
+	err = event.Emit(db, event.TypeEdgexCreated, edgex.ID, h.Params.UserID, event.EdgexData(edgex))
+	if err != nil {
+		logs.Error("[importEdgexHandler-create] emit event Failed: edgex_id=%+v, err=%+v", edgex.ID, err)
+		return
+	}
+	return
+}
+
+// update 与UpdateEdgex一致, 空值字段保持不变
+func (h *importEdgexHandler) update(db *gorm.DB, exist *dal.EdgexServiceItem, record *model.EdgexRecord) (err error) {
+	fieldsMap := make(map[string]interface{})
+	if record.OrgID > 0 {
+		fieldsMap["org_id"] = record.OrgID
+	}
+	if record.EdgexName != "" {
+		fieldsMap["edgex_name"] = record.EdgexName
+	}
+	if record.Description != "" {
+		fieldsMap["description"] = record.Description
+	}
+	if record.Location != "" {
+		fieldsMap["location"] = record.Location
+	}
+	if record.Extra != "" {
+		fieldsMap["extra"] = record.Extra
+	}
+	if record.Address != "" {
+		fieldsMap["address"] = record.Address
+	}
+	if len(fieldsMap) == 0 {
+		return
+	}
+
+	err = dal.UpdateEdgex(db, exist.ID, fieldsMap)
+	if err != nil {
+		logs.Error("[importEdgexHandler-update] UpdateEdgex Failed: edgex_id=%+v, fileds=%+v, err=%+v", exist.ID, fieldsMap, err)
+		return
+	}
+	if record.EdgexName != "" && record.EdgexName != exist.EdgexName {
+		relatedFieldsMap := map[string]interface{}{"edgex_name": record.EdgexName}
+		err = dal.UpdateEdgexRelatedUserByEdgexID(db, exist.ID, relatedFieldsMap)
+		if err != nil {
+			logs.Error("[importEdgexHandler-update] UpdateEdgexRelatedUserByEdgexID Failed: edgex_id=%+v, err=%+v", exist.ID, err)
+			return
+		}
+	}
+	err = event.Emit(db, event.TypeEdgexUpdated, exist.ID, h.Params.UserID, event.EdgexUpdateData(exist, fieldsMap))
+	if err != nil {
+		logs.Error("[importEdgexHandler-update] emit event Failed: edgex_id=%+v, err=%+v", exist.ID, err)
+		return
+	}
+	return
+}
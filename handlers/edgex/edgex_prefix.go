@@ -0,0 +1,109 @@
+package edgex
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+const maxPrefixSuggestions = 5
+
+// prefix只允许[a-z_-], 候选后缀不能带数字
+var prefixSuffixes = []string{"gw", "edge", "node", "a", "b", "c", "d", "e", "x", "y", "z"}
+
+var prefixInvalidCharRegexp = regexp.MustCompile("[^a-z_-]+")
+
+// CheckPrefixParams ...
+type CheckPrefixParams struct {
+	Prefix string `form:"prefix" json:"prefix" binding:"required"`
+}
+
+type checkPrefixHandler struct {
+	Ctx    *gin.Context
+	Params CheckPrefixParams
+	Info   *model.PrefixCheckInfo
+}
+
+func buildCheckPrefixHandler(c *gin.Context) *checkPrefixHandler {
+	return &checkPrefixHandler{
+		Ctx: c,
+	}
+}
+
+// CheckPrefix 检查prefix是否合法/可用, 不可用时给出候选
+func CheckPrefix(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildCheckPrefixHandler(c)
+
+	// Step1. checkParams
+	err := h.Ctx.Bind(&h.Params)
+	if err != nil {
+		logs.Warn("[CheckPrefix] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step2. check
+	err = h.Process()
+	if err != nil {
+		logs.Warn("[CheckPrefix] check prefix failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, h.Info)
+}
+
+func (h *checkPrefixHandler) Process() (err error) {
+
+	prefix := h.Params.Prefix
+	h.Info = &model.PrefixCheckInfo{
+		Prefix:      prefix,
+		Valid:       prefixRegexp.MatchString(prefix),
+		Suggestions: make([]string, 0),
+	}
+
+	// 不合法时以清洗后的prefix为基础给出候选
+	base := prefix
+	if !h.Info.Valid {
+		base = strings.Trim(prefixInvalidCharRegexp.ReplaceAllString(strings.ToLower(prefix), "-"), "-_")
+		if base == "" {
+			return nil
+		}
+	}
+
+	candidates := []string{base}
+	for _, suffix := range prefixSuffixes {
+		candidates = append(candidates, base+"-"+suffix)
+	}
+	existMap, err := dal.GetEdgexMapByPrefixes(caller.EdgexDB, candidates)
+	if err != nil {
+		return
+	}
+
+	h.Info.Available = h.Info.Valid && existMap[prefix] == nil
+	if h.Info.Available {
+		return nil
+	}
+	for _, candidate := range candidates {
+		if candidate == prefix || existMap[candidate] != nil {
+			continue
+		}
+		h.Info.Suggestions = append(h.Info.Suggestions, candidate)
+		if len(h.Info.Suggestions) >= maxPrefixSuggestions {
+			break
+		}
+	}
+	return nil
+}
+
+// duplicateJSON 唯一键冲突统一返回RespCodeDuplicate及冲突字段
+func duplicateJSON(c *gin.Context, dupErr *dal.DuplicateError) *resp.JSONOutput {
+	return resp.SampleJSON(c, resp.RespCodeDuplicate, &model.DuplicateInfo{
+		Field: dupErr.Field,
+		Value: dupErr.Value,
+	})
+}
@@ -0,0 +1,141 @@
+package edgex
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tdycwym/edgex_admin/model"
+	"gopkg.in/yaml.v2"
+)
+
+// 导入/导出支持的格式
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+var recordContentTypes = map[string]string{
+	FormatCSV:  "text/csv; charset=utf-8",
+	FormatJSON: "application/json; charset=utf-8",
+	FormatYAML: "application/x-yaml; charset=utf-8",
+}
+
+// csv表头, 与model.EdgexRecord字段一一对应
+var recordCSVHeader = []string{
+	"edgex_id", "prefix", "edgex_name", "org_id", "address", "status",
+	"description", "location", "extra", "created_time",
+}
+
+// normalizeFormat 支持yml别名, 不合法时返回空字符串
+func normalizeFormat(format string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "yml" {
+		format = FormatYAML
+	}
+	if _, ok := recordContentTypes[format]; !ok {
+		return ""
+	}
+	return format
+}
+
+func encodeRecords(format string, recordList []*model.EdgexRecord) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(recordList, "", "  ")
+	case FormatYAML:
+		return yaml.Marshal(recordList)
+	case FormatCSV:
+		buf := &bytes.Buffer{}
+		w := csv.NewWriter(buf)
+		_ = w.Write(recordCSVHeader)
+		for _, record := range recordList {
+			_ = w.Write([]string{
+				strconv.FormatInt(record.EdgexID, 10),
+				record.Prefix,
+				record.EdgexName,
+				strconv.FormatInt(record.OrgID, 10),
+				record.Address,
+				strconv.FormatInt(int64(record.Status), 10),
+				record.Description,
+				record.Location,
+				record.Extra,
+				record.CreatedTime,
+			})
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	}
+	return nil, fmt.Errorf("format is invalid: format=%s", format)
+}
+
+func decodeRecords(format string, r io.Reader) (recordList []*model.EdgexRecord, err error) {
+	recordList = make([]*model.EdgexRecord, 0)
+	switch format {
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&recordList)
+	case FormatYAML:
+		err = yaml.NewDecoder(r).Decode(&recordList)
+		if err == io.EOF {
+			err = nil
+		}
+	case FormatCSV:
+		recordList, err = decodeCSVRecords(r)
+	default:
+		err = fmt.Errorf("format is invalid: format=%s", format)
+	}
+	return
+}
+
+// decodeCSVRecords 按表头名取值, 未知列忽略, 缺失列取零值
+func decodeCSVRecords(r io.Reader) ([]*model.EdgexRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	recordList := make([]*model.EdgexRecord, 0)
+	if len(rows) == 0 {
+		return recordList, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	if _, ok := columns["prefix"]; !ok {
+		return nil, fmt.Errorf("csv header must contain prefix")
+	}
+
+	for i, row := range rows[1:] {
+		get := func(name string) string {
+			idx, ok := columns[name]
+			if !ok || idx >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[idx])
+		}
+		record := &model.EdgexRecord{
+			Prefix:      get("prefix"),
+			EdgexName:   get("edgex_name"),
+			Address:     get("address"),
+			Description: get("description"),
+			Location:    get("location"),
+			Extra:       get("extra"),
+			CreatedTime: get("created_time"),
+		}
+		if raw := get("org_id"); raw != "" {
+			if record.OrgID, err = strconv.ParseInt(raw, 10, 64); err != nil {
+				return nil, fmt.Errorf("row %d: org_id is invalid: %s", i+1, raw)
+			}
+		}
+		recordList = append(recordList, record)
+	}
+	return recordList, nil
+}
@@ -1,26 +1,29 @@
 package edgex
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tdycwym/edgex_admin/caller"
 	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
 	"github.com/tdycwym/edgex_admin/logs"
 	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/notify"
 	"github.com/tdycwym/edgex_admin/resp"
 )
 
 // RelationEdgexParams ...
 type RelationEdgexParams struct {
-	UserID    int64
-	Username  string
-	EdgexID   int64  `form:"edgex_id" json:"edgex_id" binding:"required"`
-	EdgexName string `form:"edgex_name" json:"edgex_name"`
+	UserID   int64
+	Username string
+	EdgexID  int64 `form:"edgex_id" json:"edgex_id" binding:"required"`
 }
 
 type relationEdgexHandler struct {
 	Ctx           *gin.Context
+	Edgex         *dal.EdgexServiceItem
 	RelatedEntity *dal.EdgexRelatedUser
 	Params        RelationEdgexParams
 }
@@ -50,30 +53,64 @@ func (h *relationEdgexHandler) Follow() (err error) {
 		logs.Warn("[relationEdgexHandler-Follow] user followed: user_id=%v, edgex_id=%v", h.Params.UserID, h.Params.EdgexID)
 		return
 	}
-	// Exist
+
+	db := caller.EdgexDB.Begin()
+	defer func() {
+		if err != nil {
+			db.Rollback()
+		} else {
+			db.Commit()
+		}
+	}()
+
+	// Exist, 顺便刷新冗余的edgex_name/username
 	if h.RelatedEntity != nil {
-		filedsMap := map[string]interface{}{"status": dal.StatusFollow}
-		err = dal.UpdateEdgexRelatedUser(caller.EdgexDB, h.RelatedEntity.ID, filedsMap)
+		filedsMap := map[string]interface{}{
+			"status":     dal.StatusFollow,
+			"edgex_name": h.Edgex.EdgexName,
+			"username":   h.Params.Username,
+		}
+		err = dal.UpdateEdgexRelatedUser(db, h.RelatedEntity.ID, filedsMap)
 		if err != nil {
 			logs.Error("[relationEdgexHandler-Follow] update follow status failed: filedsMap=%+v, err=%v", filedsMap, err)
 			return
 		}
-		return
+	} else {
+		// Create
+		entity := &dal.EdgexRelatedUser{
+			UserID:       h.Params.UserID,
+			Username:     h.Params.Username,
+			EdgexID:      h.Params.EdgexID,
+			EdgexName:    h.Edgex.EdgexName,
+			Status:       dal.StatusFollow,
+			CreatedTime:  time.Now(),
+			ModifiedTime: time.Now(),
+		}
+		err = dal.AddEdgexRelatedUser(db, entity)
+		if err != nil {
+			logs.Error("[relationEdgexHandler-Follow] create follow record: err=%v", err)
+			return
+		}
 	}
 
-	// Create
-	entity := &dal.EdgexRelatedUser{
-		UserID:       h.Params.UserID,
-		Username:     h.Params.Username,
-		EdgexID:      h.Params.EdgexID,
-		EdgexName:    h.Params.EdgexName,
-		Status:       dal.StatusFollow,
-		CreatedTime:  time.Now(),
-		ModifiedTime: time.Now(),
+	err = event.Emit(db, event.TypeEdgexFollowed, h.Params.EdgexID, h.Params.UserID, map[string]interface{}{
+		"edgex_name": h.Edgex.EdgexName,
+		"username":   h.Params.Username,
+	})
+	if err != nil {
+		logs.Error("[relationEdgexHandler-Follow] emit event failed: err=%v", err)
+		return
 	}
-	err = dal.AddEdgexRelatedUser(caller.EdgexDB, entity)
+
+	// 通知创建人, 关注自己的网关时不通知
+	_, err = notify.Send(db, &notify.Audience{UserIDs: []int64{h.Edgex.UserID}, Exclude: []int64{h.Params.UserID}}, &notify.Message{
+		Category: notify.CategoryFollowed,
+		Title:    fmt.Sprintf("%s 关注了你的网关 %s", h.Params.Username, h.Edgex.EdgexName),
+		Content:  fmt.Sprintf("网关: %s(%s)", h.Edgex.EdgexName, h.Edgex.Prefix),
+		EdgexID:  h.Params.EdgexID,
+	})
 	if err != nil {
-		logs.Error("[relationEdgexHandler-Follow] create follow record: err=%v", err)
+		logs.Error("[relationEdgexHandler-Follow] notify owner failed: err=%v", err)
 		return
 	}
 	return
@@ -85,12 +122,31 @@ func (h *relationEdgexHandler) UnFollow() (err error) {
 		logs.Warn("[relationEdgexHandler-UnFollow] user unfollowed: user_id=%v, edgex_id=%v", h.Params.UserID, h.Params.EdgexID)
 		return
 	}
+
+	db := caller.EdgexDB.Begin()
+	defer func() {
+		if err != nil {
+			db.Rollback()
+		} else {
+			db.Commit()
+		}
+	}()
+
 	filedsMap := map[string]interface{}{"status": dal.StatusUnFollow}
-	err = dal.UpdateEdgexRelatedUser(caller.EdgexDB, h.RelatedEntity.ID, filedsMap)
+	err = dal.UpdateEdgexRelatedUser(db, h.RelatedEntity.ID, filedsMap)
 	if err != nil {
 		logs.Error("[relationEdgexHandler-UnFollow] unfollow failed: err=%v", err)
 		return
 	}
+
+	err = event.Emit(db, event.TypeEdgexUnfollowed, h.Params.EdgexID, h.Params.UserID, map[string]interface{}{
+		"edgex_name": h.RelatedEntity.EdgexName,
+		"username":   h.Params.Username,
+	})
+	if err != nil {
+		logs.Error("[relationEdgexHandler-UnFollow] emit event failed: err=%v", err)
+		return
+	}
 	return
 }
 
@@ -106,14 +162,25 @@ func FollowEdgex(c *gin.Context) (out *resp.JSONOutput) {
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
 
-	// Step2. 获取Follow记录
+	// Step2. 获取edgex, edgex_name以服务端为准
+	h.Edgex, err = dal.GetEdgexByID(h.Params.EdgexID)
+	if err != nil {
+		logs.Error("[FollowEdgex] get edgex failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if h.Edgex == nil || h.Edgex.Deleted != 0 {
+		logs.Error("[FollowEdgex] edgex is Not Exsit: edgex_id=%v", h.Params.EdgexID)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step3. 获取Follow记录
 	h.RelatedEntity, err = dal.FindEdgexRelatedUserByUserIDAndEdgexID(h.Params.EdgexID, h.Params.UserID)
 	if err != nil {
 		logs.Error("[FollowEdgex] find edgexRelatedUser failed: err=%v", err)
 		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
 	}
 
-	// Step3. Follow
+	// Step4. Follow
 	err = h.Follow()
 	if err != nil {
 		logs.Error("[FollowEdgex] Follow failed: err=%v", err)
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/attribute"
 	"github.com/tdycwym/edgex_admin/constdef"
 	"github.com/tdycwym/edgex_admin/dal"
 	"github.com/tdycwym/edgex_admin/logs"
@@ -26,15 +27,17 @@ type SearchEdgexParams struct {
 	UserID   int64
 	Username string
 	Keyword  string `form:"keyword" json:"keyword"`
+	Filter   string `form:"filter" json:"filter"` // e.g. extra.vendor = "Dell" AND extra.cores >= 8
 	Status   int    `form:"status" json:"status"`
 	Offset   int    `form:"offset" json:"offset"`
 	Count    int    `form:"count" json:"count"`
 }
 
 type searchEdgexHandler struct {
-	Ctx       *gin.Context
-	Params    SearchEdgexParams
-	EdgexList []*model.EdgexInfo
+	Ctx          *gin.Context
+	Params       SearchEdgexParams
+	ExtraFilters []*attribute.Filter
+	EdgexList    []*model.EdgexInfo
 }
 
 func buildSearchEdgexHandler(c *gin.Context) *searchEdgexHandler {
@@ -92,6 +95,11 @@ func (h *searchEdgexHandler) CheckParams() error {
 	if h.Params.Count == 0 {
 		h.Params.Count = 10
 	}
+
+	h.ExtraFilters, err = attribute.ParseFilters(h.Params.Filter)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -132,7 +140,7 @@ func (h *searchEdgexHandler) Process() (err error) {
 		keyword = ""
 	}
 
-	edgexList, err := dal.GetEdgexList(edgexIDs, userIDs, keyword, h.Params.Status, h.Params.Offset, h.Params.Count)
+	edgexList, err := dal.GetEdgexList(edgexIDs, userIDs, keyword, h.ExtraFilters, h.Params.Status, h.Params.Offset, h.Params.Count)
 
 	if err != nil {
 		logs.Error("[searchEdgexHandler-Process] GetEdgexList failed: err=%v", err)
@@ -152,6 +160,7 @@ func (h *searchEdgexHandler) Pack(edgexList []*dal.EdgexServiceItem, followMap m
 			EdgexID:          item.ID,
 			EdgexName:        item.EdgexName,
 			UserID:           item.UserID,
+			OrgID:            item.OrgID,
 			UserName:         h.Params.Username,
 			Prefix:           item.Prefix,
 			Address:          item.Address,
@@ -162,6 +171,10 @@ func (h *searchEdgexHandler) Pack(edgexList []*dal.EdgexServiceItem, followMap m
 			Location:         item.Location,
 			Extra:            item.Extra,
 			IsFollow:         followMap[item.ID],
+			Scheme:           item.Scheme,
+			TLSCA:            item.TLSCA,
+			TLSServerName:    item.TLSServerName,
+			TLSSkipVerify:    item.TLSSkipVerify,
 		})
 	}
 }
@@ -2,29 +2,41 @@ package edgex
 
 import (
 	"fmt"
-	"regexp"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/attribute"
 	"github.com/tdycwym/edgex_admin/caller"
 	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/gateway"
 	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
 	"github.com/tdycwym/edgex_admin/resp"
 )
 
 // UpdateEdgexParams ...
 type UpdateEdgexParams struct {
+	UserID      int64
 	EdgexID     int64  `form:"edgex_id" json:"edgex_id" binding:"required"`
+	OrgID       int64  `form:"org_id" json:"org_id"`
 	EdgexName   string `form:"edgex_name" json:"edgex_name"`
 	Prefix      string `form:"prefix" json:"prefix"`
 	Description string `form:"description" json:"description"`
 	Address     string `form:"address" json:"address"`
 	Location    string `form:"location" json:"location"`
 	Extra       string `form:"extra" json:"extra"`
+	DryRun      bool   `form:"dry_run" json:"dry_run"` // 只校验参数并探测地址, 不写数据库
+	// TLS选项, 未传时不修改, 传空值可清除
+	Scheme        *string `form:"scheme" json:"scheme"`
+	TLSCA         *string `form:"tls_ca" json:"tls_ca"`
+	TLSServerName *string `form:"tls_server_name" json:"tls_server_name"`
+	TLSSkipVerify *bool   `form:"tls_skip_verify" json:"tls_skip_verify"`
 }
 
 type updateEdgexHandler struct {
 	Ctx    *gin.Context
 	Params UpdateEdgexParams
+	Edgex  *dal.EdgexServiceItem
 }
 
 func buildUpdateEdgexHandler(c *gin.Context) *updateEdgexHandler {
@@ -40,13 +52,61 @@ func UpdateEdgex(c *gin.Context) (out *resp.JSONOutput) {
 
 	// Step1. checkParams
 	err := h.CheckParams()
+	if h.Params.DryRun {
+		return h.DryRun(err)
+	}
 	if err != nil {
 		logs.Warn("[DeleteEdgex] params-err: err=%v", err)
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
 
-	// Step2. update
+	// Step2. 获取edgex
+	h.Edgex, err = dal.GetEdgexByID(h.Params.EdgexID)
+	if err != nil {
+		logs.Warn("[UpdateEdgex] get edgex failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if h.Edgex == nil || h.Edgex.Deleted != 0 {
+		logs.Warn("[UpdateEdgex] edgex is Not Exsit: edgex_id=%v", h.Params.EdgexID)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	if code, err := checkEdgexOwner(h.Params.UserID, h.Edgex); err != nil {
+		logs.Warn("[UpdateEdgex] check permission failed: err=%v", err)
+		return resp.SampleJSON(c, code, nil)
+	}
+
+	// Step3. 组织或extra变化时按新的schema校验
+	if h.Params.OrgID > 0 || h.Params.Extra != "" {
+		err = validateExtra(h.GetOrgID(), h.GetExtra())
+		if errList, ok := err.(attribute.ValidationErrors); ok {
+			logs.Warn("[UpdateEdgex] extra-err: err=%v", err)
+			return resp.SampleJSON(c, resp.RespCodeExtraInvalid, errList)
+		}
+		if err != nil {
+			logs.Warn("[UpdateEdgex] validate extra failed: err=%v", err)
+			return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+		}
+	}
+
+	// Step4. 地址或TLS选项变化时探测
+	target := h.GetTarget()
+	if err = gateway.Validate(target); err != nil {
+		logs.Warn("[UpdateEdgex] params-err: address=%v, err=%v", target.Address, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	if h.ConnectionChanged() {
+		if report := checkAddress(c, target); report != nil {
+			logs.Warn("[UpdateEdgex] address unreachable: address=%v, err=%v", target.Address, report.Error())
+			return resp.SampleJSON(c, resp.RespCodeUnreachable, report)
+		}
+	}
+
+	// Step5. update
 	err = h.Process()
+	if dupErr, ok := dal.AsDuplicateError(err); ok {
+		logs.Warn("[UpdateEdgex] duplicate: err=%v", err)
+		return duplicateJSON(c, dupErr)
+	}
 	if err != nil {
 		logs.Warn("[DeleteEdgex] params-err: err=%v", err)
 		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
@@ -68,8 +128,10 @@ func (h *updateEdgexHandler) CheckParams() error {
 		return fmt.Errorf("edgex_id is invalid: edgex_id=%v", h.Params.EdgexID)
 	}
 
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
+
 	if h.Params.Prefix != "" {
-		if match, _ := regexp.MatchString("^[a-z_-]+$", h.Params.Prefix); !match {
+		if !prefixRegexp.MatchString(h.Params.Prefix) {
 			logs.Error("[updateEdgexHandler-checkParams] params-err: prefix=%v", h.Params.Prefix)
 			return fmt.Errorf("prefix is invalid: prefix=%v", h.Params.Prefix)
 		}
@@ -78,25 +140,150 @@ func (h *updateEdgexHandler) CheckParams() error {
 	return nil
 }
 
+// checkEdgexOwner 仅edgex创建人和管理员可以修改; 返回需要响应的错误码
+func checkEdgexOwner(userID int64, item *dal.EdgexServiceItem) (resp.ErrorCode, error) {
+	if item.UserID == userID {
+		return resp.RespCodeSuccess, nil
+	}
+	user, err := dal.GetEdgexUserByID(userID)
+	if err != nil {
+		return resp.RespDatabaseError, err
+	}
+	if user == nil || user.Role != dal.RoleAdmin {
+		return resp.RespCodeNoPermission, fmt.Errorf("permission denied: user_id=%v, edgex_id=%v", userID, item.ID)
+	}
+	return resp.RespCodeSuccess, nil
+}
+
+// DryRun 汇总参数校验、extra校验、prefix占用及地址探测结果, 未传address时探测当前地址
+func (h *updateEdgexHandler) DryRun(checkErr error) *resp.JSONOutput {
+	report := newDryRunReport()
+	if checkErr != nil {
+		addDryRunErr(report, "params error: %v", checkErr)
+	}
+
+	var err error
+	if h.Params.EdgexID > 0 {
+		h.Edgex, err = dal.GetEdgexByID(h.Params.EdgexID)
+		if err != nil {
+			logs.Warn("[updateEdgexHandler-DryRun] get edgex failed: err=%v", err)
+			return resp.SampleJSON(h.Ctx, resp.RespDatabaseError, nil)
+		}
+	}
+	if h.Edgex == nil || h.Edgex.Deleted != 0 {
+		addDryRunErr(report, "edgex is not exist: edgex_id=%v", h.Params.EdgexID)
+		return resp.SampleJSON(h.Ctx, resp.RespCodeSuccess, report)
+	}
+	if code, err := checkEdgexOwner(h.Params.UserID, h.Edgex); err != nil {
+		logs.Warn("[updateEdgexHandler-DryRun] check permission failed: err=%v", err)
+		return resp.SampleJSON(h.Ctx, code, nil)
+	}
+
+	prefix := h.Params.Prefix
+	if !prefixRegexp.MatchString(prefix) {
+		prefix = ""
+	}
+	err = dryRunCheck(h.Ctx, report, h.Edgex.ID, h.GetOrgID(), prefix, h.GetExtra(), h.GetTarget())
+	if err != nil {
+		logs.Warn("[updateEdgexHandler-DryRun] check failed: err=%v", err)
+		return resp.SampleJSON(h.Ctx, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(h.Ctx, resp.RespCodeSuccess, report)
+}
+
 func (h *updateEdgexHandler) Process() (err error) {
 
 	fieldsMap := h.GetUpdateFieldsMap()
 	if len(fieldsMap) == 0 {
 		return nil
 	}
-	err = dal.UpdateEdgex(caller.EdgexDB, h.Params.EdgexID, fieldsMap)
+
+	db := caller.EdgexDB.Begin()
+	defer func() {
+		if err != nil {
+			db.Rollback()
+		} else {
+			db.Commit()
+		}
+	}()
+	err = dal.UpdateEdgex(db, h.Params.EdgexID, fieldsMap)
 	if err != nil {
 		logs.Error("[updateEdgexHandler-process] UpdateEdgex Failed: edgex_id=%+v, fileds=%+v, err=%+v",
 			h.Params.EdgexID, fieldsMap, err)
 		return
 	}
+
+	// 重命名时同步关注记录中冗余的edgex_name
+	if edgexName, ok := fieldsMap["edgex_name"]; ok {
+		relatedFieldsMap := map[string]interface{}{"edgex_name": edgexName}
+		err = dal.UpdateEdgexRelatedUserByEdgexID(db, h.Params.EdgexID, relatedFieldsMap)
+		if err != nil {
+			logs.Error("[updateEdgexHandler-process] UpdateEdgexRelatedUserByEdgexID Failed: edgex_id=%+v, fileds=%+v, err=%+v",
+				h.Params.EdgexID, relatedFieldsMap, err)
+			return
+		}
+	}
+
+	err = event.Emit(db, event.TypeEdgexUpdated, h.Params.EdgexID, h.Params.UserID, event.EdgexUpdateData(h.Edgex, fieldsMap))
+	if err != nil {
+		logs.Error("[updateEdgexHandler-process] emit event Failed: edgex_id=%+v, err=%+v", h.Params.EdgexID, err)
+		return
+	}
 	return
 }
 
+// GetOrgID 更新后的组织id
+func (h *updateEdgexHandler) GetOrgID() int64 {
+	if h.Params.OrgID > 0 {
+		return h.Params.OrgID
+	}
+	return h.Edgex.OrgID
+}
+
+// GetExtra 更新后的extra
+func (h *updateEdgexHandler) GetExtra() string {
+	if h.Params.Extra != "" {
+		return h.Params.Extra
+	}
+	return h.Edgex.Extra
+}
+
+// GetTarget 更新后的地址与TLS选项, 用于校验和探测
+func (h *updateEdgexHandler) GetTarget() *dal.EdgexServiceItem {
+	target := *h.Edgex
+	if h.Params.Address != "" {
+		target.Address = h.Params.Address
+	}
+	if h.Params.Scheme != nil {
+		target.Scheme = *h.Params.Scheme
+	}
+	if h.Params.TLSCA != nil {
+		target.TLSCA = *h.Params.TLSCA
+	}
+	if h.Params.TLSServerName != nil {
+		target.TLSServerName = *h.Params.TLSServerName
+	}
+	if h.Params.TLSSkipVerify != nil {
+		target.TLSSkipVerify = *h.Params.TLSSkipVerify
+	}
+	return &target
+}
+
+// ConnectionChanged 地址或TLS选项是否变化
+func (h *updateEdgexHandler) ConnectionChanged() bool {
+	target := h.GetTarget()
+	return target.Address != h.Edgex.Address || target.Scheme != h.Edgex.Scheme || target.TLSCA != h.Edgex.TLSCA ||
+		target.TLSServerName != h.Edgex.TLSServerName || target.TLSSkipVerify != h.Edgex.TLSSkipVerify
+}
+
 func (h *updateEdgexHandler) GetUpdateFieldsMap() (fieldsMap map[string]interface{}) {
 
 	fieldsMap = make(map[string]interface{})
 
+	if h.Params.OrgID > 0 {
+		fieldsMap["org_id"] = h.Params.OrgID
+	}
+
 	if h.Params.EdgexName != "" {
 		fieldsMap["edgex_name"] = h.Params.EdgexName
 	}
@@ -120,5 +307,21 @@ func (h *updateEdgexHandler) GetUpdateFieldsMap() (fieldsMap map[string]interfac
 	if h.Params.Address != "" {
 		fieldsMap["address"] = h.Params.Address
 	}
+
+	if h.Params.Scheme != nil {
+		fieldsMap["scheme"] = *h.Params.Scheme
+	}
+
+	if h.Params.TLSCA != nil {
+		fieldsMap["tls_ca"] = *h.Params.TLSCA
+	}
+
+	if h.Params.TLSServerName != nil {
+		fieldsMap["tls_server_name"] = *h.Params.TLSServerName
+	}
+
+	if h.Params.TLSSkipVerify != nil {
+		fieldsMap["tls_skip_verify"] = *h.Params.TLSSkipVerify
+	}
 	return
 }
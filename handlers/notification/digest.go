@@ -0,0 +1,48 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/digest"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+// UnsubscribeParams ...
+type UnsubscribeParams struct {
+	Token string `form:"token" json:"token" binding:"required"`
+}
+
+// UnsubscribeDigest 摘要邮件中的退订链接, 以token代替登录
+func UnsubscribeDigest(c *gin.Context) (out *resp.JSONOutput) {
+
+	params := UnsubscribeParams{}
+	err := c.Bind(&params)
+	if err != nil {
+		logs.Warn("[UnsubscribeDigest] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	userID, ok := digest.ParseUnsubscribeToken(params.Token)
+	if !ok {
+		logs.Warn("[UnsubscribeDigest] token is invalid")
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	pref, err := dal.GetNotifyPrefByUserID(userID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if pref == nil || !pref.DigestEnabled {
+		return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+	}
+	pref.DigestEnabled = false
+	pref.ModifiedTime = time.Now()
+	if err = dal.SaveNotifyPref(caller.EdgexDB, pref); err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	logs.Info("[UnsubscribeDigest] digest unsubscribed: user_id=%v", userID)
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
@@ -0,0 +1,139 @@
+package notification
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/constdef"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+const maxBatchSize = 200
+
+// NotificationListParams ...
+type NotificationListParams struct {
+	UserID   int64
+	Unread   bool   `form:"unread" json:"unread"` // 只看未读
+	Category string `form:"category" json:"category"`
+	Offset   int    `form:"offset" json:"offset"`
+	Count    int    `form:"count" json:"count"`
+}
+
+// NotificationIDsParams ...
+type NotificationIDsParams struct {
+	UserID int64
+	IDs    []int64 `form:"ids" json:"ids"`
+}
+
+// GetNotificationList 当前用户的通知, 按时间倒序, 附带总数和未读数
+func GetNotificationList(c *gin.Context) (out *resp.JSONOutput) {
+
+	params := NotificationListParams{}
+	err := c.Bind(&params)
+	if err != nil {
+		logs.Warn("[GetNotificationList] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	params.UserID = session.GetSessionUserID(c)
+	if params.Count <= 0 || params.Count > 100 {
+		params.Count = 20
+	}
+
+	itemList, err := dal.GetNotificationList(params.UserID, params.Unread, params.Category, params.Offset, params.Count)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	total, unread, err := dal.CountNotifications(params.UserID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+
+	data := &model.NotificationList{
+		Total:         total,
+		Unread:        unread,
+		Notifications: make([]*model.NotificationInfo, 0, len(itemList)),
+	}
+	for _, item := range itemList {
+		data.Notifications = append(data.Notifications, &model.NotificationInfo{
+			ID:          item.ID,
+			EdgexID:     item.EdgexID,
+			EventID:     item.EventID,
+			Category:    item.Category,
+			Title:       item.Title,
+			Content:     item.Content,
+			IsRead:      item.IsRead,
+			CreatedTime: item.CreatedTime.Format(constdef.TimeFormat),
+		})
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, data)
+}
+
+// GetNotificationCount 未读数, 供前端轮询角标
+func GetNotificationCount(c *gin.Context) (out *resp.JSONOutput) {
+	userID := session.GetSessionUserID(c)
+	total, unread, err := dal.CountNotifications(userID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, &model.NotificationCount{Total: total, Unread: unread})
+}
+
+// ReadNotification 标记ids为已读, 返回更新的条数
+func ReadNotification(c *gin.Context) (out *resp.JSONOutput) {
+
+	params, err := bindNotificationIDs(c)
+	if err != nil {
+		logs.Warn("[ReadNotification] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	affected, err := dal.MarkNotificationsRead(caller.EdgexDB, params.UserID, params.IDs)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, affected)
+}
+
+// ReadAllNotification 标记全部为已读, 返回更新的条数
+func ReadAllNotification(c *gin.Context) (out *resp.JSONOutput) {
+	userID := session.GetSessionUserID(c)
+	affected, err := dal.MarkNotificationsRead(caller.EdgexDB, userID, nil)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, affected)
+}
+
+// DeleteNotification 删除ids, 返回删除的条数
+func DeleteNotification(c *gin.Context) (out *resp.JSONOutput) {
+
+	params, err := bindNotificationIDs(c)
+	if err != nil {
+		logs.Warn("[DeleteNotification] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	affected, err := dal.DeleteNotifications(caller.EdgexDB, params.UserID, params.IDs)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, affected)
+}
+
+func bindNotificationIDs(c *gin.Context) (params *NotificationIDsParams, err error) {
+	params = &NotificationIDsParams{}
+	err = c.Bind(params)
+	if err != nil {
+		return
+	}
+	if len(params.IDs) == 0 || len(params.IDs) > maxBatchSize {
+		return nil, fmt.Errorf("ids is invalid: count=%d", len(params.IDs))
+	}
+	params.UserID = session.GetSessionUserID(c)
+	return
+}
@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/alert"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+// SaveNotifyPrefParams 未传的字段保持不变
+type SaveNotifyPrefParams struct {
+	UserID        int64
+	EmailEnabled  *bool   `form:"email_enabled" json:"email_enabled"`
+	InboxEnabled  *bool   `form:"inbox_enabled" json:"inbox_enabled"`
+	Alerts        *string `form:"alerts" json:"alerts"` // 逗号分隔, *表示全部, 空表示不接收告警
+	DigestEnabled *bool   `form:"digest_enabled" json:"digest_enabled"`
+}
+
+type saveNotifyPrefHandler struct {
+	Ctx    *gin.Context
+	Params SaveNotifyPrefParams
+	Alerts []string
+}
+
+func buildSaveNotifyPrefHandler(c *gin.Context) *saveNotifyPrefHandler {
+	return &saveNotifyPrefHandler{
+		Ctx: c,
+	}
+}
+
+// GetNotifyPref 当前用户的告警通知偏好
+func GetNotifyPref(c *gin.Context) (out *resp.JSONOutput) {
+	userID := session.GetSessionUserID(c)
+	pref, err := dal.GetNotifyPrefByUserID(userID)
+	if err != nil {
+		logs.Error("[GetNotifyPref] get pref failed: user_id=%v, err=%v", userID, err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if pref == nil {
+		pref = alert.DefaultPref(userID)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, buildNotifyPrefInfo(pref))
+}
+
+// SaveNotifyPref ...
+func SaveNotifyPref(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildSaveNotifyPrefHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[SaveNotifyPref] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step2. save
+	pref, err := h.Process()
+	if err != nil {
+		logs.Error("[SaveNotifyPref] save failed: user_id=%v, err=%v", h.Params.UserID, err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, buildNotifyPrefInfo(pref))
+}
+
+func (h *saveNotifyPrefHandler) CheckParams() error {
+
+	err := h.Ctx.Bind(&h.Params)
+	if err != nil {
+		logs.Error("[saveNotifyPrefHandler-checkParams] params-err: err=%v", err)
+		return err
+	}
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
+
+	if h.Params.Alerts != nil {
+		alertList, ok := alert.ParseAlerts(*h.Params.Alerts)
+		if !ok {
+			return fmt.Errorf("alerts is invalid: alerts=%v", *h.Params.Alerts)
+		}
+		h.Alerts = alertList
+	}
+	return nil
+}
+
+func (h *saveNotifyPrefHandler) Process() (pref *dal.EdgexNotifyPref, err error) {
+	pref, err = dal.GetNotifyPrefByUserID(h.Params.UserID)
+	if err != nil {
+		return
+	}
+	if pref == nil {
+		pref = alert.DefaultPref(h.Params.UserID)
+		pref.CreatedTime = time.Now()
+	}
+	if h.Params.EmailEnabled != nil {
+		pref.EmailEnabled = *h.Params.EmailEnabled
+	}
+	if h.Params.InboxEnabled != nil {
+		pref.InboxEnabled = *h.Params.InboxEnabled
+	}
+	if h.Params.Alerts != nil {
+		pref.Alerts = strings.Join(h.Alerts, ",")
+	}
+	if h.Params.DigestEnabled != nil {
+		pref.DigestEnabled = *h.Params.DigestEnabled
+	}
+	pref.ModifiedTime = time.Now()
+	err = dal.SaveNotifyPref(caller.EdgexDB, pref)
+	return
+}
+
+func buildNotifyPrefInfo(pref *dal.EdgexNotifyPref) *model.NotifyPrefInfo {
+	alertList := make([]string, 0)
+	if pref.Alerts != "" {
+		alertList = strings.Split(pref.Alerts, ",")
+	}
+	return &model.NotifyPrefInfo{
+		EmailEnabled:  pref.EmailEnabled,
+		InboxEnabled:  pref.InboxEnabled,
+		Alerts:        alertList,
+		DigestEnabled: pref.DigestEnabled,
+	}
+}
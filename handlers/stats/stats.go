@@ -0,0 +1,259 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/constdef"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/handlers/edgex"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+const (
+	defaultMonths = 12
+	defaultTopN   = 10
+	cacheKeyFmt   = "edgex_admin:stats:%s:%d"
+)
+
+// StatsParams ...
+type StatsParams struct {
+	Action string `form:"action" json:"action"` // all/me/follow, 与搜索一致
+	UserID int64
+}
+
+type statsHandler struct {
+	Ctx    *gin.Context
+	Params StatsParams
+	Scope  *dal.StatsScope
+	Stats  *model.FleetStats
+}
+
+func buildStatsHandler(c *gin.Context) *statsHandler {
+	return &statsHandler{
+		Ctx:   c,
+		Scope: &dal.StatsScope{},
+	}
+}
+
+// GetFleetStats 首页统计: 按状态、地区、创建人、创建月份计数, 关注最多及最近修改的edgex
+func GetFleetStats(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildStatsHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[GetFleetStats] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step2. 读缓存, 全部范围的统计所有用户共用
+	if h.getCache() {
+		return resp.SampleJSON(c, resp.RespCodeSuccess, h.Stats)
+	}
+
+	// Step3. 统计
+	err = h.Process()
+	if err != nil {
+		logs.Error("[GetFleetStats] process failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	h.setCache()
+
+	return resp.SampleJSON(c, resp.RespCodeSuccess, h.Stats)
+}
+
+func (h *statsHandler) CheckParams() error {
+
+	err := h.Ctx.Bind(&h.Params)
+	if err != nil {
+		logs.Error("[statsHandler-checkParams] params-err: err=%v", err)
+		return err
+	}
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
+
+	if h.Params.Action == "" {
+		h.Params.Action = edgex.ActionAll
+	}
+	switch h.Params.Action {
+	case edgex.ActionAll:
+	case edgex.ActionMe:
+		h.Scope.OwnerID = h.Params.UserID
+	case edgex.ActionFollow:
+		h.Scope.FollowerID = h.Params.UserID
+	default:
+		return fmt.Errorf("action is invalid: action=%s", h.Params.Action)
+	}
+	if h.Params.Action != edgex.ActionAll && h.Params.UserID == 0 {
+		return fmt.Errorf("params error: action=%s but user_id=0", h.Params.Action)
+	}
+	return nil
+}
+
+func (h *statsHandler) cacheKey() string {
+	userID := h.Scope.OwnerID + h.Scope.FollowerID
+	return fmt.Sprintf(cacheKeyFmt, h.Params.Action, userID)
+}
+
+// getCache redis不可用时视为未命中
+func (h *statsHandler) getCache() bool {
+	if config.StatsConf.CacheTTL <= 0 {
+		return false
+	}
+	data, err := caller.RedisClient.Get(h.Ctx.Request.Context(), h.cacheKey()).Bytes()
+	if err != nil {
+		return false
+	}
+	stats := &model.FleetStats{}
+	if err = json.Unmarshal(data, stats); err != nil {
+		return false
+	}
+	h.Stats = stats
+	return true
+}
+
+func (h *statsHandler) setCache() {
+	if config.StatsConf.CacheTTL <= 0 {
+		return
+	}
+	data, _ := json.Marshal(h.Stats)
+	ttl := time.Duration(config.StatsConf.CacheTTL) * time.Second
+	if err := caller.RedisClient.Set(h.Ctx.Request.Context(), h.cacheKey(), data, ttl).Err(); err != nil {
+		logs.Warn("[statsHandler-setCache] set cache failed: key=%s, err=%v", h.cacheKey(), err)
+	}
+}
+
+func (h *statsHandler) Process() (err error) {
+	now := time.Now()
+	topN := config.StatsConf.TopN
+	if topN <= 0 {
+		topN = defaultTopN
+	}
+	h.Stats = &model.FleetStats{
+		Action:          h.Params.Action,
+		ByStatus:        make([]*model.StatsCount, 0),
+		ByLocation:      make([]*model.LocationCount, 0),
+		ByOwner:         make([]*model.OwnerCount, 0),
+		TopFollowed:     make([]*model.FollowedEdgex, 0),
+		RecentlyChanged: make([]*model.ChangedEdgex, 0),
+		GeneratedTime:   now.Format(constdef.TimeFormat),
+	}
+
+	// Step1. 状态
+	statusList, err := dal.CountEdgexByStatus(h.Scope)
+	if err != nil {
+		return
+	}
+	statusCount := make(map[string]int64)
+	for _, item := range statusList {
+		statusCount[item.Key] = item.Count
+		h.Stats.Total += item.Count
+	}
+	h.Stats.ByStatus = append(h.Stats.ByStatus,
+		&model.StatsCount{Key: "active", Count: statusCount[strconv.Itoa(dal.EdgexActive)]},
+		&model.StatsCount{Key: "inactive", Count: statusCount[strconv.Itoa(dal.EdgexInactive)]},
+	)
+
+	// Step2. 地区
+	locationList, err := dal.CountEdgexByLocation(h.Scope)
+	if err != nil {
+		return
+	}
+	for _, item := range locationList {
+		h.Stats.ByLocation = append(h.Stats.ByLocation, &model.LocationCount{Province: item.Key, City: item.SubKey, Count: item.Count})
+	}
+
+	// Step3. 创建人
+	ownerList, err := dal.CountEdgexByOwner(h.Scope, topN)
+	if err != nil {
+		return
+	}
+	userIDs := make([]int64, 0, len(ownerList))
+	for _, item := range ownerList {
+		userID, _ := strconv.ParseInt(item.Key, 10, 64)
+		userIDs = append(userIDs, userID)
+		h.Stats.ByOwner = append(h.Stats.ByOwner, &model.OwnerCount{UserID: userID, Count: item.Count})
+	}
+	userMap, err := dal.GetEdgexUserMapByIDs(userIDs)
+	if err != nil {
+		return
+	}
+	for _, owner := range h.Stats.ByOwner {
+		if user, ok := userMap[owner.UserID]; ok {
+			owner.Username = user.Username
+		}
+	}
+
+	// Step4. 创建月份, 补齐没有创建的月份
+	months := config.StatsConf.Months
+	if months <= 0 {
+		months = defaultMonths
+	}
+	firstMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, 1-months, 0)
+	monthList, err := dal.CountEdgexByMonth(h.Scope, firstMonth)
+	if err != nil {
+		return
+	}
+	h.Stats.ByMonth = fillMonths(monthList, firstMonth, months)
+
+	// Step5. 关注最多
+	followList, err := dal.GetTopFollowedEdgex(h.Scope, topN)
+	if err != nil {
+		return
+	}
+	edgexIDs := make([]int64, 0, len(followList))
+	for _, item := range followList {
+		edgexIDs = append(edgexIDs, item.EdgexID)
+	}
+	edgexMap, err := dal.GetEdgexMapByIDs(edgexIDs)
+	if err != nil {
+		return
+	}
+	for _, item := range followList {
+		followed := &model.FollowedEdgex{EdgexID: item.EdgexID, Followers: item.Count}
+		if edgexItem, ok := edgexMap[item.EdgexID]; ok {
+			followed.EdgexName = edgexItem.EdgexName
+			followed.Prefix = edgexItem.Prefix
+		}
+		h.Stats.TopFollowed = append(h.Stats.TopFollowed, followed)
+	}
+
+	// Step6. 最近修改
+	changedList, err := dal.GetRecentlyChangedEdgex(h.Scope, topN)
+	if err != nil {
+		return
+	}
+	for _, item := range changedList {
+		h.Stats.RecentlyChanged = append(h.Stats.RecentlyChanged, &model.ChangedEdgex{
+			EdgexID:      item.ID,
+			EdgexName:    item.EdgexName,
+			Prefix:       item.Prefix,
+			Status:       item.Status,
+			ModifiedTime: item.ModifiedTime.Format(constdef.TimeFormat),
+		})
+	}
+	return nil
+}
+
+// fillMonths 从firstMonth开始的连续months个月
+func fillMonths(monthList []*dal.GroupCount, firstMonth time.Time, months int) []*model.StatsCount {
+	countMap := make(map[string]int64, len(monthList))
+	for _, item := range monthList {
+		countMap[item.Key] = item.Count
+	}
+	result := make([]*model.StatsCount, 0, months)
+	for i := 0; i < months; i++ {
+		key := firstMonth.AddDate(0, i, 0).Format("2006-01")
+		result = append(result, &model.StatsCount{Key: key, Count: countMap[key]})
+	}
+	return result
+}
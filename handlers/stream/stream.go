@@ -0,0 +1,266 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/resp"
+	"github.com/tdycwym/edgex_admin/stream"
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+const (
+	sseRetryMs  = 3000
+	wsWriteWait = 10 * time.Second
+)
+
+// 与CorsMiddleware一致, 允许跨域的前端建立连接
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// EventStreamParams ...
+type EventStreamParams struct {
+	UserID      int64
+	Action      string `form:"action" json:"action"`               // all/me/follow, 同SearchEdgex
+	Types       string `form:"types" json:"types"`                 // 逗号分隔的事件类型, 为空表示全部
+	LastEventID int64  `form:"last_event_id" json:"last_event_id"` // SSE优先使用Last-Event-ID请求头
+}
+
+type eventStreamHandler struct {
+	Ctx       *gin.Context
+	Params    EventStreamParams
+	Types     []string
+	Client    *stream.Client
+	Replay    []*event.Event
+	Truncated bool
+}
+
+func buildEventStreamHandler(c *gin.Context) *eventStreamHandler {
+	return &eventStreamHandler{
+		Ctx: c,
+	}
+}
+
+// CheckParams ...
+func (h *eventStreamHandler) CheckParams() (err error) {
+
+	err = h.Ctx.ShouldBindQuery(&h.Params)
+	if err != nil {
+		logs.Error("[eventStreamHandler-checkParams] params-err: err=%v", err)
+		return err
+	}
+
+	if lastEventID := h.Ctx.GetHeader("Last-Event-ID"); lastEventID != "" {
+		h.Params.LastEventID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Last-Event-ID is invalid: %s", lastEventID)
+		}
+	}
+
+	if h.Params.Action == "" {
+		h.Params.Action = stream.ActionAll
+	}
+	if !utils.InStringSlice(h.Params.Action, []string{stream.ActionAll, stream.ActionMe, stream.ActionFollow}) {
+		return fmt.Errorf("action is invalid: action=%s", h.Params.Action)
+	}
+
+	for _, item := range strings.Split(h.Params.Types, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !utils.InStringSlice(item, event.AllTypes) {
+			return fmt.Errorf("type is invalid: type=%s", item)
+		}
+		h.Types = append(h.Types, item)
+	}
+
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
+	if h.Params.UserID == 0 {
+		return fmt.Errorf("user_id is invalid")
+	}
+	return nil
+}
+
+// Process 注册连接并查询需要补发的事件
+func (h *eventStreamHandler) Process() (err error) {
+
+	user, err := dal.GetEdgexUserByID(h.Params.UserID)
+	if err != nil {
+		return err
+	}
+	isAdmin := user != nil && user.Role == dal.RoleAdmin
+
+	h.Client, err = stream.NewClient(h.Params.UserID, isAdmin, h.Params.Action, h.Types)
+	if err != nil {
+		return err
+	}
+	h.Replay, h.Truncated, err = h.Client.Subscribe(h.Params.LastEventID)
+	return err
+}
+
+// Prepare 连接建立前的公共步骤, 失败时已写入错误响应
+func (h *eventStreamHandler) Prepare(funcName string) bool {
+
+	// Step1. checkParams
+	err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[%s] params-err: err=%v", funcName, err)
+		resp.SampleJSON(h.Ctx, resp.RespCodeParamsError, err.Error()).Write()
+		return false
+	}
+
+	// Step2. subscribe
+	err = h.Process()
+	if err != nil {
+		logs.Error("[%s] subscribe failed: err=%v", funcName, err)
+		resp.SampleJSON(h.Ctx, resp.RespDatabaseError, nil).Write()
+		return false
+	}
+	return true
+}
+
+// StreamEvents SSE事件流, 断线后浏览器自动携带Last-Event-ID重连补发
+func StreamEvents(c *gin.Context) {
+
+	h := buildEventStreamHandler(c)
+	if !h.Prepare("StreamEvents") {
+		return
+	}
+	defer h.Client.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭nginx缓冲
+	c.Status(http.StatusOK)
+	_, _ = fmt.Fprintf(c.Writer, "retry: %d\n\n", sseRetryMs)
+
+	// Step3. 补发
+	if h.Truncated {
+		writeSSE(c, &event.Event{Type: stream.TypeReset, OccurredAt: time.Now().Unix()})
+	}
+	for _, evt := range h.Replay {
+		writeSSE(c, evt)
+	}
+	c.Writer.Flush()
+
+	// Step4. 推送实时事件和心跳
+	ticker := time.NewTicker(stream.HeartbeatInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-h.Client.Done():
+			return
+		case <-ticker.C:
+			_, _ = fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		case evt := <-h.Client.Events():
+			if h.Client.Duplicated(evt) {
+				continue
+			}
+			writeSSE(c, evt)
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeSSE(c *gin.Context, evt *event.Event) {
+	data, _ := json.Marshal(evt)
+	if evt.ID > 0 {
+		_, _ = fmt.Fprintf(c.Writer, "id: %d\n", evt.ID)
+	}
+	_, _ = fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", evt.Type, data)
+}
+
+// WebSocketEvents WebSocket事件流, 每条消息为一个事件的JSON; 重连时通过last_event_id补发
+func WebSocketEvents(c *gin.Context) {
+
+	h := buildEventStreamHandler(c)
+	if !h.Prepare("WebSocketEvents") {
+		return
+	}
+	defer h.Client.Close()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logs.Warn("[WebSocketEvents] upgrade failed: err=%v", err)
+		return
+	}
+	defer conn.Close()
+
+	heartbeat := stream.HeartbeatInterval()
+	// 读协程处理pong和关闭帧, 客户端不发送业务消息
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(evt *event.Event) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(evt)
+	}
+
+	// Step3. 补发
+	if h.Truncated {
+		if err = write(&event.Event{Type: stream.TypeReset, OccurredAt: time.Now().Unix()}); err != nil {
+			return
+		}
+	}
+	for _, evt := range h.Replay {
+		if err = write(evt); err != nil {
+			return
+		}
+	}
+
+	// Step4. 推送实时事件和心跳
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-h.Client.Done():
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client is too slow"), time.Now().Add(wsWriteWait))
+			return
+		case <-ticker.C:
+			if err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case evt := <-h.Client.Events():
+			if h.Client.Duplicated(evt) {
+				continue
+			}
+			if err = write(evt); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package uptime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/resp"
+	"github.com/tdycwym/edgex_admin/uptime"
+)
+
+const maxReportWindow = 400 * 24 * time.Hour
+
+// UptimeReportParams 指定month(e.g. 2021-05)或start/end(unix秒), 都不传时为本月至今
+type UptimeReportParams struct {
+	EdgexID int64   `form:"edgex_id" json:"edgex_id"`
+	OrgID   int64   `form:"org_id" json:"org_id"`
+	Month   string  `form:"month" json:"month"`
+	Start   int64   `form:"start" json:"start"`
+	End     int64   `form:"end" json:"end"`
+	SLA     float64 `form:"sla" json:"sla"` // SLA目标可用率, e.g. 99.9
+}
+
+type uptimeReportHandler struct {
+	Ctx    *gin.Context
+	Params UptimeReportParams
+	Start  time.Time
+	End    time.Time
+}
+
+func buildUptimeReportHandler(c *gin.Context) *uptimeReportHandler {
+	return &uptimeReportHandler{
+		Ctx: c,
+	}
+}
+
+// CheckParams ...
+func (h *uptimeReportHandler) CheckParams() (err error) {
+
+	err = h.Ctx.Bind(&h.Params)
+	if err != nil {
+		logs.Error("[uptimeReportHandler-checkParams] params-err: err=%v", err)
+		return err
+	}
+
+	now := time.Now()
+	switch {
+	case h.Params.Month != "":
+		h.Start, err = time.ParseInLocation("2006-01", h.Params.Month, time.Local)
+		if err != nil {
+			return fmt.Errorf("month is invalid: month=%s", h.Params.Month)
+		}
+		h.End = h.Start.AddDate(0, 1, 0)
+	default:
+		h.Start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		h.End = now
+		if h.Params.Start > 0 {
+			h.Start = time.Unix(h.Params.Start, 0)
+		}
+		if h.Params.End > 0 {
+			h.End = time.Unix(h.Params.End, 0)
+		}
+	}
+
+	if !h.Start.Before(h.End) {
+		return fmt.Errorf("window is invalid: start=%v, end=%v", h.Start, h.End)
+	}
+	if h.End.Sub(h.Start) > maxReportWindow {
+		return fmt.Errorf("window is too large: start=%v, end=%v", h.Start, h.End)
+	}
+	if h.Params.SLA < 0 || h.Params.SLA > 100 {
+		return fmt.Errorf("sla is invalid: sla=%v", h.Params.SLA)
+	}
+	return nil
+}
+
+func (h *uptimeReportHandler) slaMet(uptimePercent float64) *bool {
+	if h.Params.SLA <= 0 {
+		return nil
+	}
+	met := uptimePercent >= h.Params.SLA
+	return &met
+}
+
+// GetUptimeReport 单个edgex的可用率、不可达区间和延迟分位数
+func GetUptimeReport(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildUptimeReportHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[GetUptimeReport] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+
+	edgex, err := dal.GetEdgexByID(h.Params.EdgexID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if edgex == nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "edgex not found")
+	}
+
+	// Step2. report
+	report, err := uptime.Report(edgex, h.Start, h.End)
+	if err != nil {
+		logs.Error("[GetUptimeReport] build report failed: edgex_id=%v, err=%v", h.Params.EdgexID, err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if h.Params.SLA > 0 {
+		report.SLATarget = h.Params.SLA
+		report.SLAMet = h.slaMet(report.UptimePercent)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, report)
+}
+
+// GetOrgUptimeReport 组织下全部edgex的可用性汇总, 可用率按监控时长加权
+func GetOrgUptimeReport(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildUptimeReportHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams()
+	if err != nil {
+		logs.Warn("[GetOrgUptimeReport] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+
+	// Step2. report
+	report, err := uptime.OrgReport(h.Params.OrgID, h.Start, h.End)
+	if err != nil {
+		logs.Error("[GetOrgUptimeReport] build report failed: org_id=%v, err=%v", h.Params.OrgID, err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if h.Params.SLA > 0 {
+		report.SLATarget = h.Params.SLA
+		report.SLAMet = h.slaMet(report.UptimePercent)
+		for _, item := range report.EdgexList {
+			item.SLATarget = h.Params.SLA
+			item.SLAMet = h.slaMet(item.UptimePercent)
+		}
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, report)
+}
@@ -0,0 +1,278 @@
+package user
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/mfa"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+const defaultPreAuthTTL = 5 * time.Minute
+
+// MFACodeParams code为认证应用的6位验证码, 登录第二步和关闭时也可以使用恢复码
+type MFACodeParams struct {
+	Code string `form:"code" json:"code" binding:"required"`
+}
+
+// MFAPolicyParams ...
+type MFAPolicyParams struct {
+	Role     int32 `form:"role" json:"role"`
+	Required bool  `form:"required" json:"required"`
+}
+
+// MFAResetParams ...
+type MFAResetParams struct {
+	UserID int64 `form:"user_id" json:"user_id" binding:"required"`
+}
+
+// completeLogin 第一步校验通过后, 开启或角色要求两步验证时只保存预认证session
+func completeLogin(c *gin.Context, userInfo *dal.EdgexUser) *resp.JSONOutput {
+	enabled, err := mfa.Enabled(userInfo.ID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if enabled {
+		session.SavePreAuthSession(c, userInfo.ID, userInfo.Username, session.PreAuthVerify, preAuthTTL())
+		return resp.SampleJSON(c, resp.RespCodeSuccess, &model.LoginResult{Need2FA: true})
+	}
+	required, err := mfa.Required(userInfo.Role)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if required {
+		session.SavePreAuthSession(c, userInfo.ID, userInfo.Username, session.PreAuthEnroll, preAuthTTL())
+		return resp.SampleJSON(c, resp.RespCodeSuccess, &model.LoginResult{Need2FA: true, NeedEnroll: true})
+	}
+	session.SaveAuthSession(c, userInfo.ID, userInfo.Username)
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// VerifyMFA 登录第二步, 校验通过后预认证session升级为完整session
+func VerifyMFA(c *gin.Context) *resp.JSONOutput {
+	params := &MFACodeParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[VerifyMFA] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	userID, username, stage := session.GetPreAuthSession(c)
+	if userID <= 0 || stage != session.PreAuthVerify {
+		return resp.SampleJSON(c, resp.RespCodeNoPermission, "请重新登录")
+	}
+	if err = mfa.Verify(userID, params.Code); err != nil {
+		logs.Warn("[VerifyMFA] verify failed: user_id=%v, err=%v", userID, err)
+		return mfaErrJSON(c, err)
+	}
+	session.SaveAuthSession(c, userID, username)
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// GetMFAStatus 当前用户的两步验证状态
+func GetMFAStatus(c *gin.Context) *resp.JSONOutput {
+	userInfo, code := mfaUser(c, false)
+	if code != resp.RespCodeSuccess {
+		return resp.SampleJSON(c, code, nil)
+	}
+	status := &model.MFAStatus{}
+	var err error
+	if status.Enabled, err = mfa.Enabled(userInfo.ID); err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if status.Required, err = mfa.Required(userInfo.Role); err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if status.Enabled {
+		if status.RecoveryCodes, err = dal.CountUnusedRecoveryCodes(userInfo.ID); err != nil {
+			return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+		}
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, status)
+}
+
+// EnrollMFA 生成新的密钥, 需要登录或处于待绑定的预认证session
+func EnrollMFA(c *gin.Context) *resp.JSONOutput {
+	userInfo, code := mfaUser(c, true)
+	if code != resp.RespCodeSuccess {
+		return resp.SampleJSON(c, code, nil)
+	}
+	secret, uri, err := mfa.Enroll(userInfo)
+	if err != nil {
+		logs.Error("[EnrollMFA] enroll failed: user_id=%v, err=%v", userInfo.ID, err)
+		return mfaErrJSON(c, err)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, &model.MFAEnrollInfo{Secret: secret, URI: uri})
+}
+
+// ConfirmMFA 输入认证应用的验证码后启用并返回恢复码; 预认证session同时完成登录
+func ConfirmMFA(c *gin.Context) *resp.JSONOutput {
+	params := &MFACodeParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[ConfirmMFA] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	userInfo, code := mfaUser(c, true)
+	if code != resp.RespCodeSuccess {
+		return resp.SampleJSON(c, code, nil)
+	}
+	codes, err := mfa.Confirm(userInfo.ID, params.Code)
+	if err != nil {
+		logs.Warn("[ConfirmMFA] confirm failed: user_id=%v, err=%v", userInfo.ID, err)
+		return mfaErrJSON(c, err)
+	}
+	if session.GetSessionUserID(c) <= 0 {
+		session.SaveAuthSession(c, userInfo.ID, userInfo.Username)
+	}
+	logs.Info("[ConfirmMFA] 2fa enabled: user_id=%v", userInfo.ID)
+	return resp.SampleJSON(c, resp.RespCodeSuccess, &model.MFARecoveryCodes{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes 原恢复码全部失效
+func RegenerateRecoveryCodes(c *gin.Context) *resp.JSONOutput {
+	params := &MFACodeParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[RegenerateRecoveryCodes] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	userID := session.GetSessionUserID(c)
+	codes, err := mfa.RegenerateRecoveryCodes(userID, params.Code)
+	if err != nil {
+		logs.Warn("[RegenerateRecoveryCodes] regenerate failed: user_id=%v, err=%v", userID, err)
+		return mfaErrJSON(c, err)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, &model.MFARecoveryCodes{RecoveryCodes: codes})
+}
+
+// DisableMFA 所属角色要求两步验证时不能关闭
+func DisableMFA(c *gin.Context) *resp.JSONOutput {
+	params := &MFACodeParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[DisableMFA] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	userInfo, code := mfaUser(c, false)
+	if code != resp.RespCodeSuccess {
+		return resp.SampleJSON(c, code, nil)
+	}
+	if err = mfa.Disable(userInfo, params.Code); err != nil {
+		logs.Warn("[DisableMFA] disable failed: user_id=%v, err=%v", userInfo.ID, err)
+		return mfaErrJSON(c, err)
+	}
+	logs.Info("[DisableMFA] 2fa disabled: user_id=%v", userInfo.ID)
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// GetMFAPolicy 管理员查看各角色的两步验证要求
+func GetMFAPolicy(c *gin.Context) *resp.JSONOutput {
+	policyList, err := dal.GetMFAPolicyList()
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	infoList := make([]*model.MFAPolicyInfo, 0, len(policyList))
+	for _, policy := range policyList {
+		infoList = append(infoList, &model.MFAPolicyInfo{
+			Role:              policy.Role,
+			Required:          policy.Required,
+			UpdatedBy:         policy.UpdatedBy,
+			ModifiedTimestamp: policy.ModifiedTime.Unix(),
+		})
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, infoList)
+}
+
+// SaveMFAPolicy 管理员设置角色是否必须开启两步验证, 该角色未开启的用户下次登录时需先绑定
+func SaveMFAPolicy(c *gin.Context) *resp.JSONOutput {
+	params := &MFAPolicyParams{}
+	err := c.Bind(params)
+	if err != nil || (params.Role != dal.RoleUser && params.Role != dal.RoleAdmin) {
+		logs.Error("[SaveMFAPolicy] request-params error: params=%+v, err=%v", params, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	now := time.Now()
+	err = dal.SaveMFAPolicy(caller.EdgexDB, &dal.EdgexMFAPolicy{
+		Role:         params.Role,
+		Required:     params.Required,
+		UpdatedBy:    session.GetSessionUserID(c),
+		CreatedTime:  now,
+		ModifiedTime: now,
+	})
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// ResetUserMFA 管理员为丢失设备的用户重置两步验证, 并使其已登录的session失效
+func ResetUserMFA(c *gin.Context) *resp.JSONOutput {
+	params := &MFAResetParams{}
+	err := c.Bind(params)
+	if err != nil || params.UserID <= 0 {
+		logs.Error("[ResetUserMFA] request-params error: params=%+v, err=%v", params, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	if err = mfa.Reset(params.UserID); err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if err = session.InvalidateUserSessions(params.UserID); err != nil {
+		logs.Error("[ResetUserMFA] invalidate sessions failed: user_id=%v, err=%v", params.UserID, err)
+	}
+	logs.Info("[ResetUserMFA] 2fa reset: user_id=%v, operator=%v", params.UserID, session.GetSessionUserID(c))
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// mfaUser 已登录的用户; allowEnroll时也接受待绑定的预认证session
+func mfaUser(c *gin.Context, allowEnroll bool) (*dal.EdgexUser, resp.ErrorCode) {
+	userID := session.GetAuthSessionUserID(c)
+	if userID <= 0 && allowEnroll {
+		var stage string
+		userID, _, stage = session.GetPreAuthSession(c)
+		if stage != session.PreAuthEnroll {
+			userID = 0
+		}
+	}
+	if userID <= 0 {
+		return nil, resp.RespCodeNoPermission
+	}
+	userInfo, err := dal.GetEdgexUserByID(userID)
+	if err != nil {
+		return nil, resp.RespDatabaseError
+	}
+	if userInfo == nil || userInfo.Deleted != 0 {
+		return nil, resp.RespCodeNoPermission
+	}
+	return userInfo, resp.RespCodeSuccess
+}
+
+func mfaErrJSON(c *gin.Context, err error) *resp.JSONOutput {
+	switch err {
+	case mfa.ErrInvalidCode:
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "验证码错误")
+	case mfa.ErrTooManyAttempts:
+		return resp.SampleJSON(c, resp.RespCodeTooFrequent, "错误次数过多, 请稍后再试")
+	case mfa.ErrNotEnabled:
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "未开启两步验证")
+	case mfa.ErrNotEnrolled:
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "请先获取新的密钥")
+	case mfa.ErrAlreadyEnabled:
+		return resp.SampleJSON(c, resp.RespCodeConflict, "已开启两步验证, 请先关闭")
+	case mfa.ErrRequired:
+		return resp.SampleJSON(c, resp.RespCodeNoPermission, "所属角色要求开启两步验证")
+	}
+	return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+}
+
+func preAuthTTL() time.Duration {
+	if config.MFAConf.PreAuthTTL <= 0 {
+		return defaultPreAuthTTL
+	}
+	return time.Duration(config.MFAConf.PreAuthTTL) * time.Second
+}
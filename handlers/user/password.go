@@ -0,0 +1,163 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/mail"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/password"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+const (
+	minPasswordLen = 6
+	forgotCooldown = time.Minute
+)
+
+// ForgotPasswordParams ...
+type ForgotPasswordParams struct {
+	Email string `form:"email" json:"email" binding:"required"`
+}
+
+// ResetPasswordParams ...
+type ResetPasswordParams struct {
+	Token    string `form:"token" json:"token" binding:"required"`
+	Password string `form:"password" json:"password" binding:"required"`
+}
+
+// ForgotPassword 向邮箱发送重置密码链接; 无论邮箱是否注册都返回成功, 避免泄露用户是否存在
+func ForgotPassword(c *gin.Context) *resp.JSONOutput {
+	// Step1. 参数校验
+	params := &ForgotPasswordParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[ForgotPassword] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step2. 同一邮箱冷却期内只发送一次
+	cooldownKey := "edgex_admin:password_forgot:" + strings.ToLower(params.Email)
+	ok, err := caller.RedisClient.SetNX(context.Background(), cooldownKey, time.Now().Unix(), forgotCooldown).Result()
+	if err != nil {
+		logs.Error("[ForgotPassword] check cooldown failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+	}
+	if !ok {
+		return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+	}
+
+	// Step3. 查找用户
+	userInfo, err := dal.GetEdgexUserByEmail(params.Email)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if userInfo == nil || userInfo.Deleted != 0 {
+		logs.Warn("[ForgotPassword] user is Not Exsit: email=%v", params.Email)
+		return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+	}
+
+	// Step4. 生成token并发送邮件
+	token, err := password.IssueResetToken(userInfo.ID)
+	if err != nil {
+		logs.Error("[ForgotPassword] issue token failed: user_id=%v, err=%v", userInfo.ID, err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+	}
+	data := &mail.PasswordResetData{
+		Username: userInfo.Username,
+		Token:    token,
+		Minutes:  int(password.ResetTTL().Minutes()),
+	}
+	if config.PasswordConf.ResetURL != "" {
+		data.Link = config.PasswordConf.ResetURL + "?token=" + url.QueryEscape(token)
+	}
+	msg, err := mail.Render(mail.TemplatePasswordReset, mail.ParseLang(c.GetHeader("Accept-Language")), data, userInfo.Email)
+	if err == nil {
+		err = mail.SendAsync(msg)
+	}
+	if err != nil {
+		logs.Error("[ForgotPassword] send mail failed: user_id=%v, err=%v", userInfo.ID, err)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// ResetPassword 校验一次性token后设置新密码, 并使该用户已登录的session全部失效
+func ResetPassword(c *gin.Context) *resp.JSONOutput {
+	// Step1. 参数校验
+	params := &ResetPasswordParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[ResetPassword] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	if len(params.Password) < minPasswordLen {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, fmt.Sprintf("密码至少%d位", minPasswordLen))
+	}
+
+	// Step2. 校验token, 校验后即失效
+	userID, err := password.ConsumeResetToken(params.Token)
+	if err == password.ErrInvalidToken {
+		logs.Warn("[ResetPassword] token is invalid")
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "链接无效或已过期")
+	}
+	if err != nil {
+		logs.Error("[ResetPassword] consume token failed: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+	}
+	userInfo, err := dal.GetEdgexUserByID(userID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if userInfo == nil || userInfo.Deleted != 0 {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "链接无效或已过期")
+	}
+
+	// Step3. 保存新密码, 同时吊销全部API token
+	hashed, err := password.Hash(params.Password)
+	if err != nil {
+		logs.Error("[ResetPassword] hash password failed: user_id=%v, err=%v", userID, err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+	}
+	updated, revoked, err := resetPassword(userID, userInfo.Password, hashed)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if !updated {
+		// 期间密码被其他请求修改, token已失效, 需要重新申请
+		logs.Warn("[ResetPassword] password changed concurrently: user_id=%v", userID)
+		return resp.SampleJSON(c, resp.RespCodeConflict, nil)
+	}
+
+	// Step4. 踢下线
+	if err = session.InvalidateUserSessions(userID); err != nil {
+		logs.Error("[ResetPassword] invalidate sessions failed: user_id=%v, err=%v", userID, err)
+	}
+	logs.Info("[ResetPassword] password reset: user_id=%v, revoked_tokens=%v", userID, revoked)
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// resetPassword 单事务更新密码并吊销API token, 账号被盗时重置密码后token不能继续使用
+func resetPassword(userID int64, oldHashed string, hashed string) (updated bool, revoked int64, err error) {
+	db := caller.EdgexDB.Begin()
+	defer func() {
+		if err != nil || !updated {
+			db.Rollback()
+		} else {
+			db.Commit()
+		}
+	}()
+	updated, err = dal.UpdateEdgexUserPassword(db, userID, oldHashed, hashed)
+	if err != nil || !updated {
+		return
+	}
+	revoked, err = dal.DeleteUserAPITokens(db, userID)
+	return
+}
@@ -0,0 +1,250 @@
+package user
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/password"
+	"github.com/tdycwym/edgex_admin/resp"
+	"github.com/tdycwym/edgex_admin/sms"
+	"github.com/tdycwym/edgex_admin/vcode"
+)
+
+// 短信验证码的用途
+const (
+	PhoneSceneRegister = "register"
+	PhoneSceneLogin    = "login"
+	PhoneSceneBind     = "bind"
+)
+
+// phone_number没有唯一索引, 注册和绑定时按号码加锁
+const phoneLockTTL = 10 * time.Second
+
+var phoneScenes = map[string]string{
+	PhoneSceneRegister: vcode.ScenePhoneRegister,
+	PhoneSceneLogin:    vcode.ScenePhoneLogin,
+	PhoneSceneBind:     vcode.ScenePhoneBind,
+}
+
+// PhoneCodeParams ...
+type PhoneCodeParams struct {
+	Phone string `form:"phone" json:"phone" binding:"required"`
+	Scene string `form:"scene" json:"scene" binding:"required"`
+}
+
+// PhoneRegisterParams ...
+type PhoneRegisterParams struct {
+	Phone    string `form:"phone" json:"phone" binding:"required"`
+	Code     string `form:"code" json:"code" binding:"required"`
+	Username string `form:"username" json:"username" binding:"required"`
+	Password string `form:"password" json:"password" binding:"required"`
+}
+
+// PhoneLoginParams 也用于绑定手机号
+type PhoneLoginParams struct {
+	Phone string `form:"phone" json:"phone" binding:"required"`
+	Code  string `form:"code" json:"code" binding:"required"`
+}
+
+// SendPhoneCode 发送短信验证码; 登录时号码未注册也返回成功, 但不发送
+func SendPhoneCode(c *gin.Context) *resp.JSONOutput {
+	// Step1. 参数校验
+	params := &PhoneCodeParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[SendPhoneCode] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	scene, ok := phoneScenes[params.Scene]
+	if !ok {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "scene无效")
+	}
+	phone, err := sms.NormalizePhone(params.Phone)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "手机号格式错误")
+	}
+
+	// Step2. 按用途检查号码
+	userInfo, err := dal.GetEdgexUserByPhone(phone)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	switch params.Scene {
+	case PhoneSceneRegister:
+		if userInfo != nil {
+			return resp.SampleJSON(c, resp.RespCodeUserExsit, nil)
+		}
+	case PhoneSceneLogin:
+		if userInfo == nil || userInfo.Deleted != 0 {
+			logs.Warn("[SendPhoneCode] user is Not Exsit: phone=%v", sms.Mask(phone))
+			return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+		}
+	case PhoneSceneBind:
+		userID := session.GetAuthSessionUserID(c)
+		if userID <= 0 {
+			return resp.SampleJSON(c, resp.RespCodeNoPermission, nil)
+		}
+		if userInfo != nil {
+			return resp.SampleJSON(c, resp.RespCodeDuplicate, "手机号已被使用")
+		}
+	}
+
+	// Step3. 发送
+	err = vcode.Send(scene, phone, c.ClientIP(), func(code string) error {
+		return sms.SendCode(phone, code, int(vcode.TTL().Minutes()))
+	})
+	if err == vcode.ErrTooFrequent {
+		return resp.SampleJSON(c, resp.RespCodeTooFrequent, nil)
+	}
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespCodeRPCError, "发送失败")
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// PhoneRegister 使用手机号和短信验证码注册
+func PhoneRegister(c *gin.Context) *resp.JSONOutput {
+	// Step1. 参数校验
+	params := &PhoneRegisterParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[PhoneRegister] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	phone, err := sms.NormalizePhone(params.Phone)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "手机号格式错误")
+	}
+	if len(params.Password) < minPasswordLen {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "密码长度不足")
+	}
+
+	// Step2. 校验验证码
+	if err = vcode.Verify(vcode.ScenePhoneRegister, phone, params.Code); err != nil {
+		logs.Warn("[PhoneRegister] check-code error: phone=%v, err=%v", sms.Mask(phone), err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, verifyErrMessage(err))
+	}
+
+	// Step3. 号码未被占用时添加用户
+	code := lockPhone(phone)
+	if code != resp.RespCodeSuccess {
+		return resp.SampleJSON(c, code, nil)
+	}
+	hashed, err := password.Hash(params.Password)
+	if err != nil {
+		logs.Error("[PhoneRegister] hash password failed: username=%v, err=%v", params.Username, err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+	}
+	user := &dal.EdgexUser{
+		Username:     params.Username,
+		Password:     hashed,
+		PhoneNumber:  phone,
+		CreatedTime:  time.Now(),
+		ModifiedTime: time.Now(),
+	}
+	err = dal.AddEdgexUser(caller.EdgexDB, user)
+	if _, ok := dal.AsDuplicateError(err); ok {
+		return resp.SampleJSON(c, resp.RespCodeUserExsit, nil)
+	}
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// PhoneLogin 使用短信验证码登录
+func PhoneLogin(c *gin.Context) *resp.JSONOutput {
+	// Step1. 查看用户是否已登陆
+	if session.GetSessionUserID(c) > 0 {
+		return resp.SampleJSON(c, resp.RespCodeSuccess, "用户已登陆")
+	}
+
+	// Step2. 参数校验
+	params := &PhoneLoginParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[PhoneLogin] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	phone, err := sms.NormalizePhone(params.Phone)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "手机号格式错误")
+	}
+
+	// Step3. 校验验证码
+	if err = vcode.Verify(vcode.ScenePhoneLogin, phone, params.Code); err != nil {
+		logs.Warn("[PhoneLogin] check-code error: phone=%v, err=%v", sms.Mask(phone), err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, verifyErrMessage(err))
+	}
+	userInfo, err := dal.GetEdgexUserByPhone(phone)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if userInfo == nil || userInfo.Deleted != 0 {
+		logs.Error("[PhoneLogin] user is Not Exsit: phone=%v", sms.Mask(phone))
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step4. session save, 开启两步验证时只保存预认证session
+	return completeLogin(c, userInfo)
+}
+
+// BindPhone 绑定或更换手机号, 需要新号码收到的验证码
+func BindPhone(c *gin.Context) *resp.JSONOutput {
+	// Step1. 参数校验
+	params := &PhoneLoginParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[BindPhone] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	phone, err := sms.NormalizePhone(params.Phone)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "手机号格式错误")
+	}
+	userID := session.GetSessionUserID(c)
+
+	// Step2. 校验验证码
+	if err = vcode.Verify(vcode.ScenePhoneBind, phone, params.Code); err != nil {
+		logs.Warn("[BindPhone] check-code error: user_id=%v, err=%v", userID, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, verifyErrMessage(err))
+	}
+
+	// Step3. 号码未被占用时保存
+	code := lockPhone(phone)
+	if code == resp.RespCodeUserExsit {
+		return resp.SampleJSON(c, resp.RespCodeDuplicate, "手机号已被使用")
+	}
+	if code != resp.RespCodeSuccess {
+		return resp.SampleJSON(c, code, nil)
+	}
+	if err = dal.UpdateEdgexUserPhone(caller.EdgexDB, userID, phone); err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	logs.Info("[BindPhone] phone changed: user_id=%v, phone=%v", userID, sms.Mask(phone))
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// lockPhone 加锁后确认号码未被使用, 锁在phoneLockTTL后自动释放
+func lockPhone(phone string) resp.ErrorCode {
+	ok, err := caller.TryLock("phone:"+phone, phoneLockTTL)
+	if err != nil {
+		logs.Error("[lockPhone] lock failed: phone=%v, err=%v", sms.Mask(phone), err)
+		return resp.RespCodeRedisError
+	}
+	if !ok {
+		return resp.RespCodeConflict
+	}
+	userInfo, err := dal.GetEdgexUserByPhone(phone)
+	if err != nil {
+		return resp.RespDatabaseError
+	}
+	if userInfo != nil {
+		return resp.RespCodeUserExsit
+	}
+	return resp.RespCodeSuccess
+}
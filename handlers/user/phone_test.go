@@ -0,0 +1,267 @@
+//go:build integration
+// +build integration
+
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/password"
+	"github.com/tdycwym/edgex_admin/resp"
+	"github.com/tdycwym/edgex_admin/sms"
+	"github.com/tdycwym/edgex_admin/vcode"
+)
+
+// 集成测试, 需要可用的mysql和redis:
+//   EDGEX_ADMIN_TEST_CONF=config/app.ini go test -tags integration ./handlers/user/
+// 短信使用FakeProvider, 验证码使用MemoryStore以免受发送频率限制; 测试数据在结束时删除
+
+var phoneSeq int64
+
+func TestMain(m *testing.M) {
+	confPath := os.Getenv("EDGEX_ADMIN_TEST_CONF")
+	if confPath == "" {
+		fmt.Println("EDGEX_ADMIN_TEST_CONF is not set, skip integration tests")
+		os.Exit(0)
+	}
+	config.LoadConfig(confPath)
+	logs.InitLogs()
+	caller.InitClient()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+type phoneEnv struct {
+	*httptest.Server
+	t        *testing.T
+	phone    string
+	client   *http.Client
+	provider *sms.FakeProvider
+}
+
+// newPhoneEnv 每个测试使用不同的号码, 结束时删除该号码的用户、两步验证和锁
+func newPhoneEnv(t *testing.T) *phoneEnv {
+	env := &phoneEnv{
+		t:        t,
+		phone:    fmt.Sprintf("+86139%08d", (time.Now().UnixNano()/1000+atomic.AddInt64(&phoneSeq, 1))%100000000),
+		provider: sms.NewFakeProvider(),
+	}
+	sms.SetProvider(env.provider)
+	vcode.SetStore(vcode.NewMemoryStore())
+	t.Cleanup(func() {
+		sms.SetProvider(nil)
+		vcode.SetStore(vcode.NewRedisStore(nil))
+		if user, _ := dal.GetEdgexUserByPhone(env.phone); user != nil {
+			caller.EdgexDB.Where("user_id = ?", user.ID).Delete(&dal.EdgexUserMFA{})
+		}
+		caller.EdgexDB.Where("phone_number = ?", env.phone).Delete(&dal.EdgexUser{})
+		caller.RedisClient.Del(context.Background(), "edgex_admin:lock:phone:"+env.phone)
+	})
+
+	r := gin.New()
+	r.Use(sessions.Sessions("session_token", cookie.NewStore([]byte(session.KEY))))
+	r.POST("/phone/code", session.SessionMiddleware(), resp.JSONOutPutWrapper(SendPhoneCode))
+	r.POST("/phone/register", resp.JSONOutPutWrapper(PhoneRegister))
+	r.POST("/phone/login", session.SessionMiddleware(), resp.JSONOutPutWrapper(PhoneLogin))
+	r.GET("/mine", session.SessionMiddleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": session.GetSessionUserID(c)})
+	})
+	env.Server = httptest.NewServer(r)
+	t.Cleanup(env.Close)
+
+	jar, _ := cookiejar.New(nil)
+	env.client = &http.Client{Jar: jar}
+	return env
+}
+
+func (env *phoneEnv) post(path string, params interface{}) *resp.StdResponse {
+	body, _ := json.Marshal(params)
+	rsp, err := env.client.Post(env.URL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	out := &resp.StdResponse{}
+	if err = json.NewDecoder(rsp.Body).Decode(out); err != nil {
+		env.t.Fatalf("%s: decode response failed: %v", path, err)
+	}
+	return out
+}
+
+// sendCode 请求发送验证码并返回短信中的验证码, 未发送时返回空串
+func (env *phoneEnv) sendCode(phone string, scene string) (int32, string) {
+	before := env.provider.Last(env.phone)
+	out := env.post("/phone/code", &PhoneCodeParams{Phone: phone, Scene: scene})
+	last := env.provider.Last(env.phone)
+	if last == nil || last == before {
+		return out.Status, ""
+	}
+	return out.Status, last.Params[0]
+}
+
+func (env *phoneEnv) sessionUserID() int64 {
+	rsp, err := env.client.Get(env.URL + "/mine")
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	out := struct {
+		UserID int64 `json:"user_id"`
+	}{}
+	_ = json.NewDecoder(rsp.Body).Decode(&out)
+	return out.UserID
+}
+
+// addUser 直接写库添加用户
+func (env *phoneEnv) addUser() *dal.EdgexUser {
+	user := &dal.EdgexUser{
+		Username:     "phone-test-" + env.phone[1:],
+		PhoneNumber:  env.phone,
+		CreatedTime:  time.Now(),
+		ModifiedTime: time.Now(),
+	}
+	if err := dal.AddEdgexUser(caller.EdgexDB, user); err != nil {
+		env.t.Fatal(err)
+	}
+	return user
+}
+
+func TestPhoneRegisterAndLogin(t *testing.T) {
+	env := newPhoneEnv(t)
+	local := env.phone[3:] // 不带国家码的号码
+
+	status, code := env.sendCode(local, PhoneSceneRegister)
+	if status != int32(resp.RespCodeSuccess) || code == "" {
+		t.Fatalf("send register code: status=%d, code=%q", status, code)
+	}
+	params := &PhoneRegisterParams{Phone: local, Code: "x" + code, Username: "phone-test-" + local, Password: "p@ssw0rd"}
+	if out := env.post("/phone/register", params); out.Status != int32(resp.RespCodeParamsError) {
+		t.Fatalf("register with wrong code: status=%d", out.Status)
+	}
+	params.Code = code
+	if out := env.post("/phone/register", params); out.Status != int32(resp.RespCodeSuccess) {
+		t.Fatalf("register: status=%d, message=%s", out.Status, out.Message)
+	}
+	user, err := dal.GetEdgexUserByPhone(env.phone)
+	if err != nil || user == nil || user.Username != params.Username {
+		t.Fatalf("user not added: user=%+v, err=%v", user, err)
+	}
+	if _, err = password.Verify(user.Password, "p@ssw0rd"); err != nil {
+		t.Fatalf("password not hashed: %v", err)
+	}
+	// 验证码只能使用一次
+	if out := env.post("/phone/register", params); out.Status != int32(resp.RespCodeParamsError) {
+		t.Fatalf("register with used code: status=%d", out.Status)
+	}
+	// 已注册的号码不再发送注册验证码
+	if status, code = env.sendCode(env.phone, PhoneSceneRegister); status != int32(resp.RespCodeUserExsit) || code != "" {
+		t.Fatalf("send register code again: status=%d, code=%q", status, code)
+	}
+
+	status, code = env.sendCode(local, PhoneSceneLogin)
+	if status != int32(resp.RespCodeSuccess) || code == "" {
+		t.Fatalf("send login code: status=%d, code=%q", status, code)
+	}
+	if out := env.post("/phone/login", &PhoneLoginParams{Phone: env.phone, Code: code}); out.Status != int32(resp.RespCodeSuccess) {
+		t.Fatalf("login: status=%d, message=%s", out.Status, out.Message)
+	}
+	if userID := env.sessionUserID(); userID != user.ID {
+		t.Fatalf("session user = %d, want %d", userID, user.ID)
+	}
+}
+
+func TestPhoneLoginUnregistered(t *testing.T) {
+	env := newPhoneEnv(t)
+
+	// 不暴露号码是否注册, 但不发送短信
+	status, code := env.sendCode(env.phone, PhoneSceneLogin)
+	if status != int32(resp.RespCodeSuccess) || code != "" {
+		t.Fatalf("send login code: status=%d, code=%q", status, code)
+	}
+	if out := env.post("/phone/login", &PhoneLoginParams{Phone: env.phone, Code: "123456"}); out.Status != int32(resp.RespCodeParamsError) {
+		t.Fatalf("login: status=%d", out.Status)
+	}
+	if userID := env.sessionUserID(); userID != 0 {
+		t.Fatalf("session user = %d, want 0", userID)
+	}
+}
+
+func TestPhoneLoginWrongCode(t *testing.T) {
+	env := newPhoneEnv(t)
+	user := env.addUser()
+
+	_, code := env.sendCode(env.phone, PhoneSceneLogin)
+	if code == "" {
+		t.Fatal("login code not sent")
+	}
+	if out := env.post("/phone/login", &PhoneLoginParams{Phone: env.phone, Code: "x" + code}); out.Status != int32(resp.RespCodeParamsError) {
+		t.Fatalf("login with wrong code: status=%d", out.Status)
+	}
+	// 输错一次后正确的验证码仍然有效, 且不能用于注册
+	if out := env.post("/phone/login", &PhoneLoginParams{Phone: env.phone, Code: code}); out.Status != int32(resp.RespCodeSuccess) {
+		t.Fatalf("login: status=%d", out.Status)
+	}
+	if userID := env.sessionUserID(); userID != user.ID {
+		t.Fatalf("session user = %d, want %d", userID, user.ID)
+	}
+	if err := vcode.Verify(vcode.ScenePhoneRegister, env.phone, code); err != vcode.ErrNotFound {
+		t.Fatalf("login code verified for register: err=%v", err)
+	}
+}
+
+func TestPhoneLoginMFA(t *testing.T) {
+	env := newPhoneEnv(t)
+	user := env.addUser()
+	now := time.Now()
+	err := dal.SaveUserMFA(caller.EdgexDB, &dal.EdgexUserMFA{
+		UserID: user.ID, Enabled: true, ConfirmedTime: now, CreatedTime: now, ModifiedTime: now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, code := env.sendCode(env.phone, PhoneSceneLogin)
+	out := env.post("/phone/login", &PhoneLoginParams{Phone: env.phone, Code: code})
+	data, _ := out.Data.(map[string]interface{})
+	if out.Status != int32(resp.RespCodeSuccess) || data["need_2fa"] != true {
+		t.Fatalf("login: status=%d, data=%v", out.Status, out.Data)
+	}
+	// 只保存预认证session
+	if userID := env.sessionUserID(); userID != 0 {
+		t.Fatalf("session user = %d, want 0", userID)
+	}
+}
+
+func TestPhoneRegisterLocked(t *testing.T) {
+	env := newPhoneEnv(t)
+
+	_, code := env.sendCode(env.phone, PhoneSceneRegister)
+	if ok, err := caller.TryLock("phone:"+env.phone, phoneLockTTL); err != nil || !ok {
+		t.Fatalf("lock phone: ok=%v, err=%v", ok, err)
+	}
+	params := &PhoneRegisterParams{Phone: env.phone, Code: code, Username: "phone-test-" + env.phone[1:], Password: "p@ssw0rd"}
+	if out := env.post("/phone/register", params); out.Status != int32(resp.RespCodeConflict) {
+		t.Fatalf("register: status=%d", out.Status)
+	}
+	if user, _ := dal.GetEdgexUserByPhone(env.phone); user != nil {
+		t.Fatalf("user should not be added: %+v", user)
+	}
+}
@@ -0,0 +1,355 @@
+package user
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/password"
+	"github.com/tdycwym/edgex_admin/resp"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultQuestionCount = 2
+	maxQuestionCount     = 5
+	maxAnswerLen         = 100
+)
+
+// SecurityAnswer ...
+type SecurityAnswer struct {
+	QuestionID int64  `json:"question_id"`
+	Answer     string `json:"answer"`
+}
+
+// SecurityTokenParams 未登录时可使用重置token
+type SecurityTokenParams struct {
+	Token string `json:"token"`
+}
+
+// SaveSecurityAnswersParams ...
+type SaveSecurityAnswersParams struct {
+	Token   string            `json:"token"`
+	Answers []*SecurityAnswer `json:"answers"`
+}
+
+// VerifySecurityAnswersParams ...
+type VerifySecurityAnswersParams struct {
+	Username string            `json:"username" binding:"required"`
+	Answers  []*SecurityAnswer `json:"answers"`
+}
+
+// UserQuestionsParams ...
+type UserQuestionsParams struct {
+	Username string `form:"username" json:"username" binding:"required"`
+}
+
+// SecurityQuestionParams ...
+type SecurityQuestionParams struct {
+	ID      int64  `form:"id" json:"id"`
+	Content string `form:"content" json:"content"`
+}
+
+// GetSecurityQuestions 可选的密保问题
+func GetSecurityQuestions(c *gin.Context) *resp.JSONOutput {
+	questionList, err := dal.GetSecurityQuestionList()
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	infoList := make([]*model.SecurityQuestionInfo, 0, len(questionList))
+	for _, question := range questionList {
+		infoList = append(infoList, &model.SecurityQuestionInfo{ID: question.ID, Content: question.Content})
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, infoList)
+}
+
+// GetUserSecurityQuestions 找回密码时展示用户设置的问题
+func GetUserSecurityQuestions(c *gin.Context) *resp.JSONOutput {
+	params := &UserQuestionsParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[GetUserSecurityQuestions] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	userInfo, err := dal.GetEdgexUserByName(params.Username)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if userInfo == nil || userInfo.Deleted != 0 {
+		logs.Warn("[GetUserSecurityQuestions] user is Not Exsit: username=%v", params.Username)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	return userSecurityQuestions(c, userInfo.ID)
+}
+
+// GetMySecurityQuestions 当前用户设置的问题, 需登录或在请求体中携带重置token
+func GetMySecurityQuestions(c *gin.Context) *resp.JSONOutput {
+	params := &SecurityTokenParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[GetMySecurityQuestions] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	userID, code := securityUserID(c, params.Token)
+	if code != resp.RespCodeSuccess {
+		return resp.SampleJSON(c, code, nil)
+	}
+	return userSecurityQuestions(c, userID)
+}
+
+// SaveSecurityAnswers 覆盖当前用户的全部密保, 需登录或持有重置token
+func SaveSecurityAnswers(c *gin.Context) *resp.JSONOutput {
+	// Step1. 参数校验
+	params := &SaveSecurityAnswersParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[SaveSecurityAnswers] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	userID, code := securityUserID(c, params.Token)
+	if code != resp.RespCodeSuccess {
+		return resp.SampleJSON(c, code, nil)
+	}
+	if err = checkAnswers(params.Answers, questionCount()); err != nil {
+		logs.Warn("[SaveSecurityAnswers] params-err: user_id=%v, err=%v", userID, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+
+	// Step2. 问题需在目录中且未删除
+	questionIDs := make([]int64, 0, len(params.Answers))
+	for _, answer := range params.Answers {
+		questionIDs = append(questionIDs, answer.QuestionID)
+	}
+	questionMap, err := dal.GetSecurityQuestionMapByIDs(questionIDs)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	for _, id := range questionIDs {
+		if question, ok := questionMap[id]; !ok || question.Deleted != 0 {
+			return resp.SampleJSON(c, resp.RespCodeParamsError, fmt.Sprintf("问题不存在: %d", id))
+		}
+	}
+
+	// Step3. 规范化后哈希保存
+	now := time.Now()
+	itemList := make([]*dal.EdgexSecurityAnswer, 0, len(params.Answers))
+	for _, answer := range params.Answers {
+		hashed, err := password.HashAnswer(answer.Answer)
+		if err != nil {
+			logs.Error("[SaveSecurityAnswers] hash answer failed: user_id=%v, err=%v", userID, err)
+			return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+		}
+		itemList = append(itemList, &dal.EdgexSecurityAnswer{
+			UserID:       userID,
+			QuestionID:   answer.QuestionID,
+			Answer:       hashed,
+			CreatedTime:  now,
+			ModifiedTime: now,
+		})
+	}
+	err = caller.EdgexDB.Transaction(func(db *gorm.DB) error {
+		return dal.ReplaceSecurityAnswers(db, userID, itemList)
+	})
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	password.ClearAnswerAttempts(userID)
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// VerifySecurityAnswers 需回答用户设置的全部问题, 通过后颁发重置token
+func VerifySecurityAnswers(c *gin.Context) *resp.JSONOutput {
+	// Step1. 参数校验
+	params := &VerifySecurityAnswersParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[VerifySecurityAnswers] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	if err = checkAnswers(params.Answers, 1); err != nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+	userInfo, err := dal.GetEdgexUserByName(params.Username)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if userInfo == nil || userInfo.Deleted != 0 {
+		logs.Warn("[VerifySecurityAnswers] user is Not Exsit: username=%v", params.Username)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	// Step2. 错误次数限制, 比对前先计数
+	err = password.AcquireAnswerAttempt(userInfo.ID)
+	if err == password.ErrTooManyAttempts {
+		logs.Warn("[VerifySecurityAnswers] too many attempts: user_id=%v", userInfo.ID)
+		return resp.SampleJSON(c, resp.RespCodeTooFrequent, "错误次数过多, 请稍后再试")
+	}
+	if err != nil {
+		logs.Error("[VerifySecurityAnswers] acquire attempt failed: user_id=%v, err=%v", userInfo.ID, err)
+		return resp.SampleJSON(c, resp.RespCodeRedisError, nil)
+	}
+
+	// Step3. 逐个比对
+	answerList, err := dal.GetSecurityAnswerList(userInfo.ID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if len(answerList) == 0 {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "未设置密保")
+	}
+	given := make(map[int64]string, len(params.Answers))
+	for _, answer := range params.Answers {
+		given[answer.QuestionID] = answer.Answer
+	}
+	matched := len(given) == len(answerList)
+	for _, item := range answerList {
+		answer, ok := given[item.QuestionID]
+		if !ok || password.VerifyAnswer(item.Answer, answer) != nil {
+			matched = false
+		}
+	}
+	if !matched {
+		logs.Warn("[VerifySecurityAnswers] answer mismatch: user_id=%v", userInfo.ID)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "密保错误")
+	}
+	password.ClearAnswerAttempts(userInfo.ID)
+
+	// Step4. 颁发重置token
+	token, err := password.IssueResetToken(userInfo.ID)
+	if err != nil {
+		logs.Error("[VerifySecurityAnswers] issue token failed: user_id=%v, err=%v", userInfo.ID, err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, &model.SecurityVerifyResult{
+		Token:     token,
+		ExpiresIn: int64(password.ResetTTL().Seconds()),
+	})
+}
+
+// SaveSecurityQuestion 管理员新增或修改问题, id为0时新增
+func SaveSecurityQuestion(c *gin.Context) *resp.JSONOutput {
+	params := &SecurityQuestionParams{}
+	err := c.Bind(params)
+	if err != nil || params.Content == "" || len([]rune(params.Content)) > 100 {
+		logs.Error("[SaveSecurityQuestion] request-params error: params=%+v, err=%v", params, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	now := time.Now()
+	if params.ID == 0 {
+		question := &dal.EdgexSecurityQuestion{Content: params.Content, CreatedTime: now, ModifiedTime: now}
+		if err = dal.AddSecurityQuestion(caller.EdgexDB, question); err != nil {
+			return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+		}
+		return resp.SampleJSON(c, resp.RespCodeSuccess, &model.SecurityQuestionInfo{ID: question.ID, Content: question.Content})
+	}
+	updated, err := dal.UpdateSecurityQuestion(caller.EdgexDB, params.ID, map[string]interface{}{
+		"content":       params.Content,
+		"modified_time": now,
+	})
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if !updated {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, &model.SecurityQuestionInfo{ID: params.ID, Content: params.Content})
+}
+
+// DeleteSecurityQuestion 管理员删除问题, 已设置该问题的用户仍可用其找回密码
+func DeleteSecurityQuestion(c *gin.Context) *resp.JSONOutput {
+	params := &SecurityQuestionParams{}
+	err := c.Bind(params)
+	if err != nil || params.ID <= 0 {
+		logs.Error("[DeleteSecurityQuestion] request-params error: params=%+v, err=%v", params, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	updated, err := dal.UpdateSecurityQuestion(caller.EdgexDB, params.ID, map[string]interface{}{
+		"deleted":       1,
+		"modified_time": time.Now(),
+	})
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if !updated {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// securityUserID 优先使用重置token, 否则需已登录
+func securityUserID(c *gin.Context, token string) (int64, resp.ErrorCode) {
+	if token != "" {
+		userID, err := password.PeekResetToken(token)
+		if err == password.ErrInvalidToken {
+			return 0, resp.RespCodeNoPermission
+		}
+		if err != nil {
+			logs.Error("[securityUserID] check token failed: err=%v", err)
+			return 0, resp.RespCodeRedisError
+		}
+		return userID, resp.RespCodeSuccess
+	}
+	userID := session.GetAuthSessionUserID(c)
+	if userID <= 0 {
+		return 0, resp.RespCodeNoPermission
+	}
+	return userID, resp.RespCodeSuccess
+}
+
+func userSecurityQuestions(c *gin.Context, userID int64) *resp.JSONOutput {
+	answerList, err := dal.GetSecurityAnswerList(userID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	questionIDs := make([]int64, 0, len(answerList))
+	for _, answer := range answerList {
+		questionIDs = append(questionIDs, answer.QuestionID)
+	}
+	questionMap, err := dal.GetSecurityQuestionMapByIDs(questionIDs)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	infoList := make([]*model.SecurityQuestionInfo, 0, len(questionIDs))
+	for _, id := range questionIDs {
+		if question, ok := questionMap[id]; ok {
+			infoList = append(infoList, &model.SecurityQuestionInfo{ID: question.ID, Content: question.Content})
+		}
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, infoList)
+}
+
+// checkAnswers 问题不重复、答案非空
+func checkAnswers(answers []*SecurityAnswer, minCount int) error {
+	if len(answers) < minCount || len(answers) > maxQuestionCount {
+		return fmt.Errorf("密保问题个数需在%d-%d之间", minCount, maxQuestionCount)
+	}
+	seen := make(map[int64]bool, len(answers))
+	for _, answer := range answers {
+		if answer == nil || answer.QuestionID <= 0 || seen[answer.QuestionID] {
+			return fmt.Errorf("密保问题无效或重复")
+		}
+		seen[answer.QuestionID] = true
+		normalized := password.NormalizeAnswer(answer.Answer)
+		if normalized == "" || len([]rune(normalized)) > maxAnswerLen {
+			return fmt.Errorf("答案不能为空且不超过%d个字符", maxAnswerLen)
+		}
+	}
+	return nil
+}
+
+func questionCount() int {
+	count := config.PasswordConf.QuestionCount
+	if count <= 0 {
+		return defaultQuestionCount
+	}
+	if count > maxQuestionCount {
+		return maxQuestionCount
+	}
+	return count
+}
@@ -0,0 +1,169 @@
+package user
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/apitoken"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/constdef"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+)
+
+const maxTokenNameLen = 64
+
+// CreateTokenParams scopes逗号分隔, 为空时只读; expire_days为0时使用默认有效期
+type CreateTokenParams struct {
+	Name       string `form:"name" json:"name" binding:"required"`
+	Scopes     string `form:"scopes" json:"scopes"`
+	ExpireDays int    `form:"expire_days" json:"expire_days"`
+}
+
+// DeleteTokenParams 管理员可以传user_id吊销其他用户的token
+type DeleteTokenParams struct {
+	ID     int64 `form:"id" json:"id" binding:"required"`
+	UserID int64 `form:"user_id" json:"user_id"`
+}
+
+// GetTokenList 当前用户的token, 管理员可以传user_id查看其他用户
+func GetTokenList(c *gin.Context) *resp.JSONOutput {
+	userID, code := tokenOwner(c, c.Query("user_id"))
+	if code != resp.RespCodeSuccess {
+		return resp.SampleJSON(c, code, nil)
+	}
+	itemList, err := dal.GetAPITokenList(userID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	infoList := make([]*model.APITokenInfo, 0, len(itemList))
+	for _, item := range itemList {
+		infoList = append(infoList, buildTokenInfo(item))
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, infoList)
+}
+
+// CreateToken 创建后返回token明文, 之后无法再次查看
+func CreateToken(c *gin.Context) *resp.JSONOutput {
+	// Step1. checkParams
+	params := &CreateTokenParams{}
+	err := c.Bind(params)
+	if err != nil {
+		logs.Error("[CreateToken] request-params error: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || utf8.RuneCountInString(params.Name) > maxTokenNameLen {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "name is invalid")
+	}
+	if params.ExpireDays < 0 || params.ExpireDays > apitoken.MaxTTLDays() {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "expire_days is invalid")
+	}
+	scopes, err := apitoken.ParseScopes(params.Scopes)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "scopes is invalid")
+	}
+
+	// Step2. admin权限只能由管理员创建
+	userID := session.GetSessionUserID(c)
+	userInfo, err := dal.GetEdgexUserByID(userID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if userInfo == nil {
+		return resp.SampleJSON(c, resp.RespCodeNoPermission, nil)
+	}
+	if apitoken.HasScope(strings.Join(scopes, ","), apitoken.ScopeAdmin) && userInfo.Role != dal.RoleAdmin {
+		return resp.SampleJSON(c, resp.RespCodeNoPermission, "admin scope requires admin role")
+	}
+
+	// Step3. create
+	item, token, ok, err := apitoken.Create(userID, params.Name, scopes, params.ExpireDays)
+	if dupErr, isDup := dal.AsDuplicateError(err); isDup {
+		return resp.SampleJSON(c, resp.RespCodeDuplicate, &model.DuplicateInfo{
+			Field: dupErr.Field,
+			Value: params.Name,
+		})
+	}
+	if err != nil {
+		logs.Error("[CreateToken] create token failed: user_id=%v, err=%v", userID, err)
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if !ok {
+		return resp.SampleJSON(c, resp.RespCodeConflict, "token数量已达上限")
+	}
+	logs.Info("[CreateToken] token created: user_id=%v, id=%v, scopes=%v", userID, item.ID, item.Scopes)
+	info := buildTokenInfo(item)
+	info.Token = token
+	return resp.SampleJSON(c, resp.RespCodeSuccess, info)
+}
+
+// DeleteToken 吊销后立即失效
+func DeleteToken(c *gin.Context) *resp.JSONOutput {
+	params := &DeleteTokenParams{}
+	err := c.Bind(params)
+	if err != nil || params.ID <= 0 {
+		logs.Error("[DeleteToken] request-params error: params=%+v, err=%v", params, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	userID := session.GetSessionUserID(c)
+	if params.UserID > 0 && params.UserID != userID {
+		if !isAdmin(userID) {
+			return resp.SampleJSON(c, resp.RespCodeNoPermission, nil)
+		}
+		userID = params.UserID
+	}
+	deleted, err := dal.DeleteAPIToken(caller.EdgexDB, params.ID, userID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if !deleted {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "token not found")
+	}
+	logs.Info("[DeleteToken] token revoked: id=%v, owner=%v, operator=%v", params.ID, userID, session.GetSessionUserID(c))
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// tokenOwner rawUserID为空时返回当前用户, 查看其他用户需要管理员
+func tokenOwner(c *gin.Context, rawUserID string) (int64, resp.ErrorCode) {
+	userID := session.GetSessionUserID(c)
+	if rawUserID == "" {
+		return userID, resp.RespCodeSuccess
+	}
+	ownerID, err := strconv.ParseInt(rawUserID, 10, 64)
+	if err != nil || ownerID <= 0 {
+		return 0, resp.RespCodeParamsError
+	}
+	if ownerID != userID && !isAdmin(userID) {
+		return 0, resp.RespCodeNoPermission
+	}
+	return ownerID, resp.RespCodeSuccess
+}
+
+func isAdmin(userID int64) bool {
+	userInfo, err := dal.GetEdgexUserByID(userID)
+	return err == nil && userInfo != nil && userInfo.Role == dal.RoleAdmin
+}
+
+func buildTokenInfo(item *dal.EdgexAPIToken) *model.APITokenInfo {
+	info := &model.APITokenInfo{
+		ID:          item.ID,
+		Name:        item.Name,
+		TokenPrefix: item.TokenPrefix,
+		Scopes:      strings.Split(item.Scopes, ","),
+		ExpireTime:  item.ExpireTime.Format(constdef.TimeFormat),
+		Expired:     item.ExpireTime.Before(time.Now()),
+		LastUsedIP:  item.LastUsedIP,
+		CreatedTime: item.CreatedTime.Format(constdef.TimeFormat),
+	}
+	if item.LastUsedTime != nil {
+		info.LastUsedTime = item.LastUsedTime.Format(constdef.TimeFormat)
+	}
+	return info
+}
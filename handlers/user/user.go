@@ -1,20 +1,17 @@
 package user
 
 import (
-	"errors"
-	"fmt"
-	"math/rand"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tdycwym/edgex_admin/caller"
 	"github.com/tdycwym/edgex_admin/dal"
 	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/mail"
 	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/password"
 	"github.com/tdycwym/edgex_admin/resp"
-	"github.com/tdycwym/edgex_admin/utils"
-	"gopkg.in/gomail.v2"
+	"github.com/tdycwym/edgex_admin/vcode"
 )
 
 type LoginParams struct {
@@ -33,7 +30,7 @@ func Login(c *gin.Context) *resp.JSONOutput {
 	params := &LoginParams{}
 	err := c.Bind(&params)
 	if err != nil || (params.UserID <= 0 && params.Username == "") {
-		logs.Error("[Login] request-params error: params=%+v, err=%v", params, err)
+		logs.Error("[Login] request-params error: user_id=%v, username=%v, err=%v", params.UserID, params.Username, err)
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
 
@@ -50,24 +47,40 @@ func Login(c *gin.Context) *resp.JSONOutput {
 	}
 
 	if dbErr != nil {
-		logs.Error("[Login] get userInfo failed: params=%+v, err=%v", params, err)
+		logs.Error("[Login] get userInfo failed: user_id=%v, username=%v, err=%v", params.UserID, params.Username, dbErr)
 		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
 	}
 	if userInfo == nil {
-		logs.Error("[Login] user is Not Exsit: params=%+v, userInfo=%+v", params, userInfo)
+		logs.Error("[Login] user is Not Exsit: user_id=%v, username=%v", params.UserID, params.Username)
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
 
-	// Step4. 密码比对
-	err = utils.Compare(userInfo.Password, params.Password)
+	// Step4. 密码比对, 明文或参数过期的哈希在登录成功后重新计算
+	needRehash, err := password.Verify(userInfo.Password, params.Password)
 	if err != nil {
-		logs.Error("[Login] password is invalid:  params=%+v, err=%v", params, err)
+		logs.Error("[Login] password is invalid: user_id=%v, err=%v", userInfo.ID, err)
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
+	if needRehash {
+		rehash(userInfo, params.Password)
+	}
 
-	// step5. session save
-	session.SaveAuthSession(c, userInfo.ID, userInfo.Username)
-	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+	// step5. session save, 开启两步验证时只保存预认证session
+	return completeLogin(c, userInfo)
+}
+
+// rehash 失败不影响本次登录, 下次登录时重试
+func rehash(userInfo *dal.EdgexUser, plain string) {
+	hashed, err := password.Hash(plain)
+	if err != nil {
+		logs.Error("[rehash] hash password failed: user_id=%v, err=%v", userInfo.ID, err)
+		return
+	}
+	_, err = dal.UpdateEdgexUserPassword(caller.EdgexDB, userInfo.ID, userInfo.Password, hashed)
+	if err != nil {
+		return
+	}
+	logs.Info("[rehash] password rehashed: user_id=%v", userInfo.ID)
 }
 
 // RegisterParams ...
@@ -83,19 +96,13 @@ type RegisterCheckParams struct {
 	Email    string `form:"email" json:"email" binding:"required"`
 	Code     string `form:"code" json:"code" binding:"required"`
 }
-type CodeChecker struct {
-	Email string
-	Code  string
-}
-
-var checker map[string]CodeChecker
 
 func Register(c *gin.Context) *resp.JSONOutput {
 	// Step1. 参数校验
 	params := &RegisterParams{}
 	err := c.Bind(&params)
 	if err != nil {
-		logs.Error("[Register] request-params error: params=%+v, err=%v", params, err)
+		logs.Error("[Register] request-params error: username=%v, email=%v, err=%v", params.Username, params.Email, err)
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
 
@@ -107,29 +114,22 @@ func Register(c *gin.Context) *resp.JSONOutput {
 		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
 	}
 	if dbErr2 != nil {
-		logs.Error("[Register] email [%s] already exists: err=%v", dbErr2)
+		logs.Error("[Register] email [%s] already exists: err=%v", params.Email, dbErr2)
 	}
 	if userInfo != nil && mailInfo != nil {
 		return resp.SampleJSON(c, resp.RespCodeUserExsit, nil)
 	}
 
 	//Step3. 发送邮箱验证码
-	mailTo := []string{params.Email}
-	subject := string("登录验证")
-	code := randomCode()
-	body := code
-	err = SendMailTo(mailTo, subject, body)
+	err = vcode.Send(vcode.SceneRegister, params.Email, c.ClientIP(), func(code string) error {
+		return sendCodeMail(c, params.Email, code)
+	})
+	if err == vcode.ErrTooFrequent {
+		return resp.SampleJSON(c, resp.RespCodeTooFrequent, nil)
+	}
 	if err != nil {
 		return resp.SampleJSON(c, resp.RespCodeParamsError, "发送失败")
 	}
-
-	if checker == nil {
-		checker = make(map[string]CodeChecker)
-	}
-	var cc CodeChecker
-	cc.Email = params.Email
-	cc.Code = code
-	checker[params.Email] = cc
 	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
 }
 
@@ -137,24 +137,32 @@ func RegisterCheck(c *gin.Context) *resp.JSONOutput {
 	params := &RegisterCheckParams{}
 	err := c.Bind(&params)
 	if err != nil {
-		logs.Error("[RegisterCheck] request-params error: params=%+v, err=%v", params, err)
+		logs.Error("[RegisterCheck] request-params error: username=%v, email=%v, err=%v", params.Username, params.Email, err)
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
 	//验证验证码
-	err = checkCode(params.Email, params.Code)
+	err = vcode.Verify(vcode.SceneRegister, params.Email, params.Code)
 	if err != nil {
-		logs.Error("[RegisterCheck] check-code error: params=%+v, err=%v", params, err)
-		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+		logs.Error("[RegisterCheck] check-code error: username=%v, email=%v, err=%v", params.Username, params.Email, err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, verifyErrMessage(err))
+	}
+	hashed, err := password.Hash(params.Password)
+	if err != nil {
+		logs.Error("[RegisterCheck] hash password failed: username=%v, err=%v", params.Username, err)
+		return resp.SampleJSON(c, resp.RespCodeServerException, nil)
 	}
 	//添加用户
 	user := &dal.EdgexUser{
 		Username:     params.Username,
-		Password:     params.Password,
+		Password:     hashed,
 		Email:        params.Email,
 		CreatedTime:  time.Now(),
 		ModifiedTime: time.Now(),
 	}
 	dbErr := dal.AddEdgexUser(caller.EdgexDB, user)
+	if _, ok := dal.AsDuplicateError(dbErr); ok {
+		return resp.SampleJSON(c, resp.RespCodeUserExsit, nil)
+	}
 	if dbErr != nil {
 		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
 	}
@@ -174,42 +182,6 @@ type MailParam struct {
 	Email string `form:"email" json:"email" binding:"required"`
 }
 
-func SendMailTo(mailTo []string, subject string, body string) error {
-	//定义邮箱服务器连接信息，如果是网易邮箱 pass填密码，qq邮箱填授权码
-
-	//mailConn := map[string]string{
-	//  "user": "xxx@163.com",
-	//  "pass": "your password",
-	//  "host": "smtp.163.com",
-	//  "port": "465",
-	//}
-
-	mailConn := map[string]string{
-		"user": "2369351080@qq.com",
-		"pass": "inkdesahnqrjdjeg",
-		"host": "smtp.qq.com",
-		"port": "465",
-	}
-
-	port, _ := strconv.Atoi(mailConn["port"]) //转换端口类型为int
-
-	m := gomail.NewMessage()
-
-	m.SetHeader("From", m.FormatAddress(mailConn["user"], "NJU-IOT-EDGEX验证")) //这种方式可以添加别名，即“XX官方”　 //说明：如果是用网易邮箱账号发送，以下方法别名可以是中文，如果是qq企业邮箱，以下方法用中文别名，会报错，需要用上面此方法转码
-	//m.SetHeader("From", "FB Sample"+"<"+mailConn["user"]+">") //这种方式可以添加别名，即“FB Sample”， 也可以直接用<code>m.SetHeader("From",mailConn["user"])</code> 读者可以自行实验下效果
-	//m.SetHeader("From", mailConn["user"])
-	m.SetHeader("To", mailTo...)    //发送给多个用户
-	m.SetHeader("Subject", subject) //设置邮件主题
-	m.SetBody("text/html", body)    //设置邮件正文
-
-	d := gomail.NewDialer(mailConn["host"], port, mailConn["user"], mailConn["pass"])
-
-	err := d.DialAndSend(m)
-	fmt.Printf("%s", err)
-	return err
-
-}
-
 func SendMail(c *gin.Context) *resp.JSONOutput {
 	params := &MailParam{}
 	err := c.Bind(&params)
@@ -217,125 +189,37 @@ func SendMail(c *gin.Context) *resp.JSONOutput {
 		logs.Error("[SendMail] request-params error: params=%+v, err=%v", params, err)
 		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
 	}
-	mailTo := []string{params.Email}
-	subject := string("登录验证")
-	code := randomCode()
-	body := code
-	err = SendMailTo(mailTo, subject, body)
-	if err != nil {
-		return resp.SampleJSON(c, resp.RespCodeParamsError, "发送失败")
-	}
-	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
-}
-
-func randomCode() string {
-	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
-	vcode := fmt.Sprintf("%06v", rnd.Int31n(1000000))
-	return vcode
-}
-
-func checkCode(email string, code string) error {
-	if checker == nil {
-		checker = make(map[string]CodeChecker)
-		return errors.New("no email found")
-	}
-	c, ok := checker[email]
-	if !ok {
-		return errors.New("no email found")
+	err = vcode.Send(vcode.SceneTest, params.Email, c.ClientIP(), func(code string) error {
+		return sendCodeMail(c, params.Email, code)
+	})
+	if err == vcode.ErrTooFrequent {
+		return resp.SampleJSON(c, resp.RespCodeTooFrequent, nil)
 	}
-	if c.Code == code {
-		return nil
-	}
-	return errors.New("wrong code")
-}
-
-func setEntryptedQuestions(questionCode int, user_id int64, answer string) {
-
-}
-
-func updateEntrypted(user_name string, entrypted string) error {
-	var fieldsMap map[string]interface{} = map[string]interface{}{"entrypted": entrypted}
-	err := dal.UpdateEdgexUser(user_name, fieldsMap)
-	return err
-}
-
-func updateassword(user_name string, password string) error {
-	var fieldsMap map[string]interface{} = map[string]interface{}{"password": password}
-	err := dal.UpdateEdgexUser(user_name, fieldsMap)
-	return err
-}
-
-type EntryptedParams struct {
-	UserName   string `form:"user_name" json:"user_name"`
-	QuestionId int    `form:"question_id" json:"question_id"`
-	Answer     string `form:"answer" json:"answer"`
-}
-
-type PasswordParams struct {
-	UserName string `form:"user_name" json:"user_name"`
-	Password string `form:"password" json:"password"`
-}
-
-func UpdateUserEntrypted(c *gin.Context) *resp.JSONOutput {
-	params := &EntryptedParams{}
-	err := c.Bind(&params)
-	if err != nil {
-		logs.Error("[UpdateEntrypted] request-params error: params=%+v, err=%v", params, err)
-		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
-	}
-	entrypted := fmt.Sprintf("%d %s", params.QuestionId, params.Answer)
-	err = updateEntrypted(params.UserName, entrypted)
 	if err != nil {
-		return resp.SampleJSON(c, resp.RespCodeParamsError, "更新失败")
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "发送失败")
 	}
 	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
 }
 
-func UpdateUserPassword(c *gin.Context) *resp.JSONOutput {
-	params := &PasswordParams{}
-	err := c.Bind(&params)
+// sendCodeMail 按请求的Accept-Language渲染验证码邮件后异步发送
+func sendCodeMail(c *gin.Context, email string, code string) error {
+	data := &mail.VerifyCodeData{Code: code, Minutes: int(vcode.TTL().Minutes())}
+	msg, err := mail.Render(mail.TemplateVerifyCode, mail.ParseLang(c.GetHeader("Accept-Language")), data, email)
 	if err != nil {
-		logs.Error("[UpdatePassword] request-params error: params=%+v, err=%v", params, err)
-		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
-	}
-	password := params.Password
-	if updateEntrypted(params.UserName, password) != nil {
-		return resp.SampleJSON(c, resp.RespCodeParamsError, "更新失败")
+		return err
 	}
-	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+	return mail.SendAsync(msg)
 }
 
-func CheckUserEntrypted(c *gin.Context) *resp.JSONOutput {
-	params := &EntryptedParams{}
-	err := c.Bind(&params)
-	if err != nil {
-		logs.Error("[CheckEntrypted] request-params error: params=%+v, err=%v", params, err)
-		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+// verifyErrMessage 验证码校验失败的提示
+func verifyErrMessage(err error) string {
+	switch err {
+	case vcode.ErrNotFound:
+		return "验证码不存在或已过期"
+	case vcode.ErrTooManyAttempts:
+		return "验证码错误次数过多, 请重新获取"
+	case vcode.ErrMismatch:
+		return "验证码错误"
 	}
-
-	user_name := params.UserName
-	var (
-		userInfo *dal.EdgexUser
-		dbErr    error
-	)
-	userInfo, dbErr = dal.GetEdgexUserByName(user_name)
-	if dbErr != nil {
-		logs.Error("[CheckEntrypted] get userInfo failed: params=%+v, err=%v", params, err)
-		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
-	}
-	if userInfo == nil {
-		logs.Error("[CheckEntrypted] user is Not Exsit: params=%+v, userInfo=%+v", params, userInfo)
-		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
-	}
-	if userInfo.Entrypted == "" {
-		logs.Error("[CheckEntrypted] entrypted Not Exist: params=%+v, userInfo=%+v", params, userInfo)
-		return resp.SampleJSON(c, resp.RespDatabaseError, "密保不存在")
-	}
-	entrypted := fmt.Sprintf("%d %s", params.QuestionId, params.Answer)
-	if userInfo.Entrypted != entrypted {
-		logs.Error("[CheckEntrypted] entrypted error: params=%+v, userInfo=%+v", params, userInfo)
-		return resp.SampleJSON(c, resp.RespDatabaseError, "密保错误")
-	}
-
-	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+	return "验证失败"
 }
@@ -0,0 +1,307 @@
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/constdef"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/model"
+	"github.com/tdycwym/edgex_admin/resp"
+	"github.com/tdycwym/edgex_admin/webhook"
+)
+
+// WebhookParams ...
+type WebhookParams struct {
+	UserID      int64
+	ID          int64  `form:"id" json:"id"`
+	URL         string `form:"url" json:"url"`
+	Secret      string `form:"secret" json:"secret"`
+	Events      string `form:"events" json:"events"` // 逗号分隔, 为空或*表示全部
+	Status      int32  `form:"status" json:"status"`
+	Description string `form:"description" json:"description"`
+}
+
+type webhookHandler struct {
+	Ctx          *gin.Context
+	Params       WebhookParams
+	Events       []string
+	Subscription *dal.WebhookSubscription
+}
+
+func buildWebhookHandler(c *gin.Context) *webhookHandler {
+	return &webhookHandler{
+		Ctx: c,
+	}
+}
+
+// CheckParams needExist为true时要求id对应的订阅存在
+func (h *webhookHandler) CheckParams(needExist bool) (err error) {
+
+	err = h.Ctx.Bind(&h.Params)
+	if err != nil {
+		logs.Error("[webhookHandler-checkParams] params-err: err=%v", err)
+		return err
+	}
+	h.Params.UserID = session.GetSessionUserID(h.Ctx)
+
+	if h.Params.URL != "" {
+		if err = checkURL(h.Params.URL); err != nil {
+			return err
+		}
+	}
+
+	var ok bool
+	h.Events, ok = webhook.ParseEvents(h.Params.Events)
+	if !ok {
+		return fmt.Errorf("events is invalid: events=%s", h.Params.Events)
+	}
+
+	if h.Params.Status != 0 && h.Params.Status != dal.WebhookEnabled && h.Params.Status != dal.WebhookDisabled {
+		return fmt.Errorf("status is invalid: status=%v", h.Params.Status)
+	}
+
+	if needExist {
+		h.Subscription, err = dal.GetWebhookSubscriptionByID(h.Params.ID)
+		if err != nil {
+			return err
+		}
+		if h.Subscription == nil {
+			return fmt.Errorf("webhook not found: id=%v", h.Params.ID)
+		}
+	}
+	return nil
+}
+
+func checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url scheme is invalid: url=%s", rawURL)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("url has no host: url=%s", rawURL)
+	}
+	return nil
+}
+
+func buildWebhookInfo(item *dal.WebhookSubscription) *model.WebhookInfo {
+	return &model.WebhookInfo{
+		ID:          item.ID,
+		UserID:      item.UserID,
+		URL:         item.URL,
+		Events:      strings.Split(item.Events, ","),
+		Status:      item.Status,
+		Description: item.Description,
+		CreatedTime: item.CreatedTime.Format(constdef.TimeFormat),
+	}
+}
+
+// CreateWebhook 创建订阅, secret仅在此时返回一次
+func CreateWebhook(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildWebhookHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams(false)
+	if err != nil {
+		logs.Warn("[CreateWebhook] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+	if h.Params.URL == "" {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "url is required")
+	}
+
+	secret := h.Params.Secret
+	if secret == "" {
+		secret, err = webhook.GenerateSecret()
+		if err != nil {
+			logs.Error("[CreateWebhook] generate secret failed: err=%v", err)
+			return resp.SampleJSON(c, resp.RespCodeServerException, nil)
+		}
+	}
+	status := h.Params.Status
+	if status == 0 {
+		status = dal.WebhookEnabled
+	}
+
+	// Step2. create
+	item := &dal.WebhookSubscription{
+		UserID:       h.Params.UserID,
+		URL:          h.Params.URL,
+		Secret:       secret,
+		Events:       strings.Join(h.Events, ","),
+		Status:       status,
+		Description:  h.Params.Description,
+		CreatedTime:  time.Now(),
+		ModifiedTime: time.Now(),
+	}
+	err = dal.AddWebhookSubscription(caller.EdgexDB, item)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+
+	info := buildWebhookInfo(item)
+	info.Secret = secret
+	return resp.SampleJSON(c, resp.RespCodeSuccess, info)
+}
+
+// GetWebhookList ...
+func GetWebhookList(c *gin.Context) (out *resp.JSONOutput) {
+
+	itemList, err := dal.GetWebhookSubscriptionList(0)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+
+	infoList := make([]*model.WebhookInfo, 0, len(itemList))
+	for _, item := range itemList {
+		infoList = append(infoList, buildWebhookInfo(item))
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, infoList)
+}
+
+// UpdateWebhook 只更新传入的字段; events为空时不修改
+func UpdateWebhook(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildWebhookHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams(true)
+	if err != nil {
+		logs.Warn("[UpdateWebhook] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+
+	fieldsMap := map[string]interface{}{
+		"modified_time": time.Now(),
+	}
+	if h.Params.URL != "" {
+		fieldsMap["url"] = h.Params.URL
+	}
+	if h.Params.Secret != "" {
+		fieldsMap["secret"] = h.Params.Secret
+	}
+	if strings.TrimSpace(h.Params.Events) != "" {
+		fieldsMap["events"] = strings.Join(h.Events, ",")
+	}
+	if h.Params.Status != 0 {
+		fieldsMap["status"] = h.Params.Status
+	}
+	if h.Params.Description != "" {
+		fieldsMap["description"] = h.Params.Description
+	}
+
+	// Step2. update
+	err = dal.UpdateWebhookSubscription(caller.EdgexDB, h.Params.ID, fieldsMap)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// DeleteWebhook 未完成的投递会在下次尝试时标记为失败
+func DeleteWebhook(c *gin.Context) (out *resp.JSONOutput) {
+
+	h := buildWebhookHandler(c)
+
+	// Step1. checkParams
+	err := h.CheckParams(true)
+	if err != nil {
+		logs.Warn("[DeleteWebhook] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, err.Error())
+	}
+
+	// Step2. delete
+	err = dal.DeleteWebhookSubscription(caller.EdgexDB, h.Params.ID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
+
+// WebhookDeliveryParams ...
+type WebhookDeliveryParams struct {
+	ID             int64  `form:"id" json:"id"`
+	SubscriptionID int64  `form:"subscription_id" json:"subscription_id"`
+	Status         *int32 `form:"status" json:"status"` // 不传时不过滤
+	Offset         int    `form:"offset" json:"offset"`
+	Count          int    `form:"count" json:"count"`
+}
+
+// GetWebhookDeliveryList 投递记录, 按id倒序
+func GetWebhookDeliveryList(c *gin.Context) (out *resp.JSONOutput) {
+
+	params := WebhookDeliveryParams{}
+	err := c.Bind(&params)
+	if err != nil {
+		logs.Warn("[GetWebhookDeliveryList] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+	if params.Count <= 0 || params.Count > 100 {
+		params.Count = 20
+	}
+	status := int32(-1)
+	if params.Status != nil {
+		status = *params.Status
+	}
+
+	itemList, err := dal.GetWebhookDeliveryList(params.SubscriptionID, status, params.Offset, params.Count)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+
+	infoList := make([]*model.WebhookDeliveryInfo, 0, len(itemList))
+	for _, item := range itemList {
+		infoList = append(infoList, &model.WebhookDeliveryInfo{
+			ID:              item.ID,
+			SubscriptionID:  item.SubscriptionID,
+			EventID:         item.EventID,
+			EventType:       item.EventType,
+			Payload:         item.Payload,
+			Status:          item.Status,
+			Attempts:        item.Attempts,
+			NextAttemptTime: item.NextAttemptTime.Format(constdef.TimeFormat),
+			LastStatusCode:  item.LastStatusCode,
+			LastError:       item.LastError,
+			LastResponse:    item.LastResponse,
+			LastLatencyMs:   item.LastLatencyMs,
+			CreatedTime:     item.CreatedTime.Format(constdef.TimeFormat),
+			ModifiedTime:    item.ModifiedTime.Format(constdef.TimeFormat),
+		})
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, infoList)
+}
+
+// RedeliverWebhook 手动重投, 重置重试次数
+func RedeliverWebhook(c *gin.Context) (out *resp.JSONOutput) {
+
+	params := WebhookDeliveryParams{}
+	err := c.Bind(&params)
+	if err != nil {
+		logs.Warn("[RedeliverWebhook] params-err: err=%v", err)
+		return resp.SampleJSON(c, resp.RespCodeParamsError, nil)
+	}
+
+	item, err := dal.GetWebhookDeliveryByID(params.ID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	if item == nil {
+		return resp.SampleJSON(c, resp.RespCodeParamsError, "delivery not found")
+	}
+
+	err = webhook.Redeliver(item.ID)
+	if err != nil {
+		return resp.SampleJSON(c, resp.RespDatabaseError, nil)
+	}
+	return resp.SampleJSON(c, resp.RespCodeSuccess, nil)
+}
@@ -0,0 +1,69 @@
+package mail
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+const (
+	defaultQueueSize = 1000
+	defaultWorkers   = 2
+)
+
+// ErrQueueFull 异步发送队列已满
+var ErrQueueFull = errors.New("mail queue is full")
+
+var (
+	queue     chan *Message
+	startOnce sync.Once
+)
+
+// Start 启动异步发送协程, [Mail] Driver配置错误时返回错误
+func Start() (err error) {
+	if _, err = getMailer(); err != nil {
+		return err
+	}
+	startOnce.Do(func() {
+		size, workers := getConf().QueueSize, getConf().Workers
+		if size <= 0 {
+			size = defaultQueueSize
+		}
+		if workers <= 0 {
+			workers = defaultWorkers
+		}
+		queue = make(chan *Message, size)
+		for i := 0; i < workers; i++ {
+			go work()
+		}
+	})
+	return nil
+}
+
+// SendAsync 放入队列后立即返回, 发送失败只记录日志; 未调用Start时(如子命令)同步发送
+func SendAsync(msg *Message) error {
+	if len(msg.To) == 0 {
+		return errNoRecipient
+	}
+	if queue == nil {
+		return Send(msg)
+	}
+	select {
+	case queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func work() {
+	for msg := range queue {
+		sendSafe(msg)
+	}
+}
+
+func sendSafe(msg *Message) {
+	defer utils.RecoverPanic()
+	_ = Send(msg)
+}
@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+// FileMailer 不实际发送, 将邮件写入目录或日志, 用于开发和测试; 验证码等内容会以明文保存, 不要在生产环境使用
+type FileMailer struct {
+	dir string
+	seq uint64
+}
+
+// NewFileMailer dir为空时只打印日志
+func NewFileMailer(dir string) *FileMailer {
+	return &FileMailer{dir: dir}
+}
+
+// Send ...
+func (m *FileMailer) Send(msg *Message) error {
+	content := m.format(msg)
+	if m.dir == "" {
+		logs.Info("[FileMailer] mail:\n%s", content)
+		return nil
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s_%d.eml", time.Now().Format("20060102150405.000000"), atomic.AddUint64(&m.seq, 1))
+	return ioutil.WriteFile(filepath.Join(m.dir, name), []byte(content), 0644)
+}
+
+func (m *FileMailer) format(msg *Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n\r\n", time.Now().Format(time.RFC1123Z))
+	if msg.Text != "" {
+		b.WriteString(msg.Text)
+		b.WriteString("\r\n\r\n")
+	}
+	if msg.HTML != "" {
+		b.WriteString(msg.HTML)
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
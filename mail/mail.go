@@ -0,0 +1,136 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+// 发送方式, 见[Mail] Driver
+const (
+	DriverSMTP = "smtp" // 通过SMTP发送
+	DriverFile = "file" // 写入Dir目录, 用于开发和测试
+	DriverLog  = "log"  // 只打印日志, 用于开发
+)
+
+const (
+	defaultMaxRetries    = 3
+	defaultRetryInterval = 5 * time.Second
+)
+
+// Message ...
+type Message struct {
+	To      []string
+	Subject string
+	HTML    string
+	Text    string // 纯文本正文, 与HTML同时存在时作为multipart/alternative发送
+}
+
+// Mailer 邮件发送方式
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+var errNoRecipient = errors.New("mail has no recipient")
+
+// ErrUnknownDriver [Mail] Driver不是smtp/file/log
+var ErrUnknownDriver = errors.New("unknown mail driver")
+
+var (
+	mailerMu sync.RWMutex
+	mailer   Mailer
+)
+
+// New 按配置创建Mailer, Driver大小写不敏感; 未知的Driver返回ErrUnknownDriver, 避免邮件被静默丢弃
+func New(conf *config.MailConfig) (Mailer, error) {
+	switch strings.ToLower(strings.TrimSpace(conf.Driver)) {
+	case DriverSMTP:
+		return NewSMTPMailer(conf), nil
+	case DriverFile:
+		return NewFileMailer(conf.Dir), nil
+	case DriverLog:
+		return NewFileMailer(""), nil
+	}
+	return nil, fmt.Errorf("%w: driver=%q", ErrUnknownDriver, conf.Driver)
+}
+
+// SetMailer 替换默认的Mailer, 用于测试
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	mailer = m
+}
+
+func getMailer() (Mailer, error) {
+	mailerMu.RLock()
+	m := mailer
+	mailerMu.RUnlock()
+	if m != nil {
+		return m, nil
+	}
+
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	if mailer == nil {
+		var err error
+		if mailer, err = New(getConf()); err != nil {
+			return nil, err
+		}
+	}
+	return mailer, nil
+}
+
+// Send 同步发送, 失败时按RetryInterval指数退避重试MaxRetries次
+func Send(msg *Message) (err error) {
+	if len(msg.To) == 0 {
+		return errNoRecipient
+	}
+	m, err := getMailer()
+	if err != nil {
+		logs.Error("[mail-Send] create mailer failed: mailTo=%v, subject=%s, err=%v", msg.To, msg.Subject, err)
+		return err
+	}
+	retries, interval := maxRetries(), retryInterval()
+	for attempt := 0; ; attempt++ {
+		err = m.Send(msg)
+		if err == nil || attempt >= retries {
+			break
+		}
+		logs.Warn("[mail-Send] send mail failed, retry later: mailTo=%v, subject=%s, attempt=%d, err=%v", msg.To, msg.Subject, attempt+1, err)
+		time.Sleep(interval << uint(attempt))
+	}
+	if err != nil {
+		logs.Error("[mail-Send] send mail failed: mailTo=%v, subject=%s, err=%v", msg.To, msg.Subject, err)
+	}
+	return err
+}
+
+// getConf 未加载配置时(如测试)使用默认值
+func getConf() *config.MailConfig {
+	if config.MailConf == nil {
+		return &config.MailConfig{}
+	}
+	return config.MailConf
+}
+
+func maxRetries() int {
+	if getConf().MaxRetries < 0 {
+		return 0
+	}
+	if getConf().MaxRetries == 0 {
+		return defaultMaxRetries
+	}
+	return getConf().MaxRetries
+}
+
+func retryInterval() time.Duration {
+	if getConf().RetryInterval <= 0 {
+		return defaultRetryInterval
+	}
+	return time.Duration(getConf().RetryInterval) * time.Second
+}
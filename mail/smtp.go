@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"github.com/tdycwym/edgex_admin/config"
+	"gopkg.in/gomail.v2"
+)
+
+// SMTPMailer 端口为465时使用SSL, 其他端口在服务器支持时使用STARTTLS
+type SMTPMailer struct {
+	dialer   *gomail.Dialer
+	from     string
+	fromName string
+}
+
+// NewSMTPMailer From为空时使用Username
+func NewSMTPMailer(conf *config.MailConfig) *SMTPMailer {
+	from := conf.From
+	if from == "" {
+		from = conf.Username
+	}
+	return &SMTPMailer{
+		dialer:   gomail.NewDialer(conf.Host, conf.Port, conf.Username, conf.Password),
+		from:     from,
+		fromName: conf.FromName,
+	}
+}
+
+// Send ...
+func (m *SMTPMailer) Send(msg *Message) error {
+	gm := gomail.NewMessage()
+	// 别名需经FormatAddress编码, 否则中文别名会被部分邮箱拒收
+	gm.SetHeader("From", gm.FormatAddress(m.from, m.fromName))
+	gm.SetHeader("To", msg.To...)
+	gm.SetHeader("Subject", msg.Subject)
+	switch {
+	case msg.Text != "" && msg.HTML != "":
+		gm.SetBody("text/plain", msg.Text)
+		gm.AddAlternative("text/html", msg.HTML)
+	case msg.Text != "":
+		gm.SetBody("text/plain", msg.Text)
+	default:
+		gm.SetBody("text/html", msg.HTML)
+	}
+	return m.dialer.DialAndSend(gm)
+}
@@ -0,0 +1,162 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// 支持的语言
+const (
+	LangZh = "zh"
+	LangEn = "en"
+)
+
+// 模板名称
+const (
+	TemplateVerifyCode    = "verify_code"
+	TemplatePasswordReset = "password_reset"
+)
+
+// VerifyCodeData TemplateVerifyCode的参数
+type VerifyCodeData struct {
+	Code    string
+	Minutes int
+}
+
+// PasswordResetData TemplatePasswordReset的参数, Link为空时只展示Token
+type PasswordResetData struct {
+	Username string
+	Link     string
+	Token    string
+	Minutes  int
+}
+
+type templateSource struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+type mailTemplate struct {
+	subject *texttemplate.Template
+	html    *htmltemplate.Template
+	text    *texttemplate.Template
+}
+
+var templateSources = map[string]map[string]*templateSource{
+	TemplateVerifyCode: {
+		LangZh: {
+			Subject: `NJU-IOT-EDGEX 验证码`,
+			HTML: `<p>你的验证码是：<b style="font-size: 18px;">{{.Code}}</b></p>
+<p>{{.Minutes}}分钟内有效，只能使用一次。如果不是你本人的操作，请忽略本邮件。</p>`,
+			Text: `你的验证码是：{{.Code}}
+{{.Minutes}}分钟内有效，只能使用一次。如果不是你本人的操作，请忽略本邮件。`,
+		},
+		LangEn: {
+			Subject: `NJU-IOT-EDGEX verification code`,
+			HTML: `<p>Your verification code is: <b style="font-size: 18px;">{{.Code}}</b></p>
+<p>It expires in {{.Minutes}} minutes and can only be used once. If you did not request it, please ignore this email.</p>`,
+			Text: `Your verification code is: {{.Code}}
+It expires in {{.Minutes}} minutes and can only be used once. If you did not request it, please ignore this email.`,
+		},
+	},
+	TemplatePasswordReset: {
+		LangZh: {
+			Subject: `NJU-IOT-EDGEX 重置密码`,
+			HTML: `<p>{{.Username}}，你好：</p>
+<p>我们收到了重置密码的请求，{{.Minutes}}分钟内有效，只能使用一次。</p>
+{{if .Link}}<p><a href="{{.Link}}">点击重置密码</a></p>{{else}}<p>重置凭证：{{.Token}}</p>{{end}}
+<p>如果不是你本人的操作，请忽略本邮件。</p>`,
+			Text: `{{.Username}}，你好：
+我们收到了重置密码的请求，{{.Minutes}}分钟内有效，只能使用一次。
+{{if .Link}}重置链接：{{.Link}}{{else}}重置凭证：{{.Token}}{{end}}
+如果不是你本人的操作，请忽略本邮件。`,
+		},
+		LangEn: {
+			Subject: `NJU-IOT-EDGEX password reset`,
+			HTML: `<p>Hi {{.Username}},</p>
+<p>We received a request to reset your password. It expires in {{.Minutes}} minutes and can only be used once.</p>
+{{if .Link}}<p><a href="{{.Link}}">Reset password</a></p>{{else}}<p>Reset token: {{.Token}}</p>{{end}}
+<p>If you did not request it, please ignore this email.</p>`,
+			Text: `Hi {{.Username}},
+We received a request to reset your password. It expires in {{.Minutes}} minutes and can only be used once.
+{{if .Link}}Reset link: {{.Link}}{{else}}Reset token: {{.Token}}{{end}}
+If you did not request it, please ignore this email.`,
+		},
+	},
+}
+
+var templates = compileTemplates()
+
+func compileTemplates() map[string]map[string]*mailTemplate {
+	compiled := make(map[string]map[string]*mailTemplate)
+	for name, langMap := range templateSources {
+		compiled[name] = make(map[string]*mailTemplate)
+		for lang, src := range langMap {
+			id := name + "." + lang
+			compiled[name][lang] = &mailTemplate{
+				subject: texttemplate.Must(texttemplate.New(id + ".subject").Parse(src.Subject)),
+				html:    htmltemplate.Must(htmltemplate.New(id + ".html").Parse(src.HTML)),
+				text:    texttemplate.Must(texttemplate.New(id + ".text").Parse(src.Text)),
+			}
+		}
+	}
+	return compiled
+}
+
+// Render 按模板生成邮件, 不支持的语言使用[Mail] Lang
+func Render(name string, lang string, data interface{}, mailTo ...string) (*Message, error) {
+	langMap, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("mail template not found: name=%v", name)
+	}
+	tmpl, ok := langMap[lang]
+	if !ok {
+		tmpl, ok = langMap[DefaultLang()]
+	}
+	if !ok {
+		tmpl = langMap[LangZh]
+	}
+
+	var subject, html, text bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:      mailTo,
+		Subject: subject.String(),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+// DefaultLang ...
+func DefaultLang() string {
+	if getConf().Lang == LangEn {
+		return LangEn
+	}
+	return LangZh
+}
+
+// ParseLang 取Accept-Language中第一个支持的语言, 都不支持时返回DefaultLang
+func ParseLang(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case tag == LangZh || strings.HasPrefix(tag, LangZh+"-"):
+			return LangZh
+		case tag == LangEn || strings.HasPrefix(tag, LangEn+"-"):
+			return LangEn
+		}
+	}
+	return DefaultLang()
+}
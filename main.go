@@ -2,16 +2,29 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"time"
 
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/alert"
 	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/command"
 	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/digest"
+	"github.com/tdycwym/edgex_admin/event"
 	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/mail"
+	"github.com/tdycwym/edgex_admin/metrics"
 
 	"github.com/tdycwym/edgex_admin/middleware/cors"
 	"github.com/tdycwym/edgex_admin/middleware/session"
+	"github.com/tdycwym/edgex_admin/resp"
+	"github.com/tdycwym/edgex_admin/sms"
+	"github.com/tdycwym/edgex_admin/stream"
+	"github.com/tdycwym/edgex_admin/uptime"
+	"github.com/tdycwym/edgex_admin/webhook"
 	"go.uber.org/zap"
 )
 
@@ -25,10 +38,46 @@ func main() {
 	logs.InitLogs()
 	caller.InitClient()
 
+	// 子命令模式: 执行完即退出, 不启动http服务
+	if flag.NArg() > 0 {
+		if err := command.Run(flag.Arg(0), flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if sqlDB, err := caller.EdgexDB.DB(); err == nil {
+		metrics.RegisterDB(sqlDB, "edgex")
+	}
+
+	if err := mail.Start(); err != nil {
+		logs.Error("[main] start mail failed: err=%v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := sms.Init(); err != nil {
+		logs.Error("[main] init sms failed: err=%v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// 事件订阅者需在relay启动前注册
+	webhook.Init()
+	alert.Init()
+	webhook.Start()
+	stream.Init()
+	stream.Start()
+	event.StartRelay()
+	uptime.Start()
+	digest.Start()
+
 	gin.SetMode(config.Server.RunMode)
 
 	r := gin.New()
+	// 访问日志会记录URL参数, 记录前隐藏token等参数
+	r.Use(resp.HideSensitiveQuery())
 	r.Use(ginzap.Ginzap(zap.L(), time.RFC3339, true))
+	r.Use(resp.RestoreSensitiveQuery())
 	r.Use(ginzap.RecoveryWithZap(zap.L(), true))
 
 	// 允许跨域访问
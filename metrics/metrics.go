@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tdycwym/edgex_admin/config"
+)
+
+const namespace = "edgex_admin"
+
+var (
+	requestTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled by JSONOutPutWrapper, by route and resp.ErrorCode.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	sessionErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "session_store_errors_total",
+		Help:      "Errors returned by the redis session store, by operation.",
+	}, []string{"op"})
+
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Background job runs, by job and result.",
+	}, []string{"job", "result"})
+
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Background job run duration.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"job"})
+
+	jobLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "job_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful run of a background job.",
+	}, []string{"job"})
+
+	probeTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "probe_total",
+		Help:      "Gateway probes, by result.",
+	}, []string{"result"})
+
+	probeLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "probe_latency_seconds",
+		Help:      "Ping latency of reachable gateways.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	})
+
+	eventTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_dispatch_total",
+		Help:      "Outbox events dispatched to subscribers, by result.",
+	}, []string{"result"})
+
+	webhookTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_delivery_attempts_total",
+		Help:      "Webhook delivery attempts, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(requestTotal, requestDuration, sessionErrors,
+		jobRuns, jobDuration, jobLastSuccess, probeTotal, probeLatency, eventTotal, webhookTotal)
+}
+
+// Handler /metrics, 需携带[Metrics] Token作为Bearer token, 未配置token时拒绝全部请求
+func Handler() http.Handler {
+	next := promhttp.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"Unauthorized"}`))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func authorized(r *http.Request) bool {
+	if config.MetricsConf == nil || config.MetricsConf.Token == "" {
+		return false
+	}
+	token := config.MetricsConf.Token
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) == 1
+}
+
+// RegisterDB 导出连接池状态, e.g. edgex_admin连接池的open/in_use/wait_count
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveRequest route为gin的路由模板, 未匹配路由时为空
+func ObserveRequest(route string, method string, code int32, cost time.Duration) {
+	requestTotal.WithLabelValues(route, method, strconv.Itoa(int(code))).Inc()
+	requestDuration.WithLabelValues(route, method).Observe(cost.Seconds())
+}
+
+// SessionError op: init/save
+func SessionError(op string) {
+	sessionErrors.WithLabelValues(op).Inc()
+}
+
+// ObserveJob 记录一次后台任务的执行结果
+func ObserveJob(job string, start time.Time, err error) {
+	jobDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+	if err != nil {
+		jobRuns.WithLabelValues(job, "error").Inc()
+		return
+	}
+	jobRuns.WithLabelValues(job, "success").Inc()
+	jobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+}
+
+// ObserveProbe ...
+func ObserveProbe(up bool, latencyMs int64) {
+	if !up {
+		probeTotal.WithLabelValues("down").Inc()
+		return
+	}
+	probeTotal.WithLabelValues("up").Inc()
+	probeLatency.Observe(float64(latencyMs) / 1000)
+}
+
+// ObserveEvent result: dispatched/retry/dropped
+func ObserveEvent(result string) {
+	eventTotal.WithLabelValues(result).Inc()
+}
+
+// ObserveWebhook result: success/retry/failed
+func ObserveWebhook(result string) {
+	webhookTotal.WithLabelValues(result).Inc()
+}
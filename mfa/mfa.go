@@ -0,0 +1,393 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/credential"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"gorm.io/gorm"
+)
+
+const (
+	attemptKeyFmt = "edgex_admin:mfa_attempt:%d"
+	stepKeyFmt    = "edgex_admin:mfa_step:%d:%d"
+	secretScope   = "edgex_user_mfa:%d"
+
+	defaultIssuer        = "NJU-IOT-EDGEX"
+	defaultMaxAttempts   = 5
+	defaultLockTime      = 15 * time.Minute
+	defaultRecoveryCodes = 10
+	recoveryCodeLen      = 10
+	recoveryAlphabet     = "abcdefghjkmnpqrstuvwxyz23456789" // 去掉易混淆的i、l、o、0、1
+	rotateBatchSize      = 100
+)
+
+var (
+	// ErrNotEnabled 未开启两步验证
+	ErrNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrNotEnrolled 未生成密钥或已确认
+	ErrNotEnrolled = errors.New("two-factor authentication is not enrolled")
+	// ErrAlreadyEnabled 已开启, 需先关闭才能重新绑定
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrInvalidCode 验证码或恢复码错误
+	ErrInvalidCode = errors.New("two-factor code is invalid")
+	// ErrTooManyAttempts 错误次数过多, 锁定期内拒绝校验
+	ErrTooManyAttempts = errors.New("too many two-factor attempts")
+	// ErrRequired 所属角色要求开启两步验证, 不能关闭
+	ErrRequired = errors.New("two-factor authentication is required for the role")
+)
+
+// Enroll 生成新的密钥, 确认前不生效; 已开启时返回ErrAlreadyEnabled
+func Enroll(user *dal.EdgexUser) (secret string, uri string, err error) {
+	item, err := dal.GetUserMFA(user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if item != nil && item.Enabled {
+		return "", "", ErrAlreadyEnabled
+	}
+	secret, err = GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	keyID, ciphertext, err := credential.SealSecret(fmt.Sprintf(secretScope, user.ID), []byte(secret))
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	err = dal.SaveUserMFA(caller.EdgexDB, &dal.EdgexUserMFA{
+		UserID:        user.ID,
+		KeyID:         keyID,
+		Secret:        ciphertext,
+		Enabled:       false,
+		ConfirmedTime: now,
+		CreatedTime:   now,
+		ModifiedTime:  now,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return secret, ProvisioningURI(issuer(), user.Username, secret), nil
+}
+
+// Confirm 校验认证应用生成的验证码后启用, 返回新的恢复码明文(只展示一次)
+func Confirm(userID int64, code string) ([]string, error) {
+	item, err := dal.GetUserMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || item.Enabled {
+		return nil, ErrNotEnrolled
+	}
+	if err = checkTOTP(item, code); err != nil {
+		return nil, err
+	}
+
+	codes, codeList, err := newRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	err = caller.EdgexDB.Transaction(func(db *gorm.DB) error {
+		updated, err := dal.EnableUserMFA(db, userID, item.Secret)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			// 期间重新生成了密钥
+			return ErrNotEnrolled
+		}
+		return dal.ReplaceRecoveryCodes(db, userID, codeList)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify 登录第二步, code为认证应用的6位验证码或恢复码, 恢复码使用后失效
+func Verify(userID int64, code string) error {
+	item, err := getEnabled(userID)
+	if err != nil {
+		return err
+	}
+	code = normalizeCode(code)
+	if isTOTPCode(code) {
+		return checkTOTP(item, code)
+	}
+	return useRecoveryCode(userID, code)
+}
+
+// RegenerateRecoveryCodes 需要认证应用的验证码, 原恢复码全部失效
+func RegenerateRecoveryCodes(userID int64, code string) ([]string, error) {
+	item, err := getEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkTOTP(item, normalizeCode(code)); err != nil {
+		return nil, err
+	}
+	codes, codeList, err := newRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = dal.ReplaceRecoveryCodes(caller.EdgexDB, userID, codeList); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable 所属角色要求两步验证时返回ErrRequired
+func Disable(user *dal.EdgexUser, code string) error {
+	required, err := Required(user.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrRequired
+	}
+	if err = Verify(user.ID, code); err != nil {
+		return err
+	}
+	return Reset(user.ID)
+}
+
+// Reset 删除密钥和恢复码, 用于关闭或管理员为丢失设备的用户重置
+func Reset(userID int64) error {
+	return caller.EdgexDB.Transaction(func(db *gorm.DB) error {
+		return dal.DeleteUserMFA(db, userID)
+	})
+}
+
+// Enabled ...
+func Enabled(userID int64) (bool, error) {
+	item, err := dal.GetUserMFA(userID)
+	if err != nil {
+		return false, err
+	}
+	return item != nil && item.Enabled, nil
+}
+
+// Required 角色是否必须开启两步验证
+func Required(role int32) (bool, error) {
+	policyList, err := dal.GetMFAPolicyList()
+	if err != nil {
+		return false, err
+	}
+	for _, policy := range policyList {
+		if policy.Role == role {
+			return policy.Required, nil
+		}
+	}
+	return false, nil
+}
+
+// Rotate 将非当前主密钥加密的TOTP密钥重新加密, 由rotate-credential-key一并执行
+func Rotate() (rotated int, err error) {
+	currentID, err := credential.CurrentKeyID()
+	if err != nil {
+		return 0, err
+	}
+
+	var lastID int64
+	failed := 0
+	for {
+		itemList, err := dal.ScanUserMFANotKey(currentID, lastID, rotateBatchSize)
+		if err != nil {
+			return rotated, err
+		}
+		if len(itemList) == 0 {
+			break
+		}
+		for _, item := range itemList {
+			ok, err := rotateOne(item)
+			if err != nil {
+				failed++
+				logs.Error("[mfa-Rotate] rotate failed: user_id=%d, key_id=%s, err=%v", item.UserID, item.KeyID, err)
+				continue
+			}
+			if ok {
+				rotated++
+			}
+		}
+		lastID = itemList[len(itemList)-1].ID
+	}
+	if failed > 0 {
+		return rotated, fmt.Errorf("%d mfa secrets failed to rotate", failed)
+	}
+	return rotated, nil
+}
+
+func rotateOne(item *dal.EdgexUserMFA) (bool, error) {
+	scope := fmt.Sprintf(secretScope, item.UserID)
+	plaintext, err := credential.OpenSecret(scope, item.KeyID, item.Secret)
+	if err != nil {
+		return false, err
+	}
+	keyID, ciphertext, err := credential.SealSecret(scope, plaintext)
+	if err != nil {
+		return false, err
+	}
+	return dal.UpdateUserMFACipher(caller.EdgexDB, item.ID, item.Secret, keyID, ciphertext)
+}
+
+func getEnabled(userID int64) (*dal.EdgexUserMFA, error) {
+	item, err := dal.GetUserMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || !item.Enabled {
+		return nil, ErrNotEnabled
+	}
+	return item, nil
+}
+
+// checkTOTP 错误次数限制, 同一时间步的验证码只能使用一次
+func checkTOTP(item *dal.EdgexUserMFA, code string) error {
+	if err := acquireAttempt(item.UserID); err != nil {
+		return err
+	}
+	secret, err := credential.OpenSecret(fmt.Sprintf(secretScope, item.UserID), item.KeyID, item.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := ValidateCode(string(secret), code, time.Now())
+	if !ok {
+		return ErrInvalidCode
+	}
+	ttl := time.Duration(totpPeriod*(2*totpSkew+1)) * time.Second
+	fresh, err := caller.RedisClient.SetNX(context.Background(), fmt.Sprintf(stepKeyFmt, item.UserID, step), 1, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidCode
+	}
+	clearAttempts(item.UserID)
+	return nil
+}
+
+func useRecoveryCode(userID int64, code string) error {
+	if err := acquireAttempt(userID); err != nil {
+		return err
+	}
+	used, err := dal.UseRecoveryCode(caller.EdgexDB, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	clearAttempts(userID)
+	logs.Info("[mfa-useRecoveryCode] recovery code used: user_id=%v", userID)
+	return nil
+}
+
+func newRecoveryCodes(userID int64) ([]string, []*dal.EdgexRecoveryCode, error) {
+	count := config.MFAConf.RecoveryCodes
+	if count <= 0 {
+		count = defaultRecoveryCodes
+	}
+	now := time.Now()
+	codes := make([]string, 0, count)
+	codeList := make([]*dal.EdgexRecoveryCode, 0, count)
+	for len(codes) < count {
+		var b strings.Builder
+		for i := 0; i < recoveryCodeLen; i++ {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryAlphabet))))
+			if err != nil {
+				return nil, nil, err
+			}
+			if i == recoveryCodeLen/2 {
+				b.WriteByte('-')
+			}
+			b.WriteByte(recoveryAlphabet[n.Int64()])
+		}
+		codes = append(codes, b.String())
+		codeList = append(codeList, &dal.EdgexRecoveryCode{
+			UserID:      userID,
+			CodeHash:    hashRecoveryCode(normalizeCode(b.String())),
+			UsedTime:    now,
+			CreatedTime: now,
+		})
+	}
+	return codes, codeList, nil
+}
+
+// hashRecoveryCode 恢复码为高熵随机串, 使用sha256即可
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	_, err := strconv.Atoi(code)
+	return err == nil
+}
+
+// attemptScript 计数加一并返回当前值, 第一次时设置锁定期
+var attemptScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// acquireAttempt 校验前先原子地计数, 并发的猜测请求也无法绕过上限; 成功后由clearAttempts清除, redis不可用时拒绝校验
+func acquireAttempt(userID int64) error {
+	key := fmt.Sprintf(attemptKeyFmt, userID)
+	count, err := attemptScript.Run(context.Background(), caller.RedisClient, []string{key}, int64(lockTime().Seconds())).Int()
+	if err != nil {
+		logs.Error("[mfa-acquireAttempt] count attempt failed: user_id=%v, err=%v", userID, err)
+		return err
+	}
+	if count > maxAttempts() {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+func clearAttempts(userID int64) {
+	caller.RedisClient.Del(context.Background(), fmt.Sprintf(attemptKeyFmt, userID))
+}
+
+func issuer() string {
+	if config.MFAConf.Issuer == "" {
+		return defaultIssuer
+	}
+	return config.MFAConf.Issuer
+}
+
+func maxAttempts() int {
+	if config.MFAConf.MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return config.MFAConf.MaxAttempts
+}
+
+func lockTime() time.Duration {
+	if config.MFAConf.LockTime <= 0 {
+		return defaultLockTime
+	}
+	return time.Duration(config.MFAConf.LockTime) * time.Second
+}
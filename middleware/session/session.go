@@ -1,21 +1,42 @@
 package session
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/redis"
 	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis/v8"
 	uuid "github.com/satori/go.uuid"
+	"github.com/tdycwym/edgex_admin/apitoken"
+	"github.com/tdycwym/edgex_admin/caller"
 	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/metrics"
 )
 
 type userInfo struct {
 	UserID   int64
 	UserName string
+	Epoch    int64 // 登录时用户的session版本, 与当前版本不一致时session失效
+	// 密码校验通过但尚未完成两步验证时为预认证session, 只能访问两步验证接口
+	PreAuth       string `json:",omitempty"`
+	PreAuthExpire int64  `json:",omitempty"`
 }
 
+// 预认证session的阶段
+const (
+	PreAuthVerify = "verify" // 已开启两步验证, 需输入验证码
+	PreAuthEnroll = "enroll" // 角色要求两步验证但尚未开启, 需先绑定
+)
+
+const epochKeyFmt = "edgex_admin:session_epoch:%d"
+
 // KEY gin session key
 const KEY = "bmp1LWVkZ2V4"
 
@@ -30,10 +51,22 @@ const CookieName = "session_id"
 
 // EnableRedisSession ...
 func EnableRedisSession() gin.HandlerFunc {
-	store, _ := redis.NewStore(10, "tcp", config.RedisConf.Address, "", []byte(KEY))
+	store, err := redis.NewStore(10, "tcp", config.RedisConf.Address, "", []byte(KEY))
+	if err != nil {
+		metrics.SessionError("init")
+		logs.Error("[EnableRedisSession] create redis store failed: err=%v", err)
+	}
 	return sessions.Sessions("session_token", store)
 }
 
+// saveSession 保存失败时记录日志与监控
+func saveSession(session sessions.Session) {
+	if err := session.Save(); err != nil {
+		metrics.SessionError("save")
+		logs.Error("[saveSession] save session failed: err=%v", err)
+	}
+}
+
 // SessionMiddleware ...
 func SessionMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -45,7 +78,7 @@ func SessionMiddleware() gin.HandlerFunc {
 		sessionValue := session.Get(sessionID)
 		if sessionValue != nil {
 			session.Set(sessionID, sessionValue)
-			_ = session.Save()
+			saveSession(session)
 		}
 		c.SetCookie(CookieName, sessionID, 20*60, "/", "", false, true)
 		c.Set(CookieName, sessionID)
@@ -59,29 +92,99 @@ func AuthSessionMiddle() gin.HandlerFunc {
 		sessionID, _ := c.Cookie(CookieName)
 		session := sessions.Default(c)
 		sessionValue := session.Get(sessionID)
+		if sessionValue != nil && !checkEpoch(sessionValue) {
+			// 重置密码等操作后, 该用户之前的session全部失效
+			session.Delete(sessionID)
+			saveSession(session)
+			sessionValue = nil
+		}
+		if sessionValue != nil && isPreAuth(sessionValue) {
+			// 预认证session保留, 用于继续两步验证
+			sessionValue = nil
+		}
 		if sessionID == "" || sessionValue == nil {
-			c.Writer.WriteHeader(http.StatusUnauthorized)
-			c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-			rawData, _ := json.Marshal(gin.H{"error": "Unauthorized"})
-			_, _ = c.Writer.Write(rawData)
-			c.Abort()
+			abortWithError(c, http.StatusUnauthorized, "Unauthorized")
 		} else {
+			c.Set(CookieName, sessionID)
 			c.Next()
 		}
 	}
 }
 
+// AdminAuthMiddle 需要在AuthSessionMiddle或AuthMiddle之后使用, 仅允许管理员访问; token还需具有admin权限
+func AdminAuthMiddle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopes, ok := c.Get(tokenScopesKey); ok && !apitoken.HasScope(scopes.(string), apitoken.ScopeAdmin) {
+			abortWithError(c, http.StatusForbidden, "Insufficient scope")
+			return
+		}
+		userID := GetSessionUserID(c)
+		user, err := dal.GetEdgexUserByID(userID)
+		if err != nil {
+			logs.Error("[AdminAuthMiddle] get user failed: userID=%v, err=%v", userID, err)
+		}
+		if user == nil || user.Role != dal.RoleAdmin {
+			abortWithError(c, http.StatusForbidden, "Forbidden")
+			return
+		}
+		c.Next()
+	}
+}
+
 // SaveAuthSession 注册和登陆时都需要保存seesion信息
 func SaveAuthSession(c *gin.Context, userID int64, username string) {
 	session := sessions.Default(c)
 	sessionID, _ := c.Get(CookieName)
+	epoch, err := getEpoch(userID)
+	if err != nil {
+		logs.Error("[SaveAuthSession] get session epoch failed: userID=%v, err=%v", userID, err)
+	}
 	userInfo := &userInfo{
 		UserID:   userID,
 		UserName: username,
+		Epoch:    epoch,
+	}
+	userInfoBytes, _ := json.Marshal(userInfo)
+	session.Set(sessionID, string(userInfoBytes))
+	saveSession(session)
+}
+
+// SavePreAuthSession 密码校验通过后保存预认证session, 完成两步验证后再调用SaveAuthSession
+func SavePreAuthSession(c *gin.Context, userID int64, username string, stage string, ttl time.Duration) {
+	session := sessions.Default(c)
+	sessionID, _ := c.Get(CookieName)
+	epoch, err := getEpoch(userID)
+	if err != nil {
+		logs.Error("[SavePreAuthSession] get session epoch failed: userID=%v, err=%v", userID, err)
+	}
+	userInfo := &userInfo{
+		UserID:        userID,
+		UserName:      username,
+		Epoch:         epoch,
+		PreAuth:       stage,
+		PreAuthExpire: time.Now().Add(ttl).Unix(),
 	}
 	userInfoBytes, _ := json.Marshal(userInfo)
 	session.Set(sessionID, string(userInfoBytes))
-	_ = session.Save()
+	saveSession(session)
+}
+
+// GetPreAuthSession 返回未过期的预认证session, 不存在时userID为0
+func GetPreAuthSession(c *gin.Context) (userID int64, username string, stage string) {
+	sessionID, exsit := c.Get(CookieName)
+	if !exsit || sessionID.(string) == "" {
+		return 0, "", ""
+	}
+	sessionValue := sessions.Default(c).Get(sessionID)
+	if sessionValue == nil || !checkEpoch(sessionValue) {
+		return 0, "", ""
+	}
+	userInfo := &userInfo{}
+	err := json.Unmarshal([]byte(sessionValue.(string)), userInfo)
+	if err != nil || userInfo.UserID <= 0 || userInfo.PreAuth == "" || userInfo.PreAuthExpire < time.Now().Unix() {
+		return 0, "", ""
+	}
+	return userInfo.UserID, userInfo.UserName, userInfo.PreAuth
 }
 
 // ClearAuthSession 退出时清除session
@@ -92,11 +195,14 @@ func ClearAuthSession(c *gin.Context) {
 	}
 	session := sessions.Default(c)
 	session.Delete(sessionID)
-	_ = session.Save()
+	saveSession(session)
 }
 
-// GetSessionUserID ...
+// GetSessionUserID token鉴权的请求返回token所属用户
 func GetSessionUserID(c *gin.Context) int64 {
+	if user := tokenUser(c); user != nil {
+		return user.UserID
+	}
 	sessionID, exsit := c.Get(CookieName)
 	if !exsit || sessionID.(string) == "" {
 		return 0
@@ -108,14 +214,30 @@ func GetSessionUserID(c *gin.Context) int64 {
 	}
 	userInfo := &userInfo{}
 	err := json.Unmarshal([]byte(sessionValue.(string)), &userInfo)
-	if err != nil || userInfo == nil || userInfo.UserID <= 0 {
+	if err != nil || userInfo == nil || userInfo.UserID <= 0 || userInfo.PreAuth != "" {
 		return 0
 	}
 	return userInfo.UserID
 }
 
+// GetAuthSessionUserID 用于未经过AuthSessionMiddle的接口, 已失效的session返回0
+func GetAuthSessionUserID(c *gin.Context) int64 {
+	sessionID, exsit := c.Get(CookieName)
+	if !exsit || sessionID.(string) == "" {
+		return 0
+	}
+	sessionValue := sessions.Default(c).Get(sessionID)
+	if sessionValue == nil || !checkEpoch(sessionValue) {
+		return 0
+	}
+	return GetSessionUserID(c)
+}
+
 // GetSessionUsername ...
 func GetSessionUsername(c *gin.Context) string {
+	if user := tokenUser(c); user != nil {
+		return user.UserName
+	}
 	sessionID, exsit := c.Get(CookieName)
 	if !exsit || sessionID.(string) == "" {
 		return ""
@@ -127,8 +249,44 @@ func GetSessionUsername(c *gin.Context) string {
 	}
 	userInfo := &userInfo{}
 	err := json.Unmarshal([]byte(sessionValue.(string)), &userInfo)
-	if err != nil || userInfo == nil || userInfo.UserID <= 0 {
+	if err != nil || userInfo == nil || userInfo.UserID <= 0 || userInfo.PreAuth != "" {
 		return ""
 	}
 	return userInfo.UserName
 }
+
+// InvalidateUserSessions 使该用户已登录的全部session失效
+func InvalidateUserSessions(userID int64) error {
+	return caller.RedisClient.Incr(context.Background(), fmt.Sprintf(epochKeyFmt, userID)).Err()
+}
+
+// getEpoch 从未失效过的用户为0
+func getEpoch(userID int64) (int64, error) {
+	epoch, err := caller.RedisClient.Get(context.Background(), fmt.Sprintf(epochKeyFmt, userID)).Int64()
+	if err == goredis.Nil {
+		return 0, nil
+	}
+	return epoch, err
+}
+
+func isPreAuth(sessionValue interface{}) bool {
+	userInfo := &userInfo{}
+	raw, _ := sessionValue.(string)
+	return json.Unmarshal([]byte(raw), userInfo) == nil && userInfo.PreAuth != ""
+}
+
+// checkEpoch redis不可用时视为失效
+func checkEpoch(sessionValue interface{}) bool {
+	userInfo := &userInfo{}
+	raw, _ := sessionValue.(string)
+	if err := json.Unmarshal([]byte(raw), userInfo); err != nil || userInfo.UserID <= 0 {
+		return true
+	}
+	epoch, err := getEpoch(userInfo.UserID)
+	if err != nil {
+		metrics.SessionError("epoch")
+		logs.Error("[checkEpoch] get session epoch failed: userID=%v, err=%v", userInfo.UserID, err)
+		return false
+	}
+	return userInfo.Epoch == epoch
+}
@@ -0,0 +1,71 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdycwym/edgex_admin/apitoken"
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+// token鉴权时写入gin.Context, GetSessionUserID等优先读取
+const (
+	tokenUserKey   = "api_token_user"
+	tokenScopesKey = "api_token_scopes"
+)
+
+// AuthMiddle 请求带Authorization: Bearer时按API token鉴权并校验权限, 否则同AuthSessionMiddle
+func AuthMiddle() gin.HandlerFunc {
+	sessionAuth := AuthSessionMiddle()
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			sessionAuth(c)
+			return
+		}
+		item, user, err := apitoken.Authenticate(token, c.ClientIP())
+		if err != nil {
+			if err != apitoken.ErrInvalidToken {
+				logs.Error("[AuthMiddle] authenticate api token failed: err=%v", err)
+			}
+			abortWithError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if !apitoken.HasScope(item.Scopes, apitoken.MethodScope(c.Request.Method)) {
+			abortWithError(c, http.StatusForbidden, "Insufficient scope")
+			return
+		}
+		c.Set(tokenUserKey, &userInfo{UserID: user.ID, UserName: user.Username})
+		c.Set(tokenScopesKey, item.Scopes)
+		c.Next()
+	}
+}
+
+// bearerToken Authorization头不是Bearer时返回false, 交给session鉴权
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+// tokenUser 非token鉴权的请求返回nil
+func tokenUser(c *gin.Context) *userInfo {
+	value, exsit := c.Get(tokenUserKey)
+	if !exsit {
+		return nil
+	}
+	user, _ := value.(*userInfo)
+	return user
+}
+
+func abortWithError(c *gin.Context, status int, text string) {
+	c.Writer.WriteHeader(status)
+	c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rawData, _ := json.Marshal(gin.H{"error": text})
+	_, _ = c.Writer.Write(rawData)
+	c.Abort()
+}
@@ -0,0 +1,26 @@
+package model
+
+import "github.com/tdycwym/edgex_admin/resp"
+
+// APITokenInfo token明文只在创建时返回
+type APITokenInfo struct {
+	ID           int64    `json:"id"`
+	Name         string   `json:"name"`
+	Token        string   `json:"token,omitempty"`
+	TokenPrefix  string   `json:"token_prefix"`
+	Scopes       []string `json:"scopes"`
+	ExpireTime   string   `json:"expire_time"`
+	Expired      bool     `json:"expired"`
+	LastUsedTime string   `json:"last_used_time"` // 从未使用时为空
+	LastUsedIP   string   `json:"last_used_ip"`
+	CreatedTime  string   `json:"created_time"`
+}
+
+// Redacted 响应日志中隐藏token明文
+func (info *APITokenInfo) Redacted() interface{} {
+	redacted := *info
+	if redacted.Token != "" {
+		redacted.Token = resp.RedactedValue
+	}
+	return &redacted
+}
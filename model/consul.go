@@ -0,0 +1,27 @@
+package model
+
+import "github.com/tdycwym/edgex_admin/consul"
+
+// ConsulConfigItem edgex服务的一项配置, Key为相对服务根路径的key
+type ConsulConfigItem struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	ModifyIndex uint64 `json:"modify_index"`
+}
+
+// ConfigChangeInfo 配置变更的预览与审计记录
+type ConfigChangeInfo struct {
+	ChangeID      int64            `json:"change_id"`
+	EdgexID       int64            `json:"edgex_id"`
+	Service       string           `json:"service"`
+	Status        int32            `json:"status"`
+	Changes       []*consul.Change `json:"changes"`
+	CreatedBy     int64            `json:"created_by"`
+	CreatedByName string           `json:"created_by_name"`
+	AppliedBy     int64            `json:"applied_by"`
+	AppliedByName string           `json:"applied_by_name"`
+	Error         string           `json:"error"`
+	ExpireTime    string           `json:"expire_time"`
+	CreatedTime   string           `json:"created_time"`
+	AppliedTime   string           `json:"applied_time,omitempty"`
+}
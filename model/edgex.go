@@ -1,9 +1,12 @@
 package model
 
+import "github.com/tdycwym/edgex_admin/probe"
+
 // EdgexInfo ...
 type EdgexInfo struct {
 	EdgexID          int64  `json:"edgex_id"`
 	UserID           int64  `json:"user_id"`
+	OrgID            int64  `json:"org_id"`
 	UserName         string `json:"username"`
 	EdgexName        string `json:"edgex_name"`
 	Prefix           string `json:"prefix"`
@@ -15,4 +18,71 @@ type EdgexInfo struct {
 	Location         string `json:"location"`
 	Extra            string `json:"extra"`
 	IsFollow         bool   `json:"is_follow"`
+	Scheme           string `json:"scheme"`
+	TLSCA            string `json:"tls_ca"`
+	TLSServerName    string `json:"tls_server_name"`
+	TLSSkipVerify    bool   `json:"tls_skip_verify"`
+}
+
+// EdgexRecord 批量导入/导出的一行记录
+type EdgexRecord struct {
+	EdgexID     int64  `json:"edgex_id" yaml:"edgex_id"`
+	Prefix      string `json:"prefix" yaml:"prefix"`
+	EdgexName   string `json:"edgex_name" yaml:"edgex_name"`
+	OrgID       int64  `json:"org_id" yaml:"org_id"`
+	Address     string `json:"address" yaml:"address"`
+	Status      int32  `json:"status" yaml:"status"`
+	Description string `json:"description" yaml:"description"`
+	Location    string `json:"location" yaml:"location"`
+	Extra       string `json:"extra" yaml:"extra"`
+	CreatedTime string `json:"created_time" yaml:"created_time"`
+}
+
+// DuplicateInfo 唯一键冲突时返回冲突的字段
+type DuplicateInfo struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// PrefixCheckInfo ...
+type PrefixCheckInfo struct {
+	Prefix      string   `json:"prefix"`
+	Valid       bool     `json:"valid"`
+	Available   bool     `json:"available"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// DryRunReport dry_run模式下的校验与探测结果, 不写数据库
+type DryRunReport struct {
+	Valid  bool          `json:"valid"`
+	Errors []string      `json:"errors"`
+	Probe  *probe.Report `json:"probe"`
+}
+
+// CredentialInfo edgex凭证的元信息, 不包含任何秘密
+type CredentialInfo struct {
+	EdgexID        int64  `json:"edgex_id"`
+	Type           string `json:"type"`
+	Username       string `json:"username,omitempty"` // 仅basic类型
+	HasConsulToken bool   `json:"has_consul_token"`
+	CertSubject    string `json:"cert_subject,omitempty"` // 客户端证书
+	CertNotAfter   string `json:"cert_not_after,omitempty"`
+	UpdatedBy      int64  `json:"updated_by"`
+	UpdatedByName  string `json:"updated_by_name"`
+	ModifiedTime   string `json:"modified_time"`
+}
+
+// CertExpiryInfo 即将过期或已过期的证书
+type CertExpiryInfo struct {
+	EdgexID     int64  `json:"edgex_id"`
+	EdgexName   string `json:"edgex_name"`
+	Prefix      string `json:"prefix"`
+	Address     string `json:"address"`
+	UserID      int64  `json:"user_id"`
+	Kind        string `json:"kind"` // server/client
+	Subject     string `json:"subject"`
+	Issuer      string `json:"issuer"`
+	NotAfter    string `json:"not_after"`
+	DaysLeft    int    `json:"days_left"` // 已过期时为负数
+	CheckedTime string `json:"checked_time"`
 }
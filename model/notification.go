@@ -0,0 +1,34 @@
+package model
+
+// NotifyPrefInfo ...
+type NotifyPrefInfo struct {
+	EmailEnabled  bool     `json:"email_enabled"`
+	InboxEnabled  bool     `json:"inbox_enabled"`
+	Alerts        []string `json:"alerts"` // ["*"]表示全部
+	DigestEnabled bool     `json:"digest_enabled"`
+}
+
+// NotificationInfo ...
+type NotificationInfo struct {
+	ID          int64  `json:"id"`
+	EdgexID     int64  `json:"edgex_id"`
+	EventID     int64  `json:"event_id"`
+	Category    string `json:"category"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	IsRead      bool   `json:"is_read"`
+	CreatedTime string `json:"created_time"`
+}
+
+// NotificationList total和unread为用户全部通知的计数, 不受过滤条件影响
+type NotificationList struct {
+	Total         int64               `json:"total"`
+	Unread        int64               `json:"unread"`
+	Notifications []*NotificationInfo `json:"notifications"`
+}
+
+// NotificationCount ...
+type NotificationCount struct {
+	Total  int64 `json:"total"`
+	Unread int64 `json:"unread"`
+}
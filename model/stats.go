@@ -0,0 +1,51 @@
+package model
+
+// FleetStats 首页统计
+type FleetStats struct {
+	Action          string           `json:"action"`
+	Total           int64            `json:"total"`
+	ByStatus        []*StatsCount    `json:"by_status"` // key: active/inactive
+	ByLocation      []*LocationCount `json:"by_location"`
+	ByOwner         []*OwnerCount    `json:"by_owner"`
+	ByMonth         []*StatsCount    `json:"by_month"` // key: 2021-04, 没有创建的月份计数为0
+	TopFollowed     []*FollowedEdgex `json:"top_followed"`
+	RecentlyChanged []*ChangedEdgex  `json:"recently_changed"`
+	GeneratedTime   string           `json:"generated_time"` // 统计时间, 缓存期间不变
+}
+
+// StatsCount ...
+type StatsCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// LocationCount 按location中的province/city统计, 未填写时为空
+type LocationCount struct {
+	Province string `json:"province"`
+	City     string `json:"city"`
+	Count    int64  `json:"count"`
+}
+
+// OwnerCount ...
+type OwnerCount struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Count    int64  `json:"count"`
+}
+
+// FollowedEdgex ...
+type FollowedEdgex struct {
+	EdgexID   int64  `json:"edgex_id"`
+	EdgexName string `json:"edgex_name"`
+	Prefix    string `json:"prefix"`
+	Followers int64  `json:"followers"`
+}
+
+// ChangedEdgex ...
+type ChangedEdgex struct {
+	EdgexID      int64  `json:"edgex_id"`
+	EdgexName    string `json:"edgex_name"`
+	Prefix       string `json:"prefix"`
+	Status       int32  `json:"status"`
+	ModifiedTime string `json:"modified_time"`
+}
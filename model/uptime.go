@@ -0,0 +1,56 @@
+package model
+
+// LatencyStats 可达样本的延迟统计 单位：ms
+type LatencyStats struct {
+	Samples int64   `json:"samples"`
+	Min     int64   `json:"min"`
+	Max     int64   `json:"max"`
+	Avg     float64 `json:"avg"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P95     float64 `json:"p95"`
+	P99     float64 `json:"p99"`
+	Exact   bool    `json:"exact"` // 窗口内有小时聚合数据时分位数为直方图估算值
+}
+
+// OutageInfo 不可达区间, 已按报告窗口截断
+type OutageInfo struct {
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"` // 0表示仍未恢复
+	Duration  int64  `json:"duration"` // 单位：s
+	Error     string `json:"error"`
+}
+
+// UptimeReport edgex在[start, end)内的可用性报告
+type UptimeReport struct {
+	EdgexID          int64         `json:"edgex_id"`
+	EdgexName        string        `json:"edgex_name"`
+	Prefix           string        `json:"prefix"`
+	OrgID            int64         `json:"org_id"`
+	Start            int64         `json:"start"`
+	End              int64         `json:"end"`
+	MonitoredSeconds int64         `json:"monitored_seconds"` // 窗口与edgex存续期的交集
+	DowntimeSeconds  int64         `json:"downtime_seconds"`
+	UptimePercent    float64       `json:"uptime_percent"`
+	Samples          int64         `json:"samples"`
+	UpSamples        int64         `json:"up_samples"`
+	SLATarget        float64       `json:"sla_target,omitempty"`
+	SLAMet           *bool         `json:"sla_met,omitempty"`
+	Latency          *LatencyStats `json:"latency"`
+	Outages          []*OutageInfo `json:"outages,omitempty"`
+}
+
+// OrgUptimeReport 组织下全部edgex的可用性报告
+type OrgUptimeReport struct {
+	OrgID            int64           `json:"org_id"`
+	Start            int64           `json:"start"`
+	End              int64           `json:"end"`
+	MonitoredSeconds int64           `json:"monitored_seconds"`
+	DowntimeSeconds  int64           `json:"downtime_seconds"`
+	UptimePercent    float64         `json:"uptime_percent"` // 按监控时长加权
+	OutageCount      int             `json:"outage_count"`
+	SLATarget        float64         `json:"sla_target,omitempty"`
+	SLAMet           *bool           `json:"sla_met,omitempty"`
+	Latency          *LatencyStats   `json:"latency"`
+	EdgexList        []*UptimeReport `json:"edgex_list"`
+}
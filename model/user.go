@@ -0,0 +1,66 @@
+package model
+
+import "github.com/tdycwym/edgex_admin/resp"
+
+// SecurityQuestionInfo ...
+type SecurityQuestionInfo struct {
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+}
+
+// SecurityVerifyResult 密保校验通过后颁发的重置token, 用于/password/reset
+type SecurityVerifyResult struct {
+	Token     string `json:"token"`
+	ExpiresIn int64  `json:"expires_in"` // 单位：s
+}
+
+// Redacted 响应日志中隐藏重置token
+func (result *SecurityVerifyResult) Redacted() interface{} {
+	return &SecurityVerifyResult{Token: resp.RedactedValue, ExpiresIn: result.ExpiresIn}
+}
+
+// LoginResult 需要两步验证时返回, 此时只保存了预认证session
+type LoginResult struct {
+	Need2FA    bool `json:"need_2fa"`
+	NeedEnroll bool `json:"need_enroll"` // 角色要求两步验证但尚未开启, 需先绑定
+}
+
+// MFAStatus ...
+type MFAStatus struct {
+	Enabled       bool  `json:"enabled"`
+	Required      bool  `json:"required"`
+	RecoveryCodes int64 `json:"recovery_codes"` // 剩余未使用的恢复码个数
+}
+
+// MFAEnrollInfo uri为otpauth格式, 前端据此生成二维码
+type MFAEnrollInfo struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Redacted 响应日志中隐藏密钥, uri中同样含有密钥
+func (info *MFAEnrollInfo) Redacted() interface{} {
+	return &MFAEnrollInfo{Secret: resp.RedactedValue, URI: resp.RedactedValue}
+}
+
+// MFARecoveryCodes 恢复码明文只在生成时返回一次
+type MFARecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Redacted 响应日志中只保留恢复码个数
+func (info *MFARecoveryCodes) Redacted() interface{} {
+	codes := make([]string, len(info.RecoveryCodes))
+	for i := range codes {
+		codes[i] = resp.RedactedValue
+	}
+	return &MFARecoveryCodes{RecoveryCodes: codes}
+}
+
+// MFAPolicyInfo ...
+type MFAPolicyInfo struct {
+	Role              int32 `json:"role"`
+	Required          bool  `json:"required"`
+	UpdatedBy         int64 `json:"updated_by"`
+	ModifiedTimestamp int64 `json:"modified_timestamp"`
+}
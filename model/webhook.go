@@ -0,0 +1,42 @@
+package model
+
+import "github.com/tdycwym/edgex_admin/resp"
+
+// WebhookInfo secret只在创建时返回
+type WebhookInfo struct {
+	ID          int64    `json:"id"`
+	UserID      int64    `json:"user_id"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`
+	Events      []string `json:"events"`
+	Status      int32    `json:"status"`
+	Description string   `json:"description"`
+	CreatedTime string   `json:"created_time"`
+}
+
+// Redacted 响应日志中隐藏签名secret
+func (info *WebhookInfo) Redacted() interface{} {
+	redacted := *info
+	if redacted.Secret != "" {
+		redacted.Secret = resp.RedactedValue
+	}
+	return &redacted
+}
+
+// WebhookDeliveryInfo ...
+type WebhookDeliveryInfo struct {
+	ID              int64  `json:"id"`
+	SubscriptionID  int64  `json:"subscription_id"`
+	EventID         int64  `json:"event_id"`
+	EventType       string `json:"event_type"`
+	Payload         string `json:"payload"`
+	Status          int32  `json:"status"`
+	Attempts        int32  `json:"attempts"`
+	NextAttemptTime string `json:"next_attempt_time"`
+	LastStatusCode  int32  `json:"last_status_code"`
+	LastError       string `json:"last_error"`
+	LastResponse    string `json:"last_response"`
+	LastLatencyMs   int64  `json:"last_latency_ms"`
+	CreatedTime     string `json:"created_time"`
+	ModifiedTime    string `json:"modified_time"`
+}
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/dal"
+	"gorm.io/gorm"
+)
+
+// 通知类型, 告警类型见alert.CategoryPrefix
+const (
+	CategoryFollowed = "edgex.followed" // 网关被关注, 发给创建人
+)
+
+// Message 一条站内通知的内容, 发送给每个接收人各一份
+type Message struct {
+	Category string // e.g. alert.offline, 前端据此展示图标和跳转
+	Title    string
+	Content  string
+	EdgexID  int64 // 相关的edgex, 没有时为0
+	EventID  int64 // 触发通知的事件, 没有时为0
+}
+
+// Audience 接收人, 各项取并集后去重并排除Exclude
+type Audience struct {
+	UserIDs   []int64
+	Followers int64 // 关注该edgex的用户
+	Owner     int64 // 该edgex的创建人
+	Exclude   []int64
+}
+
+// Resolve 展开为去重后的用户, 已删除的用户不在其中
+func (a *Audience) Resolve() ([]*dal.EdgexUser, error) {
+	candidates := append([]int64{}, a.UserIDs...)
+	if a.Followers > 0 {
+		followerList, err := dal.GetFollowerList(a.Followers)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range followerList {
+			candidates = append(candidates, item.UserID)
+		}
+	}
+	if a.Owner > 0 {
+		edgex, err := dal.GetEdgexByID(a.Owner)
+		if err != nil {
+			return nil, err
+		}
+		if edgex != nil {
+			candidates = append(candidates, edgex.UserID)
+		}
+	}
+
+	seen := make(map[int64]bool)
+	for _, userID := range a.Exclude {
+		seen[userID] = true
+	}
+	userIDs := make([]int64, 0, len(candidates))
+	for _, userID := range candidates {
+		if userID <= 0 || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		userIDs = append(userIDs, userID)
+	}
+	if len(userIDs) == 0 {
+		return []*dal.EdgexUser{}, nil
+	}
+
+	userMap, err := dal.GetEdgexUserMapByIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	userList := make([]*dal.EdgexUser, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if user, ok := userMap[userID]; ok && user.Deleted == 0 {
+			userList = append(userList, user)
+		}
+	}
+	return userList, nil
+}
+
+// Send 为audience中的每个用户写入一条站内通知, 可在业务事务db中调用; 返回实际接收人
+func Send(db *gorm.DB, audience *Audience, msg *Message) ([]*dal.EdgexUser, error) {
+	userList, err := audience.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]int64, 0, len(userList))
+	for _, user := range userList {
+		userIDs = append(userIDs, user.ID)
+	}
+	return userList, SendToUsers(db, userIDs, msg)
+}
+
+// SendToUsers 接收人已确定时直接写入, 不再校验用户是否存在
+func SendToUsers(db *gorm.DB, userIDs []int64, msg *Message) error {
+	now := time.Now()
+	itemList := make([]*dal.EdgexNotification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		itemList = append(itemList, &dal.EdgexNotification{
+			UserID:       userID,
+			EdgexID:      msg.EdgexID,
+			EventID:      msg.EventID,
+			Category:     msg.Category,
+			Title:        msg.Title,
+			Content:      msg.Content,
+			CreatedTime:  now,
+			ModifiedTime: now,
+		})
+	}
+	return dal.AddNotifications(db, itemList)
+}
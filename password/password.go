@@ -0,0 +1,152 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tdycwym/edgex_admin/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 哈希算法, 结果带有各自的格式前缀, 据此识别算法和参数
+const (
+	AlgorithmBcrypt   = "bcrypt"   // $2a$<cost>$...
+	AlgorithmArgon2id = "argon2id" // $argon2id$v=19$m=<KiB>,t=<time>,p=<threads>$<salt>$<hash>
+)
+
+const (
+	bcryptPrefix   = "$2"
+	argon2idPrefix = "$argon2id$"
+
+	defaultArgon2Time    = 3
+	defaultArgon2Memory  = 64 * 1024
+	defaultArgon2Threads = 2
+	argon2SaltLen        = 16
+	argon2KeyLen         = 32
+)
+
+// ErrMismatch 密码错误
+var ErrMismatch = errors.New("password is invalid")
+
+// Hash 按[Password] Algorithm计算哈希
+func Hash(plain string) (string, error) {
+	if algorithm() == AlgorithmArgon2id {
+		return hashArgon2id(plain, argon2Params())
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), bcryptCost())
+	return string(hashed), err
+}
+
+// Verify 校验密码, 匹配时返回nil; needRehash表示哈希是明文、算法或参数与当前配置不一致, 应在登录成功后重新计算
+func Verify(hashed string, plain string) (needRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hashed, argon2idPrefix):
+		params, salt, key, err := decodeArgon2id(hashed)
+		if err != nil {
+			return false, err
+		}
+		actual := argon2.IDKey([]byte(plain), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, ErrMismatch
+		}
+		return algorithm() != AlgorithmArgon2id || *params != *argon2Params(), nil
+	case strings.HasPrefix(hashed, bcryptPrefix):
+		err = bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, ErrMismatch
+		}
+		if err != nil {
+			return false, err
+		}
+		cost, _ := bcrypt.Cost([]byte(hashed))
+		return algorithm() != AlgorithmBcrypt || cost != bcryptCost(), nil
+	default:
+		// 迁移前保存的明文密码
+		if hashed == "" || subtle.ConstantTimeCompare([]byte(hashed), []byte(plain)) != 1 {
+			return false, ErrMismatch
+		}
+		return true, nil
+	}
+}
+
+// IsHashed 是否已是支持的哈希格式, 否则视为明文
+func IsHashed(hashed string) bool {
+	return strings.HasPrefix(hashed, argon2idPrefix) || strings.HasPrefix(hashed, bcryptPrefix)
+}
+
+func algorithm() string {
+	if config.PasswordConf.Algorithm == AlgorithmArgon2id {
+		return AlgorithmArgon2id
+	}
+	return AlgorithmBcrypt
+}
+
+func bcryptCost() int {
+	cost := config.PasswordConf.BcryptCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+type argon2Config struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+func argon2Params() *argon2Config {
+	params := &argon2Config{
+		time:    defaultArgon2Time,
+		memory:  defaultArgon2Memory,
+		threads: defaultArgon2Threads,
+	}
+	if config.PasswordConf.Argon2Time > 0 {
+		params.time = uint32(config.PasswordConf.Argon2Time)
+	}
+	if config.PasswordConf.Argon2Memory > 0 {
+		params.memory = uint32(config.PasswordConf.Argon2Memory)
+	}
+	if config.PasswordConf.Argon2Threads > 0 && config.PasswordConf.Argon2Threads <= 255 {
+		params.threads = uint8(config.PasswordConf.Argon2Threads)
+	}
+	return params
+}
+
+func hashArgon2id(plain string, params *argon2Config) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plain), salt, params.time, params.memory, params.threads, argon2KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		params.memory, params.time, params.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func decodeArgon2id(hashed string) (params *argon2Config, salt []byte, key []byte, err error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2id version: %s", parts[2])
+	}
+	params = &argon2Config{}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id params: %s", parts[3])
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, nil, nil, err
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, nil, nil, err
+	}
+	return params, salt, key, nil
+}
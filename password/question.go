@@ -0,0 +1,103 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+)
+
+const (
+	answerAttemptKeyFmt      = "edgex_admin:security_answer_attempt:%d"
+	defaultAnswerMaxAttempts = 5
+	defaultAnswerLockTime    = 15 * time.Minute
+)
+
+// ErrTooManyAttempts 密保答案错误次数过多, 锁定期内拒绝校验
+var ErrTooManyAttempts = errors.New("too many security answer attempts")
+
+// NormalizeAnswer 去掉首尾及重复空白、忽略大小写和全角半角差异, 避免输入习惯不同导致答案不匹配
+func NormalizeAnswer(answer string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.TrimSpace(answer) {
+		switch {
+		case r == '　':
+			r = ' '
+		case r >= '！' && r <= '～':
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// HashAnswer 规范化后按密码的算法计算哈希
+func HashAnswer(answer string) (string, error) {
+	return Hash(NormalizeAnswer(answer))
+}
+
+// VerifyAnswer 匹配时返回nil
+func VerifyAnswer(hashed string, answer string) error {
+	if !IsHashed(hashed) {
+		return ErrMismatch
+	}
+	_, err := Verify(hashed, NormalizeAnswer(answer))
+	return err
+}
+
+// attemptScript 计数加一并返回当前值, 第一次时设置锁定期
+var attemptScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// AcquireAnswerAttempt 校验答案前调用, 先原子地计数再比较, 避免并发请求同时通过检查;
+// 锁定期(从第一次校验开始计算)内次数超过上限时返回ErrTooManyAttempts, redis不可用时返回错误, 均应拒绝校验
+func AcquireAnswerAttempt(userID int64) error {
+	key := fmt.Sprintf(answerAttemptKeyFmt, userID)
+	count, err := attemptScript.Run(context.Background(), caller.RedisClient, []string{key}, int64(answerLockTime().Seconds())).Int()
+	if err != nil {
+		return err
+	}
+	if count > answerMaxAttempts() {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// ClearAnswerAttempts 校验成功后清除错误次数
+func ClearAnswerAttempts(userID int64) {
+	caller.RedisClient.Del(context.Background(), fmt.Sprintf(answerAttemptKeyFmt, userID))
+}
+
+func answerMaxAttempts() int {
+	if config.PasswordConf.AnswerMaxAttempts <= 0 {
+		return defaultAnswerMaxAttempts
+	}
+	return config.PasswordConf.AnswerMaxAttempts
+}
+
+func answerLockTime() time.Duration {
+	if config.PasswordConf.AnswerLockTime <= 0 {
+		return defaultAnswerLockTime
+	}
+	return time.Duration(config.PasswordConf.AnswerLockTime) * time.Second
+}
@@ -0,0 +1,102 @@
+package password
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+)
+
+const (
+	resetTokenKeyFmt  = "edgex_admin:password_reset:%s"      // token哈希 -> user_id
+	resetUserKeyFmt   = "edgex_admin:password_reset_user:%d" // user_id -> 最新的token哈希
+	defaultResetTTL   = 30 * time.Minute
+	resetTokenByteLen = 32
+)
+
+// ErrInvalidToken token不存在、已使用或已过期
+var ErrInvalidToken = errors.New("reset token is invalid or expired")
+
+// ResetTTL ...
+func ResetTTL() time.Duration {
+	if config.PasswordConf.ResetTokenTTL <= 0 {
+		return defaultResetTTL
+	}
+	return time.Duration(config.PasswordConf.ResetTokenTTL) * time.Second
+}
+
+// IssueResetToken 生成一次性的重置token, redis中只保存其哈希; 同一用户之前未使用的token作废
+func IssueResetToken(userID int64) (string, error) {
+	buf := make([]byte, resetTokenByteLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	ctx := context.Background()
+	userKey := fmt.Sprintf(resetUserKeyFmt, userID)
+	if previous, err := caller.RedisClient.Get(ctx, userKey).Result(); err == nil {
+		caller.RedisClient.Del(ctx, fmt.Sprintf(resetTokenKeyFmt, previous))
+	}
+	tokenHash := hashToken(token)
+	_, err := caller.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, fmt.Sprintf(resetTokenKeyFmt, tokenHash), userID, ResetTTL())
+		pipe.Set(ctx, userKey, tokenHash, ResetTTL())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeResetToken 校验并删除token, 同一token只能使用一次
+func ConsumeResetToken(token string) (int64, error) {
+	if token == "" {
+		return 0, ErrInvalidToken
+	}
+	ctx := context.Background()
+	key := fmt.Sprintf(resetTokenKeyFmt, hashToken(token))
+	var getCmd *redis.StringCmd
+	_, err := caller.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		getCmd = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	userID, err := getCmd.Int64()
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	caller.RedisClient.Del(ctx, fmt.Sprintf(resetUserKeyFmt, userID))
+	return userID, nil
+}
+
+// PeekResetToken 校验token但不删除, 用于持有重置token时修改密保等操作
+func PeekResetToken(token string) (int64, error) {
+	if token == "" {
+		return 0, ErrInvalidToken
+	}
+	userID, err := caller.RedisClient.Get(context.Background(), fmt.Sprintf(resetTokenKeyFmt, hashToken(token))).Int64()
+	if err == redis.Nil {
+		return 0, ErrInvalidToken
+	}
+	return userID, err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
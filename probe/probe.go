@@ -0,0 +1,254 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 3 * time.Second
+
+// edgex v2/v1的ping与version接口, 按顺序尝试
+var (
+	pingPaths    = []string{"/api/v2/ping", "/api/v1/ping"}
+	versionPaths = []string{"/api/v2/version", "/api/version"}
+)
+
+// StepResult 单个探测步骤的结果
+type StepResult struct {
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report 地址探测报告
+type Report struct {
+	Address   string      `json:"address"`
+	BaseURL   string      `json:"base_url"`
+	Reachable bool        `json:"reachable"` // tcp可连通且ping成功
+	Version   string      `json:"version"`
+	DNS       *StepResult `json:"dns"`
+	TCP       *StepResult `json:"tcp"`
+	Ping      *StepResult `json:"ping"`
+	Detect    *StepResult `json:"version_detect"`
+	TLS       *TLSInfo    `json:"tls,omitempty"` // 仅https
+	LatencyMs int64       `json:"latency_ms"`
+}
+
+// TLSInfo TLS握手信息与网关证书
+type TLSInfo struct {
+	Version    string    `json:"version"`
+	ServerName string    `json:"server_name"`
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	NotAfter   time.Time `json:"not_after"`
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS1.0",
+	tls.VersionTLS11: "TLS1.1",
+	tls.VersionTLS12: "TLS1.2",
+	tls.VersionTLS13: "TLS1.3",
+}
+
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	leaf := state.PeerCertificates[0]
+	return &TLSInfo{
+		Version:    tlsVersions[state.Version],
+		ServerName: state.ServerName,
+		Subject:    leaf.Subject.String(),
+		Issuer:     leaf.Issuer.String(),
+		NotAfter:   leaf.NotAfter,
+	}
+}
+
+// Error 第一个失败步骤的错误
+func (r *Report) Error() string {
+	for _, step := range []*StepResult{r.DNS, r.TCP, r.Ping} {
+		if step != nil && !step.OK {
+			return step.Error
+		}
+	}
+	return ""
+}
+
+// Prober ...
+type Prober struct {
+	Timeout time.Duration
+	Client  *http.Client
+	Header  http.Header // 附加到每个请求的头, e.g. edgex网关凭证
+}
+
+// NewProber timeout<=0时使用默认超时
+func NewProber(timeout time.Duration) *Prober {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Prober{
+		Timeout: timeout,
+		Client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// ParseAddress 兼容 host:port 与 http(s)://host:port/path 两种写法
+func ParseAddress(address string) (*url.URL, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return nil, fmt.Errorf("address is empty")
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("address has no host: %s", address)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	return u, nil
+}
+
+// Probe 依次进行DNS解析、TCP连接、EdgeX ping和版本探测, 前一步失败时后续步骤跳过
+func (p *Prober) Probe(ctx context.Context, address string) *Report {
+	start := time.Now()
+	report := &Report{Address: address}
+	defer func() {
+		report.LatencyMs = time.Since(start).Milliseconds()
+	}()
+
+	u, err := ParseAddress(address)
+	if err != nil {
+		report.DNS = &StepResult{Error: err.Error()}
+		return report
+	}
+	report.BaseURL = u.String()
+
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	// Step1. DNS
+	var ips []string
+	report.DNS = p.step(func() (string, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			ips = []string{ip.String()}
+			return "ip address, skip resolving", nil
+		}
+		resolveCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+		ips, err = net.DefaultResolver.LookupHost(resolveCtx, host)
+		return strings.Join(ips, ","), err
+	})
+	if !report.DNS.OK {
+		return report
+	}
+
+	// Step2. TCP
+	report.TCP = p.step(func() (string, error) {
+		dialer := &net.Dialer{Timeout: p.Timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ips[0], port))
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		return conn.RemoteAddr().String(), nil
+	})
+	if !report.TCP.OK {
+		return report
+	}
+
+	// Step3. EdgeX ping
+	report.Ping = p.step(func() (string, error) {
+		body, state, err := p.tryGet(ctx, u, pingPaths)
+		report.TLS = newTLSInfo(state)
+		return body, err
+	})
+	report.Reachable = report.Ping.OK
+	if !report.Ping.OK {
+		return report
+	}
+
+	// Step4. 版本探测, 失败不影响可达性
+	report.Detect = p.step(func() (string, error) {
+		body, _, err := p.tryGet(ctx, u, versionPaths)
+		if err != nil {
+			return "", err
+		}
+		report.Version = parseVersion(body)
+		return body, nil
+	})
+	return report
+}
+
+func (p *Prober) step(fn func() (string, error)) *StepResult {
+	start := time.Now()
+	detail, err := fn()
+	result := &StepResult{
+		OK:        err == nil,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Detail:    detail,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// tryGet 返回第一个2xx响应的body, https时同时返回TLS连接状态
+func (p *Prober) tryGet(ctx context.Context, base *url.URL, paths []string) (string, *tls.ConnectionState, error) {
+	var lastErr error
+	for _, path := range paths {
+		req, err := http.NewRequest(http.MethodGet, base.String()+path, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		for key, values := range p.Header {
+			req.Header[key] = values
+		}
+		rsp, err := p.Client.Do(req.WithContext(ctx))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, _ := ioutil.ReadAll(io.LimitReader(rsp.Body, 4096))
+		rsp.Body.Close()
+		if rsp.StatusCode/100 != 2 {
+			lastErr = fmt.Errorf("GET %s: status=%d", path, rsp.StatusCode)
+			continue
+		}
+		return strings.TrimSpace(string(body)), rsp.TLS, nil
+	}
+	return "", nil, lastErr
+}
+
+// parseVersion e.g. {"version":"1.3.0"} 或 {"apiVersion":"v2","version":"2.0.0"}
+func parseVersion(body string) string {
+	v := struct {
+		Version string `json:"version"`
+	}{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return ""
+	}
+	return v.Version
+}
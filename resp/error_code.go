@@ -6,6 +6,12 @@ const (
 	RespCodeSuccess         ErrorCode = 0
 	RespCodeParamsError     ErrorCode = 4001
 	RespCodeUserExsit       ErrorCode = 4002
+	RespCodeNoPermission    ErrorCode = 4003
+	RespCodeExtraInvalid    ErrorCode = 4004
+	RespCodeDuplicate       ErrorCode = 4005
+	RespCodeUnreachable     ErrorCode = 4006
+	RespCodeConflict        ErrorCode = 4007
+	RespCodeTooFrequent     ErrorCode = 4008
 	RespCodeServerException ErrorCode = 5000
 	RespDatabaseError       ErrorCode = 5001
 	RespCodeRedisError      ErrorCode = 5002
@@ -26,6 +32,18 @@ func (p ErrorCode) Prompts() string {
 		return "请求参数错误"
 	case RespCodeUserExsit:
 		return "用户名已存在"
+	case RespCodeNoPermission:
+		return "没有操作权限"
+	case RespCodeExtraInvalid:
+		return "扩展属性不符合schema定义"
+	case RespCodeDuplicate:
+		return "已存在相同记录"
+	case RespCodeUnreachable:
+		return "edgex地址无法连通"
+	case RespCodeConflict:
+		return "数据已被他人修改，请刷新后重试"
+	case RespCodeTooFrequent:
+		return "操作过于频繁，请稍后重试"
 	case RespCodeServerException, RespDatabaseError,
 		RespCodeRedisError, RespCodeRPCError:
 		return "服务器内部错误，请稍后重试"
@@ -41,6 +59,18 @@ func (p ErrorCode) Message() string {
 		return "params error"
 	case RespCodeUserExsit:
 		return "username exsited"
+	case RespCodeNoPermission:
+		return "permission denied"
+	case RespCodeExtraInvalid:
+		return "extra is invalid"
+	case RespCodeDuplicate:
+		return "duplicate entry"
+	case RespCodeUnreachable:
+		return "address unreachable"
+	case RespCodeConflict:
+		return "conflict"
+	case RespCodeTooFrequent:
+		return "too many requests"
 	case RespCodeServerException, RespDatabaseError,
 		RespCodeRedisError, RespCodeRPCError:
 		return "server exception"
@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
 	"runtime"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/metrics"
 )
 
 // JSONOutPutWrapper ...
@@ -18,7 +20,7 @@ func JSONOutPutWrapper(call func(*gin.Context) *JSONOutput) func(c *gin.Context)
 		var output *JSONOutput
 
 		logs.Info("[wraper-request] url=%s, header=%v, body=%v",
-			c.Request.URL, c.Request.Header, c.Request.Body)
+			redactURL(c.Request.URL), redactHeader(c.Request.Header), c.Request.Body)
 
 		start := time.Now()
 
@@ -40,14 +42,108 @@ func JSONOutPutWrapper(call func(*gin.Context) *JSONOutput) func(c *gin.Context)
 
 			output.Write()
 
-			userTime := time.Since(start).Nanoseconds() / 1000
+			cost := time.Since(start)
+			userTime := cost.Nanoseconds() / 1000
 			logs.Info("[wraper-response] useTime=%d, status=%d, resp=%s",
-				userTime, output.HTTPStatus, GetMarshalStr(output.Resp))
+				userTime, output.HTTPStatus, GetMarshalStr(redactResp(output.Resp)))
+
+			code := int32(output.HTTPStatus)
+			if rsp, ok := output.Resp.(*StdResponse); ok && rsp != nil {
+				code = rsp.Status
+			}
+			metrics.ObserveRequest(c.FullPath(), c.Request.Method, code, cost)
 		}()
 		output = call(c)
 	}
 }
 
+// RedactedValue 日志中替换敏感内容
+const RedactedValue = "[REDACTED]"
+
+// Redactor 响应数据含有token、密钥等只应返回给调用方的字段时实现, 响应日志记录Redacted()的结果
+type Redactor interface {
+	Redacted() interface{}
+}
+
+// sensitiveHeaders 记录请求日志时隐藏的header
+var sensitiveHeaders = []string{"Authorization", "Cookie"}
+
+// redactHeader 返回副本, 不修改原请求
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, key := range sensitiveHeaders {
+		if redacted.Get(key) != "" {
+			redacted.Set(key, RedactedValue)
+		}
+	}
+	return redacted
+}
+
+// sensitiveQuery 记录日志时隐藏的URL参数, 如退订链接中的token
+var sensitiveQuery = []string{"token"}
+
+// redactURL 返回副本, 不修改原请求
+func redactURL(u *url.URL) string {
+	copied := *u
+	copied.RawQuery = redactedQuery(u)
+	return copied.String()
+}
+
+// redactedQuery 不含敏感参数时原样返回
+func redactedQuery(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+	for _, key := range sensitiveQuery {
+		if _, ok := query[key]; ok {
+			query.Set(key, RedactedValue)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.RawQuery
+	}
+	return query.Encode()
+}
+
+// rawQueryKey 隐藏前的URL参数
+const rawQueryKey = "raw_query"
+
+// HideSensitiveQuery 与RestoreSensitiveQuery分别注册在访问日志之前和之后, 使访问日志记录隐藏token等参数后的URL
+func HideSensitiveQuery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if query := redactedQuery(c.Request.URL); query != c.Request.URL.RawQuery {
+			c.Set(rawQueryKey, c.Request.URL.RawQuery)
+			c.Request.URL.RawQuery = query
+		}
+		c.Next()
+	}
+}
+
+// RestoreSensitiveQuery 恢复原始URL参数, 接口仍可读取如退订链接中的token
+func RestoreSensitiveQuery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if raw, ok := c.Get(rawQueryKey); ok {
+			c.Request.URL.RawQuery = raw.(string)
+		}
+		c.Next()
+	}
+}
+
+// redactResp 返回副本, 不修改实际响应
+func redactResp(obj interface{}) interface{} {
+	rsp, ok := obj.(*StdResponse)
+	if !ok || rsp == nil {
+		return obj
+	}
+	redactor, ok := rsp.Data.(Redactor)
+	if !ok {
+		return obj
+	}
+	redacted := *rsp
+	redacted.Data = redactor.Redacted()
+	return &redacted
+}
+
 // GetMarshalStr ...
 func GetMarshalStr(obj interface{}) string {
 	vi := reflect.ValueOf(obj)
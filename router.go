@@ -3,23 +3,92 @@ package main
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/tdycwym/edgex_admin/handlers"
+	"github.com/tdycwym/edgex_admin/handlers/attribute"
+	"github.com/tdycwym/edgex_admin/handlers/consul"
+	"github.com/tdycwym/edgex_admin/handlers/discovery"
 	"github.com/tdycwym/edgex_admin/handlers/edgex"
+	"github.com/tdycwym/edgex_admin/handlers/notification"
+	"github.com/tdycwym/edgex_admin/handlers/stats"
+	"github.com/tdycwym/edgex_admin/handlers/stream"
+	"github.com/tdycwym/edgex_admin/handlers/uptime"
 	"github.com/tdycwym/edgex_admin/handlers/user"
+	"github.com/tdycwym/edgex_admin/handlers/webhook"
+	"github.com/tdycwym/edgex_admin/metrics"
 	"github.com/tdycwym/edgex_admin/middleware/session"
 	"github.com/tdycwym/edgex_admin/resp"
 )
 
 func registerRouter(r *gin.Engine) {
 	r.GET("/ping", handlers.Ping)
+	// 使用[Metrics] Token鉴权而非session
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	// Prometheus服务发现, 使用[Discovery] Token鉴权而非session
+	r.GET("/edgex_admin/discovery/prometheus", discovery.PrometheusTargets)
+	// 摘要邮件的退订链接, 使用签名token而非session
+	r.GET("/edgex_admin/notification/digest/unsubscribe", resp.JSONOutPutWrapper(notification.UnsubscribeDigest))
 	// your code
 
-	edgexRouter := r.Group("/edgex_admin/edgex", session.AuthSessionMiddle())
+	// 以下接口同时接受session和API token(Authorization: Bearer), token按read/write/admin权限限制
+	edgexRouter := r.Group("/edgex_admin/edgex", session.AuthMiddle())
 	{
 		edgexRouter.GET("/search", resp.JSONOutPutWrapper(edgex.SearchEdgex))
 		edgexRouter.POST("/create", resp.JSONOutPutWrapper(edgex.CreateEdgex))
+		edgexRouter.POST("/update", resp.JSONOutPutWrapper(edgex.UpdateEdgex))
+		edgexRouter.GET("/prefix/check", resp.JSONOutPutWrapper(edgex.CheckPrefix))
+		edgexRouter.GET("/export", edgex.ExportEdgex)
+		edgexRouter.POST("/import", resp.JSONOutPutWrapper(edgex.ImportEdgex))
 		edgexRouter.POST("/delete", resp.JSONOutPutWrapper(edgex.DeleteEdgex))
 		edgexRouter.POST("/follow", resp.JSONOutPutWrapper(edgex.FollowEdgex))
 		edgexRouter.POST("/unfollow", resp.JSONOutPutWrapper(edgex.UnFollowEdgex))
+		edgexRouter.GET("/credential", resp.JSONOutPutWrapper(edgex.GetEdgexCredential))
+		edgexRouter.POST("/credential/save", resp.JSONOutPutWrapper(edgex.SaveEdgexCredential))
+		edgexRouter.POST("/credential/delete", resp.JSONOutPutWrapper(edgex.DeleteEdgexCredential))
+		edgexRouter.GET("/cert/expiring", resp.JSONOutPutWrapper(edgex.GetExpiringCerts))
+	}
+	attributeRouter := r.Group("/edgex_admin/attribute", session.AuthMiddle())
+	{
+		attributeRouter.GET("/schema", resp.JSONOutPutWrapper(attribute.GetAttributeSchema))
+		attributeRouter.POST("/schema/save", session.AdminAuthMiddle(), resp.JSONOutPutWrapper(attribute.SaveAttributeSchema))
+		attributeRouter.POST("/schema/delete", session.AdminAuthMiddle(), resp.JSONOutPutWrapper(attribute.DeleteAttributeSchema))
+	}
+	consulRouter := r.Group("/edgex_admin/consul", session.AuthMiddle())
+	{
+		consulRouter.GET("/services", resp.JSONOutPutWrapper(consul.GetConsulServices))
+		consulRouter.GET("/config", resp.JSONOutPutWrapper(consul.GetConsulConfig))
+		consulRouter.POST("/preview", resp.JSONOutPutWrapper(consul.PreviewConsulConfig))
+		consulRouter.POST("/apply", resp.JSONOutPutWrapper(consul.ApplyConsulConfig))
+		consulRouter.POST("/cancel", resp.JSONOutPutWrapper(consul.CancelConsulConfig))
+		consulRouter.GET("/changes", resp.JSONOutPutWrapper(consul.GetConsulConfigChanges))
+	}
+	uptimeRouter := r.Group("/edgex_admin/uptime", session.AuthMiddle())
+	{
+		uptimeRouter.GET("/report", resp.JSONOutPutWrapper(uptime.GetUptimeReport))
+		uptimeRouter.GET("/org_report", resp.JSONOutPutWrapper(uptime.GetOrgUptimeReport))
+	}
+	r.GET("/edgex_admin/stats", session.AuthMiddle(), resp.JSONOutPutWrapper(stats.GetFleetStats))
+	eventRouter := r.Group("/edgex_admin/event", session.AuthMiddle())
+	{
+		eventRouter.GET("/stream", stream.StreamEvents)
+		eventRouter.GET("/ws", stream.WebSocketEvents)
+	}
+	notificationRouter := r.Group("/edgex_admin/notification", session.AuthMiddle())
+	{
+		notificationRouter.GET("/list", resp.JSONOutPutWrapper(notification.GetNotificationList))
+		notificationRouter.GET("/count", resp.JSONOutPutWrapper(notification.GetNotificationCount))
+		notificationRouter.POST("/read", resp.JSONOutPutWrapper(notification.ReadNotification))
+		notificationRouter.POST("/read_all", resp.JSONOutPutWrapper(notification.ReadAllNotification))
+		notificationRouter.POST("/delete", resp.JSONOutPutWrapper(notification.DeleteNotification))
+		notificationRouter.GET("/pref", resp.JSONOutPutWrapper(notification.GetNotifyPref))
+		notificationRouter.POST("/pref/save", resp.JSONOutPutWrapper(notification.SaveNotifyPref))
+	}
+	webhookRouter := r.Group("/edgex_admin/webhook", session.AuthMiddle(), session.AdminAuthMiddle())
+	{
+		webhookRouter.GET("/list", resp.JSONOutPutWrapper(webhook.GetWebhookList))
+		webhookRouter.POST("/create", resp.JSONOutPutWrapper(webhook.CreateWebhook))
+		webhookRouter.POST("/update", resp.JSONOutPutWrapper(webhook.UpdateWebhook))
+		webhookRouter.POST("/delete", resp.JSONOutPutWrapper(webhook.DeleteWebhook))
+		webhookRouter.GET("/deliveries", resp.JSONOutPutWrapper(webhook.GetWebhookDeliveryList))
+		webhookRouter.POST("/redeliver", resp.JSONOutPutWrapper(webhook.RedeliverWebhook))
 	}
 	userRouter := r.Group("/edgex_admin/user")
 	{
@@ -28,8 +97,33 @@ func registerRouter(r *gin.Engine) {
 		userRouter.GET("/logout", resp.JSONOutPutWrapper(user.Logout))
 		userRouter.POST("/test/email", resp.JSONOutPutWrapper(user.SendMail))
 		userRouter.POST("/registerCheck", resp.JSONOutPutWrapper(user.RegisterCheck))
-		userRouter.POST("/update/entrypted", resp.JSONOutPutWrapper(user.UpdateUserEntrypted))
-		userRouter.POST("/update/password", resp.JSONOutPutWrapper(user.UpdateUserPassword))
-		userRouter.POST("/entryptedcheck", resp.JSONOutPutWrapper(user.CheckUserEntrypted))
+		userRouter.POST("/password/forgot", resp.JSONOutPutWrapper(user.ForgotPassword))
+		userRouter.POST("/password/reset", resp.JSONOutPutWrapper(user.ResetPassword))
+		userRouter.POST("/phone/code", session.SessionMiddleware(), resp.JSONOutPutWrapper(user.SendPhoneCode))
+		userRouter.POST("/phone/register", resp.JSONOutPutWrapper(user.PhoneRegister))
+		userRouter.POST("/phone/login", session.SessionMiddleware(), resp.JSONOutPutWrapper(user.PhoneLogin))
+		userRouter.POST("/phone/bind", session.AuthSessionMiddle(), resp.JSONOutPutWrapper(user.BindPhone))
+		// 登录第二步及绑定, 接受预认证session
+		userRouter.POST("/2fa/verify", session.SessionMiddleware(), resp.JSONOutPutWrapper(user.VerifyMFA))
+		userRouter.POST("/2fa/enroll", session.SessionMiddleware(), resp.JSONOutPutWrapper(user.EnrollMFA))
+		userRouter.POST("/2fa/confirm", session.SessionMiddleware(), resp.JSONOutPutWrapper(user.ConfirmMFA))
+		userRouter.GET("/2fa/status", session.AuthSessionMiddle(), resp.JSONOutPutWrapper(user.GetMFAStatus))
+		userRouter.POST("/2fa/recovery/regenerate", session.AuthSessionMiddle(), resp.JSONOutPutWrapper(user.RegenerateRecoveryCodes))
+		userRouter.POST("/2fa/disable", session.AuthSessionMiddle(), resp.JSONOutPutWrapper(user.DisableMFA))
+		userRouter.GET("/2fa/policy", session.AuthSessionMiddle(), session.AdminAuthMiddle(), resp.JSONOutPutWrapper(user.GetMFAPolicy))
+		userRouter.POST("/2fa/policy/save", session.AuthSessionMiddle(), session.AdminAuthMiddle(), resp.JSONOutPutWrapper(user.SaveMFAPolicy))
+		userRouter.POST("/2fa/reset", session.AuthSessionMiddle(), session.AdminAuthMiddle(), resp.JSONOutPutWrapper(user.ResetUserMFA))
+		// token只能在登录后管理, 不接受token鉴权
+		userRouter.GET("/token/list", session.AuthSessionMiddle(), resp.JSONOutPutWrapper(user.GetTokenList))
+		userRouter.POST("/token/create", session.AuthSessionMiddle(), resp.JSONOutPutWrapper(user.CreateToken))
+		userRouter.POST("/token/delete", session.AuthSessionMiddle(), resp.JSONOutPutWrapper(user.DeleteToken))
+		userRouter.GET("/security/questions", resp.JSONOutPutWrapper(user.GetSecurityQuestions))
+		userRouter.GET("/security/user_questions", resp.JSONOutPutWrapper(user.GetUserSecurityQuestions))
+		userRouter.POST("/security/verify", resp.JSONOutPutWrapper(user.VerifySecurityAnswers))
+		// 需登录或持有重置token
+		userRouter.POST("/security/mine", session.SessionMiddleware(), resp.JSONOutPutWrapper(user.GetMySecurityQuestions))
+		userRouter.POST("/security/save", session.SessionMiddleware(), resp.JSONOutPutWrapper(user.SaveSecurityAnswers))
+		userRouter.POST("/security/question/save", session.AuthSessionMiddle(), session.AdminAuthMiddle(), resp.JSONOutPutWrapper(user.SaveSecurityQuestion))
+		userRouter.POST("/security/question/delete", session.AuthSessionMiddle(), session.AdminAuthMiddle(), resp.JSONOutPutWrapper(user.DeleteSecurityQuestion))
 	}
 }
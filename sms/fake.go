@@ -0,0 +1,47 @@
+package sms
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+// SentMessage FakeProvider记录的短信
+type SentMessage struct {
+	Phone      string
+	TemplateID string
+	Params     []string
+}
+
+// FakeProvider 不实际发送, 记录并打印日志, 用于开发和测试; 验证码会以明文写入日志, 不要在生产环境使用
+type FakeProvider struct {
+	mu   sync.Mutex
+	sent []*SentMessage
+}
+
+// NewFakeProvider ...
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{sent: make([]*SentMessage, 0)}
+}
+
+// Send ...
+func (p *FakeProvider) Send(phone string, templateID string, params []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, &SentMessage{Phone: phone, TemplateID: templateID, Params: params})
+	logs.Info("[FakeProvider] sms: phone=%v, template=%v, params=%v", phone, templateID, strings.Join(params, ","))
+	return nil
+}
+
+// Last 发送给该号码的最后一条短信, 没有时返回nil
+func (p *FakeProvider) Last(phone string) *SentMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := len(p.sent) - 1; i >= 0; i-- {
+		if p.sent[i].Phone == phone {
+			return p.sent[i]
+		}
+	}
+	return nil
+}
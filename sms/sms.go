@@ -0,0 +1,127 @@
+package sms
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+// 短信服务商, 见[SMS] Provider
+const (
+	ProviderTencent = "tencent" // 腾讯云短信
+	ProviderFake    = "fake"    // 不实际发送, 用于开发和测试
+)
+
+// ErrInvalidPhone 手机号格式错误
+var ErrInvalidPhone = errors.New("phone number is invalid")
+
+// ErrUnknownProvider [SMS] Provider不是tencent/fake
+var ErrUnknownProvider = errors.New("unknown sms provider")
+
+// e164Regexp E.164格式, 如+8613800000000
+var e164Regexp = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// mainlandRegexp 不带国家码的大陆手机号
+var mainlandRegexp = regexp.MustCompile(`^1[3-9][0-9]{9}$`)
+
+// Provider 短信发送方式, params按模板中变量的顺序填写
+type Provider interface {
+	Send(phone string, templateID string, params []string) error
+}
+
+var (
+	providerMu sync.RWMutex
+	provider   Provider
+)
+
+// New 按配置创建Provider, Provider大小写不敏感; 未知的Provider返回ErrUnknownProvider, 避免验证码只写入日志
+func New(conf *config.SMSConfig) (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(conf.Provider)) {
+	case ProviderTencent:
+		return NewTencentProvider(conf), nil
+	case ProviderFake:
+		return NewFakeProvider(), nil
+	}
+	return nil, fmt.Errorf("%w: provider=%q", ErrUnknownProvider, conf.Provider)
+}
+
+// Init 启动时校验[SMS] Provider, 配置错误时返回错误
+func Init() error {
+	_, err := getProvider()
+	return err
+}
+
+// SetProvider 替换默认的Provider, 用于测试
+func SetProvider(p Provider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	provider = p
+}
+
+func getProvider() (Provider, error) {
+	providerMu.RLock()
+	p := provider
+	providerMu.RUnlock()
+	if p != nil {
+		return p, nil
+	}
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if provider == nil {
+		var err error
+		if provider, err = New(getConf()); err != nil {
+			return nil, err
+		}
+	}
+	return provider, nil
+}
+
+// SendCode 使用[SMS] CodeTemplateID发送验证码, 模板变量依次为验证码和有效分钟数
+func SendCode(phone string, code string, minutes int) error {
+	p, err := getProvider()
+	if err == nil {
+		err = p.Send(phone, getConf().CodeTemplateID, []string{code, strconv.Itoa(minutes)})
+	}
+	if err != nil {
+		logs.Error("[sms-SendCode] send sms failed: phone=%v, err=%v", Mask(phone), err)
+	}
+	return err
+}
+
+// NormalizePhone 统一为E.164格式, 不带国家码的大陆手机号补+86
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(phone))
+	if mainlandRegexp.MatchString(phone) {
+		phone = "+86" + phone
+	}
+	if strings.HasPrefix(phone, "00") {
+		phone = "+" + phone[2:]
+	}
+	if !e164Regexp.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
+
+// Mask 日志中隐藏手机号中间的数字
+func Mask(phone string) string {
+	if len(phone) < 8 {
+		return phone
+	}
+	return phone[:len(phone)-8] + "****" + phone[len(phone)-4:]
+}
+
+// getConf 未加载配置时(如测试)使用默认值
+func getConf() *config.SMSConfig {
+	if config.SMSConf == nil {
+		return &config.SMSConfig{}
+	}
+	return config.SMSConf
+}
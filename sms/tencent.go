@@ -0,0 +1,74 @@
+package sms
+
+import (
+	"fmt"
+
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	smsapi "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20190711"
+)
+
+const (
+	defaultRegion  = "ap-guangzhou"
+	defaultTimeout = 10 // 单位：s
+	sendOK         = "Ok"
+)
+
+// TencentProvider 腾讯云短信, 需在控制台创建应用、签名和模板
+type TencentProvider struct {
+	conf *config.SMSConfig
+}
+
+// NewTencentProvider ...
+func NewTencentProvider(conf *config.SMSConfig) *TencentProvider {
+	return &TencentProvider{conf: conf}
+}
+
+// Send ...
+func (p *TencentProvider) Send(phone string, templateID string, params []string) error {
+	client, err := p.client()
+	if err != nil {
+		return err
+	}
+	req := smsapi.NewSendSmsRequest()
+	req.SmsSdkAppid = common.StringPtr(p.conf.AppID)
+	req.Sign = common.StringPtr(p.conf.Sign)
+	req.TemplateID = common.StringPtr(templateID)
+	req.PhoneNumberSet = common.StringPtrs([]string{phone})
+	req.TemplateParamSet = common.StringPtrs(params)
+
+	rsp, err := client.SendSms(req)
+	if err != nil {
+		return err
+	}
+	// 请求成功时每个号码的发送结果在SendStatusSet中
+	if rsp.Response == nil || len(rsp.Response.SendStatusSet) == 0 {
+		return fmt.Errorf("empty send status")
+	}
+	status := rsp.Response.SendStatusSet[0]
+	if status.Code == nil || *status.Code != sendOK {
+		code, message := "", ""
+		if status.Code != nil {
+			code = *status.Code
+		}
+		if status.Message != nil {
+			message = *status.Message
+		}
+		return fmt.Errorf("send sms failed: code=%s, message=%s", code, message)
+	}
+	return nil
+}
+
+func (p *TencentProvider) client() (*smsapi.Client, error) {
+	region := p.conf.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	cpf := profile.NewClientProfile()
+	cpf.HttpProfile.ReqTimeout = defaultTimeout
+	if p.conf.Timeout > 0 {
+		cpf.HttpProfile.ReqTimeout = p.conf.Timeout
+	}
+	return smsapi.NewClient(common.NewCredential(p.conf.SecretID, p.conf.SecretKey), region, cpf)
+}
@@ -0,0 +1,248 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+// 与SearchEdgex的action保持一致
+const (
+	ActionAll    = "all"    // 全部网关
+	ActionMe     = "me"     // 我创建的网关
+	ActionFollow = "follow" // 我关注的网关
+)
+
+// TypeReset 补发的事件超过ReplayLimit时下发, 客户端应重新调用SearchEdgex拉取全量
+const TypeReset = "stream.reset"
+
+const (
+	defaultChannel    = "edgex_admin:events"
+	defaultReplay     = 500
+	defaultBufferSize = 64
+	defaultHeartbeat  = 15 * time.Second
+)
+
+var (
+	clientLock sync.RWMutex
+	clients    = make(map[*Client]struct{})
+	listenOnce sync.Once
+)
+
+// Init 注册outbox订阅者, 将事件发布到redis频道; relay保证每个事件只由一个实例发布
+func Init() {
+	event.Subscribe("stream", publish)
+}
+
+// Start 订阅redis频道, 将事件推送给本实例上的连接
+func Start() {
+	listenOnce.Do(func() {
+		go listen()
+	})
+}
+
+// HeartbeatInterval ...
+func HeartbeatInterval() time.Duration {
+	if config.StreamConf.HeartbeatInterval <= 0 {
+		return defaultHeartbeat
+	}
+	return time.Duration(config.StreamConf.HeartbeatInterval) * time.Second
+}
+
+func channel() string {
+	if config.StreamConf.Channel == "" {
+		return defaultChannel
+	}
+	return config.StreamConf.Channel
+}
+
+func publish(evt *event.Event) error {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return caller.RedisClient.Publish(context.Background(), channel(), payload).Err()
+}
+
+func listen() {
+	defer utils.RecoverPanic()
+
+	// redis断开后go-redis会自动重连并重新订阅
+	pubsub := caller.RedisClient.Subscribe(context.Background(), channel())
+	defer pubsub.Close()
+	for msg := range pubsub.Channel() {
+		evt := &event.Event{}
+		if err := json.Unmarshal([]byte(msg.Payload), evt); err != nil {
+			logs.Error("[stream-listen] decode event failed: payload=%s, err=%v", msg.Payload, err)
+			continue
+		}
+		broadcast(evt)
+	}
+}
+
+func broadcast(evt *event.Event) {
+	clientLock.RLock()
+	defer clientLock.RUnlock()
+	for c := range clients {
+		c.push(evt)
+	}
+}
+
+// Client 一个SSE或WebSocket连接
+type Client struct {
+	UserID  int64
+	IsAdmin bool
+	Action  string
+	Types   []string // 为空时不过滤事件类型
+
+	send      chan *event.Event
+	done      chan struct{}
+	closeOnce sync.Once
+
+	lock      sync.Mutex
+	followMap map[int64]bool
+	replayed  map[int64]bool
+}
+
+// NewClient action为follow时加载当前关注列表, 之后随关注事件更新
+func NewClient(userID int64, isAdmin bool, action string, types []string) (*Client, error) {
+	if action == "" {
+		action = ActionAll
+	}
+	followMap, err := dal.GetFollowMapByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	bufferSize := config.StreamConf.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	return &Client{
+		UserID:    userID,
+		IsAdmin:   isAdmin,
+		Action:    action,
+		Types:     types,
+		send:      make(chan *event.Event, bufferSize),
+		done:      make(chan struct{}),
+		followMap: followMap,
+		replayed:  make(map[int64]bool),
+	}, nil
+}
+
+// Events 实时事件
+func (c *Client) Events() <-chan *event.Event {
+	return c.send
+}
+
+// Done 连接被服务端关闭(如发送缓冲已满)
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close ...
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		clientLock.Lock()
+		delete(clients, c)
+		clientLock.Unlock()
+		close(c.done)
+	})
+}
+
+// Subscribe 注册连接; lastEventID>0时返回需要补发的事件, truncated表示补发不完整
+func (c *Client) Subscribe(lastEventID int64) (replay []*event.Event, truncated bool, err error) {
+	// 先注册再查outbox, 两者之间产生的事件通过replayed去重
+	clientLock.Lock()
+	clients[c] = struct{}{}
+	clientLock.Unlock()
+
+	if lastEventID <= 0 {
+		return nil, false, nil
+	}
+
+	limit := config.StreamConf.ReplayLimit
+	if limit <= 0 {
+		limit = defaultReplay
+	}
+	itemList, err := dal.GetEventOutboxAfter(lastEventID, limit)
+	if err != nil {
+		c.Close()
+		return nil, false, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, item := range itemList {
+		evt := event.FromOutbox(item)
+		c.replayed[evt.ID] = true
+		if c.visible(evt) {
+			replay = append(replay, evt)
+		}
+	}
+	return replay, len(itemList) >= limit, nil
+}
+
+// Duplicated 实时事件是否已在补发中推送过
+func (c *Client) Duplicated(evt *event.Event) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.replayed[evt.ID]
+}
+
+func (c *Client) push(evt *event.Event) {
+	c.lock.Lock()
+	ok := c.visible(evt)
+	c.lock.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case c.send <- evt:
+	default:
+		// 客户端消费过慢, 断开后由客户端携带last_event_id重连补发
+		logs.Warn("[stream-push] client is too slow, close it: user_id=%d", c.UserID)
+		go c.Close()
+	}
+}
+
+// visible 需持有c.lock; 关注事件只推送给管理员和关注者本人
+func (c *Client) visible(evt *event.Event) bool {
+	isFollowEvent := evt.Type == event.TypeEdgexFollowed || evt.Type == event.TypeEdgexUnfollowed
+	if isFollowEvent && evt.UserID == c.UserID {
+		c.followMap[evt.EdgexID] = evt.Type == event.TypeEdgexFollowed
+	}
+
+	if len(c.Types) > 0 && !utils.InStringSlice(evt.Type, c.Types) {
+		return false
+	}
+	if isFollowEvent && !c.IsAdmin && evt.UserID != c.UserID {
+		return false
+	}
+
+	switch c.Action {
+	case ActionMe:
+		return ownerID(evt) == c.UserID
+	case ActionFollow:
+		return c.followMap[evt.EdgexID] || (isFollowEvent && evt.UserID == c.UserID)
+	}
+	return true
+}
+
+// ownerID 网关创建人, 关注事件中不携带
+func ownerID(evt *event.Event) int64 {
+	switch userID := evt.Data["user_id"].(type) {
+	case float64:
+		return int64(userID)
+	case int64:
+		return userID
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package uptime
+
+import (
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+)
+
+const (
+	defaultRawRetention    = 7 * 24 * time.Hour
+	defaultHourlyRetention = 400 * 24 * time.Hour
+)
+
+func rawRetention() time.Duration {
+	if config.UptimeConf.RawRetentionDays <= 0 {
+		return defaultRawRetention
+	}
+	return time.Duration(config.UptimeConf.RawRetentionDays) * 24 * time.Hour
+}
+
+func hourlyRetention() time.Duration {
+	if config.UptimeConf.HourlyRetentionDays <= 0 {
+		return defaultHourlyRetention
+	}
+	return time.Duration(config.UptimeConf.HourlyRetentionDays) * 24 * time.Hour
+}
+
+// Downsample 将超过保留期的原始探测结果按小时聚合后删除, 并清理过期的聚合数据与不可达区间
+func Downsample(now time.Time) error {
+	cutoff := now.Add(-rawRetention()).Truncate(time.Hour)
+	for {
+		oldest, err := dal.GetOldestProbeResult()
+		if err != nil {
+			return err
+		}
+		if oldest == nil || !oldest.ProbeTime.Before(cutoff) {
+			break
+		}
+		if err = downsampleHour(oldest.ProbeTime.Truncate(time.Hour)); err != nil {
+			return err
+		}
+	}
+
+	before := now.Add(-hourlyRetention())
+	if err := dal.DeleteProbeHourlyBefore(before); err != nil {
+		return err
+	}
+	return dal.DeleteOutageBefore(before)
+}
+
+// downsampleHour 聚合[hour, hour+1h)内全部edgex的探测结果, 写入与删除在同一事务中;
+// 该小时已聚合过时(如聚合后又写入了迟到的探测结果)累加到已有的计数上
+func downsampleHour(hour time.Time) (err error) {
+	end := hour.Add(time.Hour)
+	itemList, err := dal.GetProbeResultList(0, hour, end)
+	if err != nil {
+		return
+	}
+
+	hourlyMap := make(map[int64]*dal.EdgexProbeHourly)
+	histoMap := make(map[int64]*Histogram)
+	hourlyList := make([]*dal.EdgexProbeHourly, 0)
+	edgexIDs := make([]int64, 0)
+	for _, item := range itemList {
+		hourly, ok := hourlyMap[item.EdgexID]
+		if !ok {
+			hourly = &dal.EdgexProbeHourly{EdgexID: item.EdgexID, Hour: hour}
+			hourlyMap[item.EdgexID] = hourly
+			histoMap[item.EdgexID] = NewHistogram()
+			hourlyList = append(hourlyList, hourly)
+			edgexIDs = append(edgexIDs, item.EdgexID)
+		}
+		hourly.Samples++
+		if item.Up {
+			hourly.UpSamples++
+			histoMap[item.EdgexID].Add(item.LatencyMs)
+		}
+	}
+
+	db := caller.EdgexDB.Begin()
+	defer func() {
+		if err != nil {
+			db.Rollback()
+		} else {
+			db.Commit()
+		}
+	}()
+	existMap, err := dal.GetProbeHourlyByHour(db, hour, edgexIDs)
+	if err != nil {
+		return
+	}
+	for edgexID, hourly := range hourlyMap {
+		histo := histoMap[edgexID]
+		if exist, ok := existMap[edgexID]; ok {
+			hourly.Samples += exist.Samples
+			hourly.UpSamples += exist.UpSamples
+			histo.Merge(ParseHistogram(exist.LatencyHisto, exist.LatencyMin, exist.LatencyMax, exist.LatencySum))
+		}
+		hourly.LatencySum, hourly.LatencyMin, hourly.LatencyMax = histo.Sum, histo.Min, histo.Max
+		hourly.LatencyHisto = histo.String()
+	}
+	if err = dal.SaveProbeHourly(db, hourlyList); err != nil {
+		return
+	}
+	return dal.DeleteProbeResults(db, hour, end)
+}
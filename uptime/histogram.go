@@ -0,0 +1,118 @@
+package uptime
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// bucketBounds 延迟直方图各桶上界 单位：ms, 最后一个桶为(10000, +inf)
+var bucketBounds = []int64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// Histogram 固定分桶的延迟直方图, 可跨小时合并以估算任意窗口的分位数
+type Histogram struct {
+	Counts []int64
+	Min    int64
+	Max    int64
+	Sum    int64
+}
+
+// NewHistogram ...
+func NewHistogram() *Histogram {
+	return &Histogram{Counts: make([]int64, len(bucketBounds)+1)}
+}
+
+// ParseHistogram 解析EdgexProbeHourly.LatencyHisto
+func ParseHistogram(raw string, min int64, max int64, sum int64) *Histogram {
+	h := NewHistogram()
+	counts := make([]int64, 0)
+	if err := json.Unmarshal([]byte(raw), &counts); err != nil || len(counts) != len(h.Counts) {
+		return h
+	}
+	h.Counts, h.Min, h.Max, h.Sum = counts, min, max, sum
+	return h
+}
+
+// String 各桶计数的JSON数组
+func (h *Histogram) String() string {
+	data, _ := json.Marshal(h.Counts)
+	return string(data)
+}
+
+// Count ...
+func (h *Histogram) Count() int64 {
+	var count int64
+	for _, c := range h.Counts {
+		count += c
+	}
+	return count
+}
+
+// Add ...
+func (h *Histogram) Add(latency int64) {
+	if h.Count() == 0 || latency < h.Min {
+		h.Min = latency
+	}
+	if latency > h.Max {
+		h.Max = latency
+	}
+	h.Sum += latency
+	h.Counts[sort.Search(len(bucketBounds), func(i int) bool { return latency <= bucketBounds[i] })]++
+}
+
+// Merge ...
+func (h *Histogram) Merge(other *Histogram) {
+	if other.Count() == 0 {
+		return
+	}
+	if h.Count() == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+	h.Sum += other.Sum
+	for i := range h.Counts {
+		h.Counts[i] += other.Counts[i]
+	}
+}
+
+// Quantile 在目标桶内线性插值, 结果限制在[Min, Max]
+func (h *Histogram) Quantile(q float64) float64 {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+	rank := q * float64(total)
+	var cumulative int64
+	for i, c := range h.Counts {
+		if c == 0 || float64(cumulative+c) < rank {
+			cumulative += c
+			continue
+		}
+		lower, upper := float64(h.Min), float64(h.Max)
+		if i > 0 && float64(bucketBounds[i-1]) > lower {
+			lower = float64(bucketBounds[i-1])
+		}
+		if i < len(bucketBounds) && float64(bucketBounds[i]) < upper {
+			upper = float64(bucketBounds[i])
+		}
+		return lower + (upper-lower)*(rank-float64(cumulative))/float64(c)
+	}
+	return float64(h.Max)
+}
+
+// exactQuantile 对已排序的样本取nearest-rank分位数
+func exactQuantile(sorted []int64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return float64(sorted[idx])
+}
@@ -0,0 +1,231 @@
+package uptime
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/credential"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/gateway"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/metrics"
+	"github.com/tdycwym/edgex_admin/probe"
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+const (
+	defaultInterval    = time.Minute
+	defaultConcurrency = 16
+	scanBatchSize      = 200
+	downsampleInterval = time.Hour
+	maxErrorLength     = 1000
+)
+
+var startOnce sync.Once
+
+// Start 定时探测全部edgex并降采样历史数据, 多实例部署时每轮只有一个实例执行
+func Start() {
+	if !config.UptimeConf.Enabled {
+		return
+	}
+	startOnce.Do(func() {
+		go loop()
+	})
+}
+
+func interval() time.Duration {
+	if config.UptimeConf.Interval <= 0 {
+		return defaultInterval
+	}
+	return time.Duration(config.UptimeConf.Interval) * time.Second
+}
+
+func loop() {
+	ticker := time.NewTicker(interval())
+	defer ticker.Stop()
+	for range ticker.C {
+		probeRound()
+		downsampleRound()
+	}
+}
+
+func probeRound() {
+	defer utils.RecoverPanic()
+
+	// 锁略短于探测间隔, 保证下一轮可以重新抢占
+	ok, err := caller.TryLock("uptime_probe", interval()-time.Second)
+	if err != nil {
+		logs.Error("[uptime-probeRound] lock failed: err=%v", err)
+		return
+	}
+	if ok {
+		start := time.Now()
+		ProbeAll(context.Background())
+		metrics.ObserveJob("uptime_probe", start, nil)
+	}
+}
+
+func downsampleRound() {
+	defer utils.RecoverPanic()
+
+	ok, err := caller.TryLock("uptime_downsample", downsampleInterval)
+	if err != nil || !ok {
+		return
+	}
+	start := time.Now()
+	err = Downsample(start)
+	metrics.ObserveJob("uptime_downsample", start, err)
+	if err != nil {
+		logs.Error("[uptime-downsampleRound] downsample failed: err=%v", err)
+	}
+}
+
+// ProbeAll 并发探测全部未删除的edgex
+func ProbeAll(ctx context.Context) {
+	timeout := time.Duration(config.ProbeConf.Timeout) * time.Millisecond
+	concurrency := config.UptimeConf.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	var lastID int64
+	for {
+		edgexList, err := dal.ScanEdgex(lastID, scanBatchSize)
+		if err != nil || len(edgexList) == 0 {
+			break
+		}
+		edgexIDs := make([]int64, 0, len(edgexList))
+		for _, item := range edgexList {
+			edgexIDs = append(edgexIDs, item.ID)
+		}
+		credMap, err := dal.GetCredentialMapByEdgexIDs(edgexIDs)
+		if err != nil {
+			break
+		}
+		for _, item := range edgexList {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(item *dal.EdgexServiceItem, credItem *dal.EdgexCredential) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				defer utils.RecoverPanic()
+				// 凭证无法解密或TLS选项无效时跳过, 避免误判为不可达
+				prober, err := newProber(item, credItem, timeout)
+				if err != nil {
+					logs.Error("[uptime-ProbeAll] build prober failed: edgex_id=%d, err=%v", item.ID, err)
+					return
+				}
+				if err := Check(ctx, prober, item); err != nil {
+					logs.Error("[uptime-ProbeAll] check failed: edgex_id=%d, err=%v", item.ID, err)
+				}
+			}(item, credMap[item.ID])
+		}
+		lastID = edgexList[len(edgexList)-1].ID
+	}
+	wg.Wait()
+}
+
+// newProber 按edgex的TLS选项与凭证构造探测器, 连接池由gateway按edgex复用
+func newProber(item *dal.EdgexServiceItem, credItem *dal.EdgexCredential, timeout time.Duration) (*probe.Prober, error) {
+	var cred *credential.Credential
+	if credItem != nil {
+		var err error
+		cred, err = credential.Decrypt(credItem)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return gateway.Prober(item, cred, timeout)
+}
+
+// Check 探测一个edgex并记录结果; 可达性变化时更新status、维护不可达区间并发出status_changed事件
+func Check(ctx context.Context, prober *probe.Prober, item *dal.EdgexServiceItem) (err error) {
+	now := time.Now()
+	report := prober.Probe(ctx, gateway.Address(item))
+	if report.TLS != nil {
+		saveServerCert(item.ID, report.TLS, now)
+	}
+
+	result := &dal.EdgexProbeResult{
+		EdgexID:   item.ID,
+		ProbeTime: now,
+		Up:        report.Reachable,
+		LatencyMs: report.LatencyMs,
+		Error:     report.Error(),
+	}
+	if report.Ping != nil {
+		result.LatencyMs = report.Ping.LatencyMs
+	}
+	metrics.ObserveProbe(result.Up, result.LatencyMs)
+	if len(result.Error) > maxErrorLength {
+		result.Error = result.Error[:maxErrorLength]
+	}
+	err = dal.AddProbeResult(caller.EdgexDB, result)
+	if err != nil {
+		return
+	}
+
+	status := int32(dal.EdgexInactive)
+	if result.Up {
+		status = dal.EdgexActive
+	}
+	outage, err := dal.GetOpenOutage(item.ID)
+	if err != nil {
+		return
+	}
+	needOpen := !result.Up && outage == nil
+	needClose := result.Up && outage != nil
+	if status == item.Status && !needOpen && !needClose {
+		return nil
+	}
+
+	db := caller.EdgexDB.Begin()
+	defer func() {
+		if err != nil {
+			db.Rollback()
+		} else {
+			db.Commit()
+		}
+	}()
+
+	switch {
+	case needOpen:
+		err = dal.AddOutage(db, &dal.EdgexOutage{EdgexID: item.ID, StartTime: now, Error: result.Error})
+	case needClose:
+		err = dal.CloseOutage(db, item.ID, now)
+	}
+	if err != nil || status == item.Status {
+		return
+	}
+
+	// 以原状态为条件更新, 并发修改时只有一方发出事件
+	updated, err := dal.UpdateEdgexStatus(db, item.ID, item.Status, status)
+	if err != nil || !updated {
+		return
+	}
+	data := event.EdgexUpdateData(item, map[string]interface{}{"status": status})
+	data["latency_ms"] = result.LatencyMs
+	data["error"] = result.Error
+	err = event.Emit(db, event.TypeEdgexStatusChanged, item.ID, 0, data)
+	return
+}
+
+// saveServerCert 记录网关证书以跟踪过期时间, 失败不影响探测结果
+func saveServerCert(edgexID int64, info *probe.TLSInfo, now time.Time) {
+	_ = dal.SaveEdgexCert(caller.EdgexDB, &dal.EdgexCert{
+		EdgexID:     edgexID,
+		Kind:        dal.CertKindServer,
+		Subject:     info.Subject,
+		Issuer:      info.Issuer,
+		NotAfter:    info.NotAfter,
+		CheckedTime: now,
+	})
+}
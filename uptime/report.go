@@ -0,0 +1,195 @@
+package uptime
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/model"
+)
+
+// latencySamples 窗口内可达样本的延迟, 原始数据保留明细, 聚合数据只有直方图
+type latencySamples struct {
+	raw   []int64
+	histo *Histogram
+}
+
+func newLatencySamples() *latencySamples {
+	return &latencySamples{histo: NewHistogram()}
+}
+
+func (s *latencySamples) merge(other *latencySamples) {
+	s.raw = append(s.raw, other.raw...)
+	s.histo.Merge(other.histo)
+}
+
+// stats 只有原始数据时计算精确分位数, 否则将明细并入直方图估算
+func (s *latencySamples) stats() *model.LatencyStats {
+	if s.histo.Count() == 0 {
+		if len(s.raw) == 0 {
+			return &model.LatencyStats{Exact: true}
+		}
+		sorted := append([]int64(nil), s.raw...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var sum int64
+		for _, latency := range sorted {
+			sum += latency
+		}
+		return &model.LatencyStats{
+			Samples: int64(len(sorted)),
+			Min:     sorted[0],
+			Max:     sorted[len(sorted)-1],
+			Avg:     float64(sum) / float64(len(sorted)),
+			P50:     exactQuantile(sorted, 0.50),
+			P90:     exactQuantile(sorted, 0.90),
+			P95:     exactQuantile(sorted, 0.95),
+			P99:     exactQuantile(sorted, 0.99),
+			Exact:   true,
+		}
+	}
+
+	histo := NewHistogram()
+	histo.Merge(s.histo)
+	for _, latency := range s.raw {
+		histo.Add(latency)
+	}
+	count := histo.Count()
+	return &model.LatencyStats{
+		Samples: count,
+		Min:     histo.Min,
+		Max:     histo.Max,
+		Avg:     float64(histo.Sum) / float64(count),
+		P50:     histo.Quantile(0.50),
+		P90:     histo.Quantile(0.90),
+		P95:     histo.Quantile(0.95),
+		P99:     histo.Quantile(0.99),
+	}
+}
+
+// Report edgex在[start, end)内的可用性; 可用率按时间计算: 1 - 不可达时长/监控时长
+func Report(item *dal.EdgexServiceItem, start time.Time, end time.Time) (*model.UptimeReport, error) {
+	report, _, err := buildReport(item, start, end)
+	return report, err
+}
+
+// OrgReport 组织下全部edgex的可用性, 包括窗口内被删除的edgex
+func OrgReport(orgID int64, start time.Time, end time.Time) (*model.OrgUptimeReport, error) {
+	edgexList, err := dal.GetEdgexListByOrgID(orgID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	orgReport := &model.OrgUptimeReport{
+		OrgID:         orgID,
+		Start:         start.Unix(),
+		End:           end.Unix(),
+		UptimePercent: 100,
+		EdgexList:     make([]*model.UptimeReport, 0, len(edgexList)),
+	}
+	latency := newLatencySamples()
+	for _, item := range edgexList {
+		report, samples, err := buildReport(item, start, end)
+		if err != nil {
+			return nil, err
+		}
+		orgReport.MonitoredSeconds += report.MonitoredSeconds
+		orgReport.DowntimeSeconds += report.DowntimeSeconds
+		orgReport.OutageCount += len(report.Outages)
+		orgReport.EdgexList = append(orgReport.EdgexList, report)
+		latency.merge(samples)
+	}
+	orgReport.UptimePercent = uptimePercent(orgReport.MonitoredSeconds, orgReport.DowntimeSeconds)
+	orgReport.Latency = latency.stats()
+	return orgReport, nil
+}
+
+func buildReport(item *dal.EdgexServiceItem, start time.Time, end time.Time) (*model.UptimeReport, *latencySamples, error) {
+	report := &model.UptimeReport{
+		EdgexID:       item.ID,
+		EdgexName:     item.EdgexName,
+		Prefix:        item.Prefix,
+		OrgID:         item.OrgID,
+		Start:         start.Unix(),
+		End:           end.Unix(),
+		UptimePercent: 100,
+		Outages:       make([]*model.OutageInfo, 0),
+	}
+	latency := newLatencySamples()
+
+	// 监控时长: 窗口与edgex存续期(创建到删除/当前)的交集
+	from, to := start, end
+	if item.CreatedTime.After(from) {
+		from = item.CreatedTime
+	}
+	if now := time.Now(); now.Before(to) {
+		to = now
+	}
+	if item.Deleted != 0 && item.ModifiedTime.Before(to) {
+		to = item.ModifiedTime
+	}
+	if !from.Before(to) {
+		report.Latency = latency.stats()
+		return report, latency, nil
+	}
+	report.MonitoredSeconds = int64(to.Sub(from).Seconds())
+
+	// Step1. 不可达区间
+	outageList, err := dal.GetOutageList(item.ID, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, outage := range outageList {
+		outageStart, outageEnd := outage.StartTime, to
+		if outage.EndTime != nil && outage.EndTime.Before(to) {
+			outageEnd = *outage.EndTime
+		}
+		if outageStart.Before(from) {
+			outageStart = from
+		}
+		info := &model.OutageInfo{
+			StartTime: outageStart.Unix(),
+			Duration:  int64(outageEnd.Sub(outageStart).Seconds()),
+			Error:     outage.Error,
+		}
+		if outage.EndTime != nil {
+			info.EndTime = outageEnd.Unix()
+		}
+		report.DowntimeSeconds += info.Duration
+		report.Outages = append(report.Outages, info)
+	}
+	report.UptimePercent = uptimePercent(report.MonitoredSeconds, report.DowntimeSeconds)
+
+	// Step2. 探测样本, 降采样后的小时数据与原始数据时间上不重叠
+	hourlyList, err := dal.GetProbeHourlyList(item.ID, from.Truncate(time.Hour), to)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, hourly := range hourlyList {
+		report.Samples += hourly.Samples
+		report.UpSamples += hourly.UpSamples
+		latency.histo.Merge(ParseHistogram(hourly.LatencyHisto, hourly.LatencyMin, hourly.LatencyMax, hourly.LatencySum))
+	}
+	resultList, err := dal.GetProbeResultList(item.ID, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, result := range resultList {
+		report.Samples++
+		if result.Up {
+			report.UpSamples++
+			latency.raw = append(latency.raw, result.LatencyMs)
+		}
+	}
+	report.Latency = latency.stats()
+	return report, latency, nil
+}
+
+func uptimePercent(monitored int64, downtime int64) float64 {
+	if monitored <= 0 {
+		return 100
+	}
+	if downtime > monitored {
+		downtime = monitored
+	}
+	return 100 * float64(monitored-downtime) / float64(monitored)
+}
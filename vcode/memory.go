@@ -0,0 +1,84 @@
+package vcode
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore 单进程内存实现, 用于测试
+type MemoryStore struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	codes    map[string]*memoryCode
+	counters map[string]*memoryCounter
+}
+
+type memoryCode struct {
+	code     string
+	attempts int
+	expireAt time.Time
+}
+
+type memoryCounter struct {
+	count    int64
+	expireAt time.Time
+}
+
+// NewMemoryStore ...
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:      time.Now,
+		codes:    make(map[string]*memoryCode),
+		counters: make(map[string]*memoryCounter),
+	}
+}
+
+// SetClock 替换时间来源, 便于测试过期
+func (s *MemoryStore) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Save ...
+func (s *MemoryStore) Save(key string, code string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[key] = &memoryCode{code: code, expireAt: s.now().Add(ttl)}
+	return nil
+}
+
+// Check ...
+func (s *MemoryStore) Check(key string, code string, maxAttempts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.codes[key]
+	if !ok || !s.now().Before(item.expireAt) {
+		delete(s.codes, key)
+		return ErrNotFound
+	}
+	if item.code == code {
+		delete(s.codes, key)
+		return nil
+	}
+	item.attempts++
+	if item.attempts >= maxAttempts {
+		delete(s.codes, key)
+		return ErrTooManyAttempts
+	}
+	return ErrMismatch
+}
+
+// Incr ...
+func (s *MemoryStore) Incr(key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	item, ok := s.counters[key]
+	if !ok || !now.Before(item.expireAt) {
+		item = &memoryCounter{expireAt: now.Add(window)}
+		s.counters[key] = item
+	}
+	item.count++
+	return item.count, nil
+}
@@ -0,0 +1,86 @@
+package vcode
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/tdycwym/edgex_admin/caller"
+)
+
+// checkScript 返回 0-匹配 1-不匹配 2-不存在 3-错误次数达到上限
+var checkScript = redis.NewScript(`
+local code = redis.call('HGET', KEYS[1], 'code')
+if not code then
+	return 2
+end
+if code == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts >= tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+	return 3
+end
+return 1
+`)
+
+// RedisStore 多实例共享, 重启不丢失
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore client为nil时使用caller.RedisClient
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) redis() *redis.Client {
+	if s.client != nil {
+		return s.client
+	}
+	return caller.RedisClient
+}
+
+// Save ...
+func (s *RedisStore) Save(key string, code string, ttl time.Duration) error {
+	ctx := context.Background()
+	_, err := s.redis().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, "code", code, "attempts", 0)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// Check ...
+func (s *RedisStore) Check(key string, code string, maxAttempts int) error {
+	res, err := checkScript.Run(context.Background(), s.redis(), []string{key}, code, maxAttempts).Int()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case 0:
+		return nil
+	case 2:
+		return ErrNotFound
+	case 3:
+		return ErrTooManyAttempts
+	}
+	return ErrMismatch
+}
+
+// Incr ...
+func (s *RedisStore) Incr(key string, window time.Duration) (int64, error) {
+	ctx := context.Background()
+	count, err := s.redis().Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		s.redis().Expire(ctx, key, window)
+	}
+	return count, nil
+}
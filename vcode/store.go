@@ -0,0 +1,25 @@
+package vcode
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound 验证码不存在、已使用或已过期
+	ErrNotFound = errors.New("verification code is not found or expired")
+	// ErrMismatch 验证码错误
+	ErrMismatch = errors.New("verification code mismatch")
+	// ErrTooManyAttempts 错误次数达到上限, 验证码已作废
+	ErrTooManyAttempts = errors.New("too many verification attempts")
+)
+
+// Store 验证码及发送计数的存储, 默认使用redis, 测试时可替换为内存实现
+type Store interface {
+	// Save 保存验证码, 覆盖同一key之前的验证码及错误次数
+	Save(key string, code string, ttl time.Duration) error
+	// Check 比对验证码, 成功或错误次数达到maxAttempts时删除
+	Check(key string, code string, maxAttempts int) error
+	// Incr 计数加一并返回当前值, 计数从第一次开始window后过期
+	Incr(key string, window time.Duration) (int64, error)
+}
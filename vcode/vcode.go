@@ -0,0 +1,165 @@
+package vcode
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+// 验证码的使用场景, 不同场景的验证码互不通用
+const (
+	SceneRegister = "register"
+	SceneTest     = "test"
+
+	ScenePhoneRegister = "phone_register"
+	ScenePhoneLogin    = "phone_login"
+	ScenePhoneBind     = "phone_bind"
+)
+
+const (
+	codeKeyFmt     = "edgex_admin:vcode:%s:%s"
+	intervalKeyFmt = "edgex_admin:vcode_interval:%s"
+	dailyKeyFmt    = "edgex_admin:vcode_daily:%s"
+	ipKeyFmt       = "edgex_admin:vcode_ip:%s"
+
+	defaultTTL         = 10 * time.Minute
+	defaultLength      = 6
+	defaultMaxAttempts = 5
+)
+
+// ErrTooFrequent 超过发送频率限制
+var ErrTooFrequent = errors.New("verification code requested too frequently")
+
+var (
+	storeMu sync.RWMutex
+	store   Store = NewRedisStore(nil)
+)
+
+// SetStore 替换存储实现, 用于测试
+func SetStore(s Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	store = s
+}
+
+func getStore() Store {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return store
+}
+
+// Send 校验频率限制后生成验证码并通过deliver发送; target为邮箱或手机号, ip为空时不做ip限制
+func Send(scene string, target string, ip string, deliver func(code string) error) error {
+	target = normalize(target)
+	if err := checkRate(target, ip); err != nil {
+		return err
+	}
+	code, err := generate(length())
+	if err != nil {
+		return err
+	}
+	if err = getStore().Save(fmt.Sprintf(codeKeyFmt, scene, target), code, ttl()); err != nil {
+		logs.Error("[vcode-Send] save code failed: scene=%v, target=%v, err=%v", scene, target, err)
+		return err
+	}
+	return deliver(code)
+}
+
+// Verify 校验成功后验证码立即失效; 错误达到MaxAttempts次后验证码作废, 需重新发送
+func Verify(scene string, target string, code string) error {
+	if code == "" {
+		return ErrMismatch
+	}
+	return getStore().Check(fmt.Sprintf(codeKeyFmt, scene, normalize(target)), strings.TrimSpace(code), maxAttempts())
+}
+
+// TTL 验证码有效期
+func TTL() time.Duration {
+	return ttl()
+}
+
+// checkRate 同一目标SendInterval秒内一次、每天TargetDailyLimit次, 同一ip每小时IPHourlyLimit次, 配置为0时不限制
+func checkRate(target string, ip string) error {
+	conf := getConf()
+	if ip != "" && conf.IPHourlyLimit > 0 {
+		count, err := getStore().Incr(fmt.Sprintf(ipKeyFmt, ip), time.Hour)
+		if err != nil {
+			return err
+		}
+		if count > int64(conf.IPHourlyLimit) {
+			return ErrTooFrequent
+		}
+	}
+	if conf.SendInterval > 0 {
+		count, err := getStore().Incr(fmt.Sprintf(intervalKeyFmt, target), time.Duration(conf.SendInterval)*time.Second)
+		if err != nil {
+			return err
+		}
+		if count > 1 {
+			return ErrTooFrequent
+		}
+	}
+	if conf.TargetDailyLimit > 0 {
+		count, err := getStore().Incr(fmt.Sprintf(dailyKeyFmt, target), 24*time.Hour)
+		if err != nil {
+			return err
+		}
+		if count > int64(conf.TargetDailyLimit) {
+			return ErrTooFrequent
+		}
+	}
+	return nil
+}
+
+// generate 使用crypto/rand生成定长数字验证码
+func generate(n int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte('0' + digit.Int64()))
+	}
+	return b.String(), nil
+}
+
+func normalize(target string) string {
+	return strings.ToLower(strings.TrimSpace(target))
+}
+
+func ttl() time.Duration {
+	if getConf().TTL <= 0 {
+		return defaultTTL
+	}
+	return time.Duration(getConf().TTL) * time.Second
+}
+
+func length() int {
+	if getConf().Length < 4 || getConf().Length > 10 {
+		return defaultLength
+	}
+	return getConf().Length
+}
+
+func maxAttempts() int {
+	if getConf().MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return getConf().MaxAttempts
+}
+
+// getConf 未加载配置时(如测试)使用默认值
+func getConf() *config.VerifyCodeConfig {
+	if config.VerifyCodeConf == nil {
+		return &config.VerifyCodeConfig{}
+	}
+	return config.VerifyCodeConf
+}
@@ -0,0 +1,220 @@
+package vcode
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/config"
+)
+
+// fakeClock 手动推进的时间
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// setup 使用内存存储和给定配置, 测试结束后恢复
+func setup(t *testing.T, conf *config.VerifyCodeConfig) *fakeClock {
+	clock := &fakeClock{now: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)}
+	memStore := NewMemoryStore()
+	memStore.SetClock(clock.Now)
+	SetStore(memStore)
+	oldConf := config.VerifyCodeConf
+	config.VerifyCodeConf = conf
+	t.Cleanup(func() {
+		SetStore(NewRedisStore(nil))
+		config.VerifyCodeConf = oldConf
+	})
+	return clock
+}
+
+// send 发送验证码并返回发送的内容
+func send(scene string, target string, ip string) (string, error) {
+	var sent string
+	err := Send(scene, target, ip, func(code string) error {
+		sent = code
+		return nil
+	})
+	return sent, err
+}
+
+func TestSendVerify(t *testing.T) {
+	setup(t, &config.VerifyCodeConfig{Length: 8})
+
+	code, err := send(SceneRegister, " User@Example.com ", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 8 {
+		t.Fatalf("code = %q, want 8 digits", code)
+	}
+	// 不同场景的验证码互不通用
+	if err = Verify(SceneTest, "user@example.com", code); err != ErrNotFound {
+		t.Fatalf("other scene: err=%v, want ErrNotFound", err)
+	}
+	if err = Verify(SceneRegister, "USER@example.com", " "+code+" "); err != nil {
+		t.Fatalf("verify: err=%v", err)
+	}
+}
+
+func TestVerifySingleUse(t *testing.T) {
+	setup(t, &config.VerifyCodeConfig{})
+
+	code, err := send(ScenePhoneLogin, "13800000000", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify(ScenePhoneLogin, "13800000000", code); err != nil {
+		t.Fatalf("first verify: err=%v", err)
+	}
+	if err = Verify(ScenePhoneLogin, "13800000000", code); err != ErrNotFound {
+		t.Fatalf("second verify: err=%v, want ErrNotFound", err)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	clock := setup(t, &config.VerifyCodeConfig{TTL: 60})
+
+	code, err := send(ScenePhoneLogin, "13800000000", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(59 * time.Second)
+	if err = Verify(ScenePhoneLogin, "13800000000", "wrong"); err != ErrMismatch {
+		t.Fatalf("before expiry: err=%v, want ErrMismatch", err)
+	}
+	clock.Add(time.Second)
+	if err = Verify(ScenePhoneLogin, "13800000000", code); err != ErrNotFound {
+		t.Fatalf("after expiry: err=%v, want ErrNotFound", err)
+	}
+}
+
+func TestVerifyMaxAttempts(t *testing.T) {
+	setup(t, &config.VerifyCodeConfig{MaxAttempts: 3})
+
+	code, err := send(ScenePhoneLogin, "13800000000", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "x" + code
+	for i := 1; i < 3; i++ {
+		if err = Verify(ScenePhoneLogin, "13800000000", wrong); err != ErrMismatch {
+			t.Fatalf("attempt %d: err=%v, want ErrMismatch", i, err)
+		}
+	}
+	if err = Verify(ScenePhoneLogin, "13800000000", wrong); err != ErrTooManyAttempts {
+		t.Fatalf("attempt 3: err=%v, want ErrTooManyAttempts", err)
+	}
+	// 作废后正确的验证码也不可用
+	if err = Verify(ScenePhoneLogin, "13800000000", code); err != ErrNotFound {
+		t.Fatalf("after max attempts: err=%v, want ErrNotFound", err)
+	}
+	if err = Verify(ScenePhoneLogin, "13800000000", ""); err != ErrMismatch {
+		t.Fatalf("empty code: err=%v, want ErrMismatch", err)
+	}
+}
+
+func TestResendResetsAttempts(t *testing.T) {
+	setup(t, &config.VerifyCodeConfig{MaxAttempts: 2})
+
+	first, err := send(ScenePhoneLogin, "13800000000", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify(ScenePhoneLogin, "13800000000", "x"+first); err != ErrMismatch {
+		t.Fatalf("err=%v, want ErrMismatch", err)
+	}
+	second, err := send(ScenePhoneLogin, "13800000000", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify(ScenePhoneLogin, "13800000000", "x"+second); err != ErrMismatch {
+		t.Fatalf("after resend: err=%v, want ErrMismatch", err)
+	}
+	if err = Verify(ScenePhoneLogin, "13800000000", second); err != nil {
+		t.Fatalf("after resend: err=%v", err)
+	}
+}
+
+func TestSendInterval(t *testing.T) {
+	clock := setup(t, &config.VerifyCodeConfig{SendInterval: 60})
+
+	if _, err := send(ScenePhoneLogin, "13800000000", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := send(ScenePhoneLogin, "13800000000", ""); err != ErrTooFrequent {
+		t.Fatalf("err=%v, want ErrTooFrequent", err)
+	}
+	// 其他目标不受影响
+	if _, err := send(ScenePhoneLogin, "13800000001", ""); err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(60 * time.Second)
+	if _, err := send(ScenePhoneLogin, "13800000000", ""); err != nil {
+		t.Fatalf("after interval: err=%v", err)
+	}
+}
+
+func TestTargetDailyLimit(t *testing.T) {
+	clock := setup(t, &config.VerifyCodeConfig{TargetDailyLimit: 3})
+
+	for i := 0; i < 3; i++ {
+		if _, err := send(ScenePhoneLogin, "13800000000", ""); err != nil {
+			t.Fatalf("send %d: err=%v", i+1, err)
+		}
+	}
+	if _, err := send(ScenePhoneLogin, "13800000000", ""); err != ErrTooFrequent {
+		t.Fatalf("err=%v, want ErrTooFrequent", err)
+	}
+	clock.Add(24 * time.Hour)
+	if _, err := send(ScenePhoneLogin, "13800000000", ""); err != nil {
+		t.Fatalf("next day: err=%v", err)
+	}
+}
+
+func TestIPHourlyLimit(t *testing.T) {
+	clock := setup(t, &config.VerifyCodeConfig{IPHourlyLimit: 2})
+
+	for i := 0; i < 2; i++ {
+		if _, err := send(ScenePhoneLogin, fmt.Sprintf("1380000000%d", i), "1.2.3.4"); err != nil {
+			t.Fatalf("send %d: err=%v", i+1, err)
+		}
+	}
+	if _, err := send(ScenePhoneLogin, "13800000009", "1.2.3.4"); err != ErrTooFrequent {
+		t.Fatalf("err=%v, want ErrTooFrequent", err)
+	}
+	if _, err := send(ScenePhoneLogin, "13800000009", "5.6.7.8"); err != nil {
+		t.Fatalf("other ip: err=%v", err)
+	}
+	// ip为空时不限制
+	if _, err := send(ScenePhoneLogin, "13800000008", ""); err != nil {
+		t.Fatalf("empty ip: err=%v", err)
+	}
+	clock.Add(time.Hour)
+	if _, err := send(ScenePhoneLogin, "13800000009", "1.2.3.4"); err != nil {
+		t.Fatalf("next hour: err=%v", err)
+	}
+}
+
+func TestSendDeliverError(t *testing.T) {
+	setup(t, &config.VerifyCodeConfig{})
+
+	want := fmt.Errorf("sms gateway down")
+	if err := Send(ScenePhoneLogin, "13800000000", "", func(string) error { return want }); err != want {
+		t.Fatalf("err=%v, want %v", err, want)
+	}
+}
@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+	"github.com/tdycwym/edgex_admin/metrics"
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+const (
+	deliverInterval    = time.Second
+	deliverBatchSize   = 50
+	deliverConcurrency = 8
+	maxResponseSize    = 1024
+)
+
+var deliverOnce sync.Once
+
+// Start 启动投递协程
+func Start() {
+	deliverOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(deliverInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				deliverBatch()
+			}
+		}()
+	})
+}
+
+func deliverBatch() {
+	defer utils.RecoverPanic()
+
+	timeout := time.Duration(config.HookConf.Timeout) * time.Millisecond
+	owner := uuid.NewV4().String()
+	// 锁定时长需覆盖一批投递的最长耗时
+	itemList, err := dal.ClaimWebhookDeliveries(owner, 2*timeout+time.Minute, deliverBatchSize)
+	if err != nil || len(itemList) == 0 {
+		return
+	}
+
+	subMap := make(map[int64]*dal.WebhookSubscription)
+	for _, item := range itemList {
+		if _, ok := subMap[item.SubscriptionID]; ok {
+			continue
+		}
+		subMap[item.SubscriptionID], _ = dal.GetWebhookSubscriptionByID(item.SubscriptionID)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	sem := make(chan struct{}, deliverConcurrency)
+	wg := sync.WaitGroup{}
+	for _, item := range itemList {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item *dal.WebhookDelivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			defer utils.RecoverPanic()
+			Deliver(client, subMap[item.SubscriptionID], item)
+		}(item)
+	}
+	wg.Wait()
+}
+
+// Deliver 投递一次并记录结果, 失败时按指数退避安排下一次重试
+func Deliver(client *http.Client, sub *dal.WebhookSubscription, item *dal.WebhookDelivery) {
+	_ = dal.UpdateWebhookDelivery(caller.EdgexDB, item.ID, attempt(client, sub, item))
+}
+
+// attempt 投递一次, 返回需要更新到投递记录的字段
+func attempt(client *http.Client, sub *dal.WebhookSubscription, item *dal.WebhookDelivery) map[string]interface{} {
+	attempts := item.Attempts + 1
+	fieldsMap := map[string]interface{}{
+		"attempts":      attempts,
+		"modified_time": time.Now(),
+	}
+
+	if sub == nil || sub.Status != dal.WebhookEnabled {
+		fieldsMap["status"] = dal.DeliveryStatusFailed
+		fieldsMap["last_error"] = "subscription is deleted or disabled"
+		return fieldsMap
+	}
+
+	start := time.Now()
+	statusCode, body, err := post(client, sub, item)
+	fieldsMap["last_latency_ms"] = time.Since(start).Milliseconds()
+	fieldsMap["last_status_code"] = statusCode
+	fieldsMap["last_response"] = body
+	fieldsMap["last_error"] = ""
+	if err == nil && statusCode/100 != 2 {
+		err = fmt.Errorf("unexpected status code %d", statusCode)
+	}
+
+	switch {
+	case err == nil:
+		fieldsMap["status"] = dal.DeliveryStatusSuccess
+		metrics.ObserveWebhook("success")
+	case int(attempts) >= config.HookConf.MaxAttempts:
+		fieldsMap["status"] = dal.DeliveryStatusFailed
+		fieldsMap["last_error"] = err.Error()
+		metrics.ObserveWebhook("failed")
+	default:
+		fieldsMap["status"] = dal.DeliveryStatusPending
+		fieldsMap["last_error"] = err.Error()
+		fieldsMap["next_attempt_time"] = time.Now().Add(Backoff(int(attempts)))
+		metrics.ObserveWebhook("retry")
+	}
+	if err != nil {
+		logs.Warn("[webhook-Deliver] deliver failed: delivery_id=%d, url=%s, attempts=%d, err=%v",
+			item.ID, sub.URL, attempts, err)
+	}
+	return fieldsMap
+}
+
+func post(client *http.Client, sub *dal.WebhookSubscription, item *dal.WebhookDelivery) (statusCode int, body string, err error) {
+	payload := []byte(item.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "edgex-admin-webhook")
+	req.Header.Set(HeaderEvent, item.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(item.ID, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, payload))
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer rsp.Body.Close()
+	rawBody, _ := ioutil.ReadAll(io.LimitReader(rsp.Body, maxResponseSize))
+	return rsp.StatusCode, string(rawBody), nil
+}
+
+// Backoff 第attempts次失败后的重试间隔: BaseBackoff * 2^(attempts-1), 不超过MaxBackoff
+func Backoff(attempts int) time.Duration {
+	base := time.Duration(config.HookConf.BaseBackoff) * time.Second
+	maxBackoff := time.Duration(config.HookConf.MaxBackoff) * time.Second
+	if base <= 0 {
+		base = time.Second
+	}
+	backoff := base
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if maxBackoff > 0 && backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/caller"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/event"
+	"github.com/tdycwym/edgex_admin/utils"
+)
+
+// 投递请求头
+const (
+	HeaderEvent     = "X-Edgex-Event"
+	HeaderDelivery  = "X-Edgex-Delivery"
+	HeaderTimestamp = "X-Edgex-Timestamp"
+	HeaderSignature = "X-Edgex-Signature"
+)
+
+// EventAll 订阅全部事件
+const EventAll = "*"
+
+// Sign 签名内容为 "{timestamp}.{body}", 结果形如 sha256=<hex>
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret 未指定secret时随机生成
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Verify 接收方校验签名, 供对接方参考
+func Verify(secret string, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// ParseEvents 校验并规范化事件过滤器, 空表示全部
+func ParseEvents(raw string) ([]string, bool) {
+	eventList := make([]string, 0)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if item == EventAll {
+			return []string{EventAll}, true
+		}
+		if !utils.InStringSlice(item, event.AllTypes) {
+			return nil, false
+		}
+		if !utils.InStringSlice(item, eventList) {
+			eventList = append(eventList, item)
+		}
+	}
+	if len(eventList) == 0 {
+		return []string{EventAll}, true
+	}
+	return eventList, true
+}
+
+// Match 订阅的事件过滤器是否包含eventType
+func Match(sub *dal.WebhookSubscription, eventType string) bool {
+	for _, item := range strings.Split(sub.Events, ",") {
+		if item == EventAll || item == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Init 注册outbox订阅者, 将事件展开为每个匹配订阅的投递记录
+func Init() {
+	event.Subscribe("webhook", fanout)
+}
+
+func fanout(evt *event.Event) error {
+	subList, err := dal.GetWebhookSubscriptionList(dal.WebhookEnabled)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	deliveryList := make([]*dal.WebhookDelivery, 0)
+	for _, sub := range subList {
+		if !Match(sub, evt.Type) {
+			continue
+		}
+		deliveryList = append(deliveryList, &dal.WebhookDelivery{
+			SubscriptionID:  sub.ID,
+			EventID:         evt.ID,
+			EventType:       evt.Type,
+			Payload:         string(payload),
+			Status:          dal.DeliveryStatusPending,
+			NextAttemptTime: time.Now(),
+			CreatedTime:     time.Now(),
+			ModifiedTime:    time.Now(),
+		})
+	}
+	return dal.AddWebhookDeliveries(caller.EdgexDB, deliveryList)
+}
+
+// Redeliver 手动重新投递, 重置重试次数
+func Redeliver(deliveryID int64) error {
+	return dal.UpdateWebhookDelivery(caller.EdgexDB, deliveryID, redeliverFields(time.Now()))
+}
+
+// redeliverFields 重置为待投递, 重新计算重试次数
+func redeliverFields(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"status":            dal.DeliveryStatusPending,
+		"attempts":          0,
+		"next_attempt_time": now,
+	}
+}
@@ -0,0 +1,224 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tdycwym/edgex_admin/config"
+	"github.com/tdycwym/edgex_admin/dal"
+	"github.com/tdycwym/edgex_admin/logs"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "webhook_test")
+	if err != nil {
+		panic(err)
+	}
+	config.LogConf = &config.LogConfig{FileName: filepath.Join(dir, "test.log")}
+	logs.InitLogs()
+	config.HookConf = &config.WebhookConfig{Timeout: 1000, MaxAttempts: 3, BaseBackoff: 10, MaxBackoff: 60}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// receiver 模拟订阅方, 按statusCodes依次返回状态码, 用完后返回最后一个
+type receiver struct {
+	*httptest.Server
+	secret      string
+	statusCodes []int
+	calls       int32
+	badSign     int32
+}
+
+func newReceiver(t *testing.T, secret string, statusCodes ...int) *receiver {
+	r := &receiver{secret: secret, statusCodes: statusCodes}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if !Verify(r.secret, req.Header.Get(HeaderTimestamp), body, req.Header.Get(HeaderSignature)) {
+			atomic.AddInt32(&r.badSign, 1)
+		}
+		n := int(atomic.AddInt32(&r.calls, 1))
+		if n > len(r.statusCodes) {
+			n = len(r.statusCodes)
+		}
+		w.WriteHeader(r.statusCodes[n-1])
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// apply 模拟UpdateWebhookDelivery写回投递记录
+func apply(item *dal.WebhookDelivery, fieldsMap map[string]interface{}) {
+	if v, ok := fieldsMap["status"]; ok {
+		item.Status = int32(v.(int))
+	}
+	if v, ok := fieldsMap["attempts"]; ok {
+		switch v := v.(type) {
+		case int32:
+			item.Attempts = v
+		case int:
+			item.Attempts = int32(v)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"edgex_id":1}`)
+	sign := Sign("secret", "1600000000", body)
+	if !Verify("secret", "1600000000", body, sign) {
+		t.Fatalf("signature should verify: %s", sign)
+	}
+	if Verify("other", "1600000000", body, sign) {
+		t.Fatal("signature should not verify with another secret")
+	}
+	if Verify("secret", "1600000001", body, sign) {
+		t.Fatal("signature should not verify with another timestamp")
+	}
+	if Verify("secret", "1600000000", []byte(`{"edgex_id":2}`), sign) {
+		t.Fatal("signature should not verify with another body")
+	}
+}
+
+func TestDeliverSigned(t *testing.T) {
+	var headers http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		headers = req.Header.Clone()
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer srv.Close()
+
+	sub := &dal.WebhookSubscription{ID: 1, URL: srv.URL, Secret: "s3cret", Status: dal.WebhookEnabled}
+	item := &dal.WebhookDelivery{ID: 42, SubscriptionID: 1, EventType: "edgex.offline", Payload: `{"edgex_id":7}`}
+	fieldsMap := attempt(srv.Client(), sub, item)
+
+	if fieldsMap["status"] != dal.DeliveryStatusSuccess {
+		t.Fatalf("status = %v, want success", fieldsMap["status"])
+	}
+	if string(body) != item.Payload {
+		t.Fatalf("body = %s, want %s", body, item.Payload)
+	}
+	if headers.Get(HeaderEvent) != "edgex.offline" || headers.Get(HeaderDelivery) != "42" {
+		t.Fatalf("unexpected headers: %v", headers)
+	}
+	timestamp := headers.Get(HeaderTimestamp)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Fatalf("timestamp = %q: %v", timestamp, err)
+	}
+	if headers.Get(HeaderSignature) != Sign("s3cret", timestamp, body) {
+		t.Fatalf("signature = %q", headers.Get(HeaderSignature))
+	}
+}
+
+func TestDeliverRetry(t *testing.T) {
+	srv := newReceiver(t, "s3cret", http.StatusInternalServerError)
+	sub := &dal.WebhookSubscription{ID: 1, URL: srv.URL, Secret: "s3cret", Status: dal.WebhookEnabled}
+	item := &dal.WebhookDelivery{ID: 1, SubscriptionID: 1, Payload: `{}`}
+
+	for i := 1; i < config.HookConf.MaxAttempts; i++ {
+		start := time.Now()
+		fieldsMap := attempt(srv.Client(), sub, item)
+		if fieldsMap["status"] != dal.DeliveryStatusPending {
+			t.Fatalf("attempt %d: status = %v, want pending", i, fieldsMap["status"])
+		}
+		if fieldsMap["last_status_code"] != http.StatusInternalServerError || fieldsMap["last_error"] == "" {
+			t.Fatalf("attempt %d: unexpected result: %v", i, fieldsMap)
+		}
+		next := fieldsMap["next_attempt_time"].(time.Time)
+		if wait := next.Sub(start); wait < Backoff(i) || wait > Backoff(i)+time.Second {
+			t.Fatalf("attempt %d: next attempt after %v, want %v", i, wait, Backoff(i))
+		}
+		apply(item, fieldsMap)
+	}
+
+	fieldsMap := attempt(srv.Client(), sub, item)
+	if fieldsMap["status"] != dal.DeliveryStatusFailed {
+		t.Fatalf("status = %v, want failed", fieldsMap["status"])
+	}
+	if _, ok := fieldsMap["next_attempt_time"]; ok {
+		t.Fatal("failed delivery should not be rescheduled")
+	}
+	if srv.calls != int32(config.HookConf.MaxAttempts) || srv.badSign != 0 {
+		t.Fatalf("calls = %d, bad signatures = %d", srv.calls, srv.badSign)
+	}
+}
+
+func TestDeliverRetryThenSuccess(t *testing.T) {
+	srv := newReceiver(t, "s3cret", http.StatusBadGateway, http.StatusOK)
+	sub := &dal.WebhookSubscription{ID: 1, URL: srv.URL, Secret: "s3cret", Status: dal.WebhookEnabled}
+	item := &dal.WebhookDelivery{ID: 1, SubscriptionID: 1, Payload: `{}`}
+
+	apply(item, attempt(srv.Client(), sub, item))
+	if item.Status != dal.DeliveryStatusPending || item.Attempts != 1 {
+		t.Fatalf("status = %d, attempts = %d", item.Status, item.Attempts)
+	}
+	apply(item, attempt(srv.Client(), sub, item))
+	if item.Status != dal.DeliveryStatusSuccess || item.Attempts != 2 {
+		t.Fatalf("status = %d, attempts = %d", item.Status, item.Attempts)
+	}
+}
+
+func TestDeliverDisabled(t *testing.T) {
+	srv := newReceiver(t, "s3cret", http.StatusOK)
+	sub := &dal.WebhookSubscription{ID: 1, URL: srv.URL, Secret: "s3cret", Status: dal.WebhookDisabled}
+	item := &dal.WebhookDelivery{ID: 1, SubscriptionID: 1, Payload: `{}`}
+
+	fieldsMap := attempt(srv.Client(), sub, item)
+	if fieldsMap["status"] != dal.DeliveryStatusFailed || srv.calls != 0 {
+		t.Fatalf("status = %v, calls = %d", fieldsMap["status"], srv.calls)
+	}
+	if fieldsMap = attempt(srv.Client(), nil, item); fieldsMap["status"] != dal.DeliveryStatusFailed {
+		t.Fatalf("deleted subscription: status = %v", fieldsMap["status"])
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	srv := newReceiver(t, "s3cret", http.StatusInternalServerError, http.StatusInternalServerError,
+		http.StatusInternalServerError, http.StatusOK)
+	sub := &dal.WebhookSubscription{ID: 1, URL: srv.URL, Secret: "s3cret", Status: dal.WebhookEnabled}
+	item := &dal.WebhookDelivery{ID: 1, SubscriptionID: 1, Payload: `{}`}
+
+	for item.Status == dal.DeliveryStatusPending {
+		apply(item, attempt(srv.Client(), sub, item))
+	}
+	if item.Status != dal.DeliveryStatusFailed {
+		t.Fatalf("status = %d, want failed", item.Status)
+	}
+
+	now := time.Now()
+	fieldsMap := redeliverFields(now)
+	if fieldsMap["next_attempt_time"] != now {
+		t.Fatalf("next_attempt_time = %v, want %v", fieldsMap["next_attempt_time"], now)
+	}
+	apply(item, fieldsMap)
+	if item.Status != dal.DeliveryStatusPending || item.Attempts != 0 {
+		t.Fatalf("status = %d, attempts = %d", item.Status, item.Attempts)
+	}
+
+	apply(item, attempt(srv.Client(), sub, item))
+	if item.Status != dal.DeliveryStatusSuccess || item.Attempts != 1 {
+		t.Fatalf("status = %d, attempts = %d", item.Status, item.Attempts)
+	}
+	if srv.badSign != 0 {
+		t.Fatalf("bad signatures = %d", srv.badSign)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 60 * time.Second, 60 * time.Second}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Fatalf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}